@@ -0,0 +1,65 @@
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Acknowledger reads from conn the acknowledgement of a relay to which a
+// flush wrote lines lines, or pickled datapoints, and returns how many of
+// them the relay confirmed receiving. It is not called for flushes which
+// wrote nothing.
+type Acknowledger func(conn net.Conn, lines int) (int, error)
+
+// AckLines is an Acknowledger for relays answering every flush with a line
+// "ACK <n>", n being the number of lines they received.
+func AckLines(conn net.Conn, lines int) (int, error) {
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if nil != err {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(line), "ACK "))
+	if !strings.HasPrefix(line, "ACK ") || nil != err {
+		return 0, fmt.Errorf("graphite: bad acknowledgement %q", line)
+	}
+	return n, nil
+}
+
+// acknowledged returns the number of the sent datapoints written to conn
+// by a flush which the relay acknowledged with c.Acknowledge, given the
+// error of the write, if any. Only acknowledged datapoints are considered
+// delivered: the others are queued again, or sent again by the next flush.
+func (e *Exporter) acknowledged(conn net.Conn, sent int, err error) (int, error) {
+	c := &e.c
+	if nil == c.Acknowledge || "" != c.HTTPURL {
+		return sent, err
+	}
+	if nil != err {
+		return 0, err
+	}
+	if datagram(network(c)) {
+		return 0, errors.New("graphite: acknowledgements require a stream network")
+	}
+	if 0 == sent {
+		return 0, nil
+	}
+	timeout := c.AckTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	acked, err := c.Acknowledge(conn, sent)
+	conn.SetReadDeadline(time.Time{})
+	if nil != err {
+		return 0, err
+	}
+	if acked < sent {
+		return acked, fmt.Errorf("graphite: relay acknowledged %d of %d datapoints", acked, sent)
+	}
+	return sent, nil
+}
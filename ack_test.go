@@ -0,0 +1,119 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestAckLines(t *testing.T) {
+	for _, tc := range []struct {
+		reply string
+		acked int
+		ok    bool
+	}{
+		{"ACK 3\n", 3, true},
+		{"ACK 0\r\n", 0, true},
+		{"NACK 3\n", 0, false},
+		{"ACK three\n", 0, false},
+	} {
+		client, server := net.Pipe()
+		go func() { server.Write([]byte(tc.reply)); server.Close() }()
+		acked, err := AckLines(client, 3)
+		if (nil == err) != tc.ok || acked != tc.acked {
+			t.Error("bad acknowledgement:", tc.reply, acked, err)
+		}
+		client.Close()
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	replies := make(chan func(lines int) string, 1)
+	e := New(GraphiteConfig{
+		Addr:          "carbon:2003",
+		Registry:      metrics.NewRegistry(),
+		FlushInterval: time.Second,
+		Prefix:        "p",
+		Acknowledge:   AckLines,
+		AckTimeout:    time.Second,
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			reply := <-replies
+			go func() {
+				defer server.Close()
+				r := bufio.NewReader(server)
+				for lines := 1; ; lines++ {
+					line, err := r.ReadString('\n')
+					if nil != err {
+						return
+					}
+					if strings.HasPrefix(line, "p.b ") {
+						server.Write([]byte(reply(lines)))
+						return
+					}
+				}
+			}()
+			return client, nil
+		},
+	})
+	queued := func() (paths []string) {
+		for _, dp := range e.queue {
+			paths = append(paths, dp.path)
+		}
+		return
+	}
+	e.Send("a", 1, time.Now())
+	e.Send("b", 2, time.Now())
+
+	replies <- func(int) string { return "" }
+	if err := e.flush().Err; nil == err {
+		t.Fatal("unacknowledged flush did not fail")
+	}
+	if paths := queued(); 2 != len(paths) {
+		t.Fatal("unacknowledged datapoints not queued again:", paths)
+	}
+
+	replies <- func(lines int) string { return fmt.Sprintf("ACK %d\n", lines-1) }
+	if err := e.flush().Err; nil == err || !strings.Contains(err.Error(), "acknowledged") {
+		t.Fatal("partly acknowledged flush did not fail:", err)
+	}
+	if paths := queued(); 1 != len(paths) || "p.b" != paths[0] {
+		t.Fatal("bad datapoints queued again:", paths)
+	}
+
+	replies <- func(lines int) string { return fmt.Sprintf("ACK %d\n", lines) }
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if paths := queued(); 0 != len(paths) {
+		t.Fatal("acknowledged datapoints queued again:", paths)
+	}
+}
+
+func TestAcknowledgeDatagram(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	e := New(GraphiteConfig{
+		Addr:          l.LocalAddr().String(),
+		Network:       "udp",
+		Registry:      metrics.NewRegistry(),
+		FlushInterval: time.Second,
+		Acknowledge:   AckLines,
+	})
+	e.Send("a", 1, time.Now())
+	if err := e.flush().Err; nil == err {
+		t.Fatal("acknowledgement over udp did not fail")
+	}
+	if 1 != len(e.queue) {
+		t.Fatal("unacknowledged datapoint not queued again:", e.queue)
+	}
+}
@@ -0,0 +1,89 @@
+package graphite
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// beaconAlert is an alert in the graphite-beacon configuration format.
+type beaconAlert struct {
+	Name     string   `json:"name"`
+	Query    string   `json:"query"`
+	Interval string   `json:"interval"`
+	Method   string   `json:"method,omitempty"`
+	NoData   string   `json:"no_data,omitempty"`
+	Rules    []string `json:"rules"`
+}
+
+// AlertRules returns skeleton graphite-beacon alerts, encoded as JSON,
+// for the series the exporter emits for the current content of
+// c.Registry:
+//
+//   - a heartbeat alert raised when no series under the prefix report;
+//   - an alert on the one-minute rate of every meter whose name mentions
+//     errors or failures;
+//   - an alert on the 99th percentile of every timer, if c.Percentiles
+//     includes it, with thresholds at twice and four times its current
+//     value.
+//
+// The thresholds are starting points meant to be reviewed. The result can
+// be used as the "alerts" key of a graphite-beacon configuration.
+func AlertRules(c *GraphiteConfig) ([]byte, error) {
+	p := prefix(c)
+	alerts := []beaconAlert{{
+		Name:     p + " heartbeat",
+		Query:    "countSeries(" + p + ".*)",
+		Interval: "1minute",
+		NoData:   "critical",
+		Rules:    []string{"critical: < 1"},
+	}}
+	for _, s := range Snapshot(c) {
+		for _, f := range s.Fields {
+			if a, ok := alertRule(c, s, f); ok {
+				alerts = append(alerts, a)
+			}
+		}
+	}
+	return json.MarshalIndent(alerts, "", "  ")
+}
+
+// alertRule returns the alert on field f of metric s, if any.
+func alertRule(c *GraphiteConfig, s MetricSnapshot, f Field) (beaconAlert, bool) {
+	name := seriesName(c, s, f)
+	switch {
+	case TypeMeter == s.Type && "one-minute" == f.Name && isErrorName(s.Name):
+		return beaconAlert{
+			Name:     s.Name + " error rate",
+			Query:    name,
+			Interval: "1minute",
+			Method:   "average",
+			Rules:    []string{"critical: > 1", "warning: > 0"},
+		}, true
+	case TypeTimer == s.Type && percentileKey(0.99)+"-percentile" == f.Name:
+		threshold := math.Max(f.Value, 1)
+		return beaconAlert{
+			Name:     s.Name + " p99",
+			Query:    name,
+			Interval: "5minute",
+			Method:   "average",
+			Rules: []string{
+				"critical: > " + strconv.FormatFloat(4*threshold, 'f', -1, 64),
+				"warning: > " + strconv.FormatFloat(2*threshold, 'f', -1, 64),
+			},
+		}, true
+	}
+	return beaconAlert{}, false
+}
+
+// isErrorName returns true if the metric called name counts errors.
+func isErrorName(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"error", "fail", "fault"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
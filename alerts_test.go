@@ -0,0 +1,39 @@
+package graphite
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestAlertRules(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterMeter("requests", r).Mark(1)
+	metrics.GetOrRegisterMeter("requests.errors", r).Mark(1)
+	metrics.GetOrRegisterTimer("latency", r).Update(50 * time.Millisecond)
+
+	b, err := AlertRules(&GraphiteConfig{
+		Registry:     r,
+		DurationUnit: time.Millisecond,
+		Prefix:       "app",
+		Percentiles:  []float64{0.5, 0.99},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	var alerts []beaconAlert
+	if err := json.Unmarshal(b, &alerts); nil != err {
+		t.Fatal(err)
+	}
+	expected := []beaconAlert{
+		{Name: "app heartbeat", Query: "countSeries(app.*)", Interval: "1minute", NoData: "critical", Rules: []string{"critical: < 1"}},
+		{Name: "latency p99", Query: "app.latency.99-percentile", Interval: "5minute", Method: "average", Rules: []string{"critical: > 200", "warning: > 100"}},
+		{Name: "requests.errors error rate", Query: "app.requests.errors.one-minute", Interval: "1minute", Method: "average", Rules: []string{"critical: > 1", "warning: > 0"}},
+	}
+	if !reflect.DeepEqual(alerts, expected) {
+		t.Fatal("bad alerts:", string(b))
+	}
+}
@@ -0,0 +1,50 @@
+package graphite
+
+import (
+	"strings"
+)
+
+// APIKeyRule sends the series matching Pattern to the hosted Graphite
+// account of Key, as done by HostedGraphite and similar providers which
+// expect every path to start with the API key of the account. Patterns are
+// matched against series names without prefix, tags included for tagged
+// series. Only the first matching rule applies.
+type APIKeyRule struct {
+	Pattern string
+	Key     string
+}
+
+// withAPIKeys returns dps with the API key of the first of c.APIKeys
+// matching each of them prepended to its path, or c.APIKey if none does.
+// dps is left untouched.
+func withAPIKeys(c *GraphiteConfig, dps []datapoint) []datapoint {
+	if !hasAPIKeys(c) {
+		return dps
+	}
+	keyed := make([]datapoint, len(dps))
+	p := prefix(c) + "."
+	for i, dp := range dps {
+		dp.path = apiKey(c, strings.TrimPrefix(dp.path, p)) + dp.path
+		keyed[i] = dp
+	}
+	return keyed
+}
+
+// hasAPIKeys returns true if series are sent with API keys.
+func hasAPIKeys(c *GraphiteConfig) bool {
+	return "" != c.APIKey || 0 != len(c.APIKeys)
+}
+
+// apiKey returns the API key series name is sent with, followed by a dot,
+// or an empty string if none.
+func apiKey(c *GraphiteConfig, name string) string {
+	for _, rule := range c.APIKeys {
+		if match(rule.Pattern, name) {
+			return rule.Key + "."
+		}
+	}
+	if "" != c.APIKey {
+		return c.APIKey + "."
+	}
+	return ""
+}
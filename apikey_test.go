@@ -0,0 +1,59 @@
+package graphite
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestWithAPIKeys(t *testing.T) {
+	c := &GraphiteConfig{Prefix: "app", APIKeys: []APIKeyRule{
+		{Pattern: "customers.acme.*", Key: "key-acme"},
+		{Pattern: "customers.*.*", Key: "key-shared"},
+	}}
+	dps := []datapoint{
+		{path: "app.customers.acme.requests"},
+		{path: "app.customers.initech.requests"},
+		{path: "app.internal.load"},
+		{path: "customers.acme.relayed"},
+	}
+	keyed := withAPIKeys(c, dps)
+	var paths []string
+	for _, dp := range keyed {
+		paths = append(paths, dp.path)
+	}
+	expected := []string{
+		"key-acme.app.customers.acme.requests",
+		"key-shared.app.customers.initech.requests",
+		"app.internal.load",
+		"key-acme.customers.acme.relayed",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatal("bad paths:", paths)
+	}
+	if "app.customers.acme.requests" != dps[0].path {
+		t.Fatal("datapoints modified:", dps)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.APIKey = "key-default"
+	c.APIKeys = []APIKeyRule{{Pattern: "acme.*", Key: "key-acme"}}
+	metrics.GetOrRegisterGauge("acme.load", r).Update(1)
+	metrics.GetOrRegisterGauge("load", r).Update(2)
+	e := New(c)
+	wg.Add(1)
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if expected, found := 1.0, res["key-acme.foobar.acme.load"]; !floatEquals(found, expected) {
+		t.Fatal("rule not applied:", res)
+	}
+	if expected, found := 2.0, res["key-default.foobar.load"]; !floatEquals(found, expected) {
+		t.Fatal("default API key not applied:", res)
+	}
+}
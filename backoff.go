@@ -0,0 +1,55 @@
+package graphite
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// retryFlush calls flush, a regular flush, until it succeeds and at most
+// c.RetryAttempts times, waiting for an exponential backoff with jitter
+// between attempts so that a Graphite server briefly unavailable does not
+// cost a whole flush interval. It gives up on flushes failed by invalid
+// lines, which fail again, on flushes skipped by the circuit breaker, once
+// the next wait would end after c.RetryMaxElapsed and when e is stopped,
+// returning the last result.
+func (e *Exporter) retryFlush(flush func() FlushResult) FlushResult {
+	c := &e.c
+	start := time.Now()
+	delay := c.RetryBackoff
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	maxElapsed := c.RetryMaxElapsed
+	if maxElapsed <= 0 {
+		maxElapsed = tick(c)
+	}
+	for attempt := 1; ; attempt++ {
+		res := flush()
+		var invalid *InvalidLinesError
+		if nil == res.Err || attempt >= c.RetryAttempts || errors.As(res.Err, &invalid) || errors.Is(res.Err, ErrCircuitOpen) {
+			return res
+		}
+		wait := jitter(delay)
+		if time.Since(start)+wait > maxElapsed {
+			return res
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-e.stop:
+			timer.Stop()
+			return res
+		case <-e.ctx.Done():
+			timer.Stop()
+			return res
+		}
+		delay *= 2
+	}
+}
+
+// jitter returns a random duration between half of d and d, so that the
+// exporters of many processes which failed together retry apart.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
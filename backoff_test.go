@@ -0,0 +1,73 @@
+package graphite
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryFlush(t *testing.T) {
+	failure := errors.New("unavailable")
+	for _, tc := range []struct {
+		attempts, failures, expected int
+		err                          error
+		ok                           bool
+	}{
+		{0, 3, 1, failure, false},
+		{5, 2, 3, failure, true},
+		{3, 5, 3, failure, false},
+		{5, 5, 1, &InvalidLinesError{}, false},
+	} {
+		e := New(GraphiteConfig{FlushInterval: time.Minute, RetryAttempts: tc.attempts, RetryBackoff: time.Millisecond})
+		calls := 0
+		res := e.retryFlush(func() FlushResult {
+			if calls++; calls <= tc.failures {
+				return FlushResult{Err: tc.err}
+			}
+			return FlushResult{}
+		})
+		if tc.expected != calls || tc.ok != (nil == res.Err) {
+			t.Error("bad retries:", tc.attempts, tc.failures, calls, res.Err)
+		}
+	}
+}
+
+func TestRetryFlushMaxElapsed(t *testing.T) {
+	e := New(GraphiteConfig{
+		FlushInterval:   time.Minute,
+		RetryAttempts:   100,
+		RetryBackoff:    10 * time.Millisecond,
+		RetryMaxElapsed: 100 * time.Millisecond,
+	})
+	calls := 0
+	start := time.Now()
+	e.retryFlush(func() FlushResult {
+		calls++
+		return FlushResult{Err: errors.New("unavailable")}
+	})
+	// Waits of at least 5, 10, 20 and 40ms fit, those of 160ms do not.
+	if elapsed := time.Since(start); calls < 3 || calls > 6 || elapsed > 100*time.Millisecond {
+		t.Fatal("bad retries:", calls, elapsed)
+	}
+}
+
+func TestRetryFlushStopped(t *testing.T) {
+	e := New(GraphiteConfig{FlushInterval: time.Minute, RetryAttempts: 3, RetryBackoff: time.Hour, RetryMaxElapsed: 2 * time.Hour})
+	close(e.stop)
+	calls := 0
+	e.retryFlush(func() FlushResult {
+		calls++
+		return FlushResult{Err: errors.New("unavailable")}
+	})
+	if 1 != calls {
+		t.Fatal("stopped exporter retried:", calls)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Second); d < time.Second/2 || d > time.Second {
+			t.Fatal("bad jitter:", d)
+		}
+	}
+}
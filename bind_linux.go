@@ -0,0 +1,19 @@
+package graphite
+
+import (
+	"syscall"
+)
+
+// bindToDevice returns a net.Dialer control function binding sockets to
+// device with SO_BINDTODEVICE.
+func bindToDevice(device string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		if cerr := c.Control(func(fd uintptr) {
+			err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, device)
+		}); nil != cerr {
+			return cerr
+		}
+		return err
+	}
+}
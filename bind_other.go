@@ -0,0 +1,16 @@
+//go:build !linux
+
+package graphite
+
+import (
+	"errors"
+	"syscall"
+)
+
+// bindToDevice returns a net.Dialer control function failing every dial
+// since SO_BINDTODEVICE is only available on Linux.
+func bindToDevice(device string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return errors.New("graphite: BindToDevice is only supported on Linux")
+	}
+}
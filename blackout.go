@@ -0,0 +1,155 @@
+package graphite
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BlackoutWindow pauses the regular flushes for Duration from every time
+// matching Schedule, to coordinate the maintenance of the metrics
+// infrastructure without every service logging errors and triggering gap
+// alerts. Schedule is a cron expression of five fields: minute, hour, day
+// of month, month and day of week, each being "*", a number, a range such
+// as "1-5", a list of those such as "1,15" and optionally a step such as
+// "*/15", or "5/15" for every 15 from 5. Names of months and days and other
+// extensions are rejected. Unlike cron, a time must match every field, days
+// of month and of week included. Times are local. Malformed schedules are
+// logged by New and ignored.
+type BlackoutWindow struct {
+	Schedule string
+	Duration time.Duration
+
+	// Spool queues the datapoints of the flushes skipped during the window,
+	// up to c.MaxQueued, so that they are sent after it rather than lost.
+	Spool bool
+}
+
+// blackoutWindow is a BlackoutWindow along with its parsed schedule and
+// the latest start of the window, which only changes every minute.
+type blackoutWindow struct {
+	BlackoutWindow
+	schedule schedule
+	minute   time.Time // minute for which start was computed
+	start    time.Time // latest matching minute less than Duration before minute
+}
+
+// blackoutWindows parses the schedules of ws, logging and leaving out the
+// malformed ones.
+func blackoutWindows(ws []BlackoutWindow) []*blackoutWindow {
+	windows := make([]*blackoutWindow, 0, len(ws))
+	for _, w := range ws {
+		s, err := parseSchedule(w.Schedule)
+		if nil != err {
+			log.Println(err)
+			continue
+		}
+		windows = append(windows, &blackoutWindow{BlackoutWindow: w, schedule: s})
+	}
+	return windows
+}
+
+// blackout returns the first of c.Blackouts in force at t, if any.
+func (e *Exporter) blackout(t time.Time) (BlackoutWindow, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, w := range e.blackouts {
+		if w.active(t) {
+			return w.BlackoutWindow, true
+		}
+	}
+	return BlackoutWindow{}, false
+}
+
+// active returns true if a window started by w is in force at t.
+func (w *blackoutWindow) active(t time.Time) bool {
+	if m := t.Truncate(time.Minute); !m.Equal(w.minute) {
+		w.minute, w.start = m, time.Time{}
+		for start := m; m.Sub(start) < w.Duration; start = start.Add(-time.Minute) {
+			if w.schedule.matches(start) {
+				w.start = start
+				break
+			}
+		}
+	}
+	return !w.start.IsZero() && t.Sub(w.start) < w.Duration
+}
+
+// spool queues the datapoints of a flush at ts skipped by a blackout. The
+// datapoints already queued are put back in front of them so that the
+// oldest ones are dropped first once c.MaxQueued is reached. Only shard is
+// spooled when regular flushes are sharded.
+func (e *Exporter) spool(ts time.Time, shard int) {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	dps, queued, _ := e.collect(selection{shard: shard}, ts)
+	e.requeue(queued)
+	e.mu.Lock()
+	e.enqueue(dps[:len(dps)-len(queued)]...)
+	e.mu.Unlock()
+	e.saveState()
+}
+
+// schedule is a parsed cron expression, holding for each field the set of
+// values it matches.
+type schedule [5]map[int]bool
+
+// scheduleBounds are the bounds of the fields of a cron expression.
+var scheduleBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// parseSchedule parses the cron expression expr.
+func parseSchedule(expr string) (schedule, error) {
+	var s schedule
+	bad := func(reason string) (schedule, error) {
+		return s, fmt.Errorf("graphite: bad blackout schedule %q: %s", expr, reason)
+	}
+	fields := strings.Fields(expr)
+	if 5 != len(fields) {
+		return bad(fmt.Sprintf("%d fields instead of 5", len(fields)))
+	}
+	for i, field := range fields {
+		min, max := scheduleBounds[i][0], scheduleBounds[i][1]
+		s[i] = make(map[int]bool)
+		for _, part := range strings.Split(field, ",") {
+			step, stepped := 1, false
+			if j := strings.IndexByte(part, '/'); j >= 0 {
+				n, err := strconv.Atoi(part[j+1:])
+				if nil != err || n <= 0 {
+					return bad(fmt.Sprintf("bad step %q", part))
+				}
+				step, part, stepped = n, part[:j], true
+			}
+			lo, hi := min, max
+			if "*" != part {
+				bounds := strings.SplitN(part, "-", 2)
+				var err error
+				if lo, err = strconv.Atoi(bounds[0]); nil != err {
+					return bad(fmt.Sprintf("bad value %q", part))
+				}
+				// A stepped single value, such as 5/15, runs up to max.
+				if hi = lo; stepped {
+					hi = max
+				}
+				if 2 == len(bounds) {
+					if hi, err = strconv.Atoi(bounds[1]); nil != err {
+						return bad(fmt.Sprintf("bad value %q", part))
+					}
+				}
+			}
+			if lo < min || hi > max || lo > hi {
+				return bad(fmt.Sprintf("%q out of [%d, %d]", part, min, max))
+			}
+			for v := lo; v <= hi; v += step {
+				s[i][v] = true
+			}
+		}
+	}
+	return s, nil
+}
+
+// matches returns true if t, truncated to the minute, matches s.
+func (s schedule) matches(t time.Time) bool {
+	return s[0][t.Minute()] && s[1][t.Hour()] && s[2][t.Day()] && s[3][int(t.Month())] && s[4][int(t.Weekday())]
+}
@@ -0,0 +1,119 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestBlackoutWindow(t *testing.T) {
+	// Saturdays from 02:00 to 04:00 and every quarter of an hour for a minute.
+	w := BlackoutWindow{Schedule: "0 2 * * 6", Duration: 2 * time.Hour}
+	q := BlackoutWindow{Schedule: "*/15 * * * *", Duration: time.Minute}
+	sat := time.Date(2023, time.January, 7, 0, 0, 0, 0, time.Local)
+	for _, tc := range []struct {
+		w        BlackoutWindow
+		t        time.Time
+		expected bool
+	}{
+		{w, sat.Add(1 * time.Hour), false},
+		{w, sat.Add(2 * time.Hour), true},
+		{w, sat.Add(3*time.Hour + 59*time.Minute), true},
+		{w, sat.Add(4 * time.Hour), false},
+		{w, sat.Add(26 * time.Hour), false},
+		{q, sat.Add(30*time.Minute + 30*time.Second), true},
+		{q, sat.Add(31 * time.Minute), false},
+		{BlackoutWindow{Schedule: "* * *", Duration: time.Hour}, sat, false},
+		{BlackoutWindow{Schedule: "0-5,30 1-2 1 1 */2", Duration: time.Minute}, sat.Add(-6*24*time.Hour + time.Hour), true},
+		{BlackoutWindow{Schedule: "0-5,30 1-2 1 1 1", Duration: time.Minute}, sat.Add(-6*24*time.Hour + time.Hour), false},
+	} {
+		windows := blackoutWindows([]BlackoutWindow{tc.w})
+		if found := 1 == len(windows) && windows[0].active(tc.t); tc.expected != found {
+			t.Error("bad window:", tc.w.Schedule, tc.t, found)
+		}
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "0,30 9-17 * 1-12/3 1-5", "*/5 */2 1,15 * 0"} {
+		if _, err := parseSchedule(expr); nil != err {
+			t.Error("rejected:", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "5/x * * * *", "70/5 * * * *"} {
+		if _, err := parseSchedule(expr); nil == err {
+			t.Error("accepted:", expr)
+		}
+	}
+	s, err := parseSchedule("5/15 * * * *")
+	if nil != err {
+		t.Fatal(err)
+	}
+	for m := 0; m < 60; m++ {
+		if expected := 5 == m || 20 == m || 35 == m || 50 == m; expected != s[0][m] {
+			t.Error("bad minute of a stepped value:", m, s[0][m])
+		}
+	}
+}
+
+func TestBlackoutWindowCache(t *testing.T) {
+	w := blackoutWindows([]BlackoutWindow{{Schedule: "0 2 * * *", Duration: 90 * time.Second}})[0]
+	two := time.Date(2023, time.January, 7, 2, 0, 0, 0, time.Local)
+	for _, tc := range []struct {
+		t        time.Time
+		expected bool
+	}{
+		{two.Add(-time.Second), false},
+		{two, true},
+		{two.Add(59 * time.Second), true},
+		{two.Add(89 * time.Second), true},
+		{two.Add(90 * time.Second), false},
+		{two.Add(24 * time.Hour), true},
+	} {
+		if found := w.active(tc.t); tc.expected != found {
+			t.Error("bad window:", tc.t, found)
+		}
+	}
+}
+
+func TestBlackoutSpool(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.Blackouts = []BlackoutWindow{{Schedule: "* * * * *", Duration: time.Minute, Spool: true}}
+	metrics.GetOrRegisterGauge("foo", r).Update(3)
+
+	e := New(c)
+	e.Start()
+	time.Sleep(50 * time.Millisecond)
+	e.Stop()
+	if 0 != len(res) {
+		t.Fatal("flushed during a blackout:", res)
+	}
+	n := len(e.queue)
+	if 0 == n {
+		t.Fatal("nothing spooled")
+	}
+
+	wg.Add(1)
+	if r := e.flush(); nil != r.Err || n+1 != r.Lines {
+		t.Fatal("bad flush:", r, n)
+	}
+	wg.Wait()
+	if expected, found := float64(3*(n+1)), res["foobar.foo"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+}
+
+func TestBlackoutSpoolBound(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	e := New(GraphiteConfig{Registry: r, Prefix: "foobar", MaxQueued: 2})
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 3; i++ {
+		e.spool(start.Add(time.Duration(i)*time.Minute), allShards)
+	}
+	if 2 != len(e.queue) || start.Add(time.Minute).Unix() != e.queue[0].timestamp || start.Add(2*time.Minute).Unix() != e.queue[1].timestamp {
+		t.Fatal("newest datapoints not kept:", e.queue)
+	}
+}
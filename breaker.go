@@ -0,0 +1,84 @@
+package graphite
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrCircuitOpen fails the flushes skipped, without dialing, while the
+// circuit breaker is open, see GraphiteConfig.BreakerFailures. It is not
+// logged.
+var ErrCircuitOpen = errors.New("graphite: circuit breaker open")
+
+// BreakerState is the state of the circuit breaker of an exporter.
+type BreakerState struct {
+	Open     bool      // Whether flushes are skipped without dialing
+	Failures int       // Consecutive failed flushes
+	Until    time.Time // End of the cool-down of an open breaker, after which flushes dial again
+	Skipped  int       // Flushes skipped since the breaker last opened
+}
+
+// Breaker returns the state of the circuit breaker, which never opens
+// unless c.BreakerFailures is set.
+func (e *Exporter) Breaker() BreakerState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.breaker
+	s.Open = time.Now().Before(s.Until)
+	return s
+}
+
+// allow returns ErrCircuitOpen if the flush started at t is skipped since
+// the breaker is open.
+func (e *Exporter) allow(t time.Time) error {
+	if e.c.BreakerFailures <= 0 {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if t.Before(e.breaker.Until) {
+		e.breaker.Skipped++
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// trip counts res, the result of a flush which was not skipped. The breaker
+// opens for c.BreakerCooldown once c.BreakerFailures flushes failed in a
+// row, again after every failed flush following a cool-down, and closes
+// after a successful one. Opening and closing are logged once each, rather
+// than every failed flush.
+func (e *Exporter) trip(res FlushResult) {
+	c := &e.c
+	if c.BreakerFailures <= 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b := &e.breaker
+	if nil == res.Err {
+		if b.Failures >= c.BreakerFailures {
+			log.Printf("graphite: circuit breaker closed after %d failed and %d skipped flushes", b.Failures, b.Skipped)
+		}
+		e.breaker = BreakerState{}
+		return
+	}
+	if b.Failures++; b.Failures < c.BreakerFailures {
+		return
+	}
+	cooldown := c.BreakerCooldown
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	b.Until, b.Skipped = time.Now().Add(cooldown), 0
+	log.Printf("graphite: circuit breaker open for %s after %d failed flushes: %v", cooldown, b.Failures, res.Err)
+}
+
+// logFailure logs the error of res, if any, unless the flush was skipped by
+// the circuit breaker.
+func logFailure(res FlushResult) {
+	if nil != res.Err && !errors.Is(res.Err, ErrCircuitOpen) {
+		log.Println(res.Err)
+	}
+}
@@ -0,0 +1,73 @@
+package graphite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestBreaker(t *testing.T) {
+	dials, up := 0, false
+	e := New(GraphiteConfig{
+		Addr:            "carbon:2003",
+		Registry:        metrics.NewRegistry(),
+		FlushInterval:   time.Second,
+		BreakerFailures: 2,
+		BreakerCooldown: 50 * time.Millisecond,
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			dials++
+			if !up {
+				return nil, errors.New("unreachable")
+			}
+			client, server := net.Pipe()
+			go func() { io.Copy(io.Discard, server) }()
+			return client, nil
+		},
+	})
+	for i := 0; i < 2; i++ {
+		if err := e.flush().Err; nil == err || errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("bad failure:", i, err)
+		}
+	}
+	if s := e.Breaker(); !s.Open || 2 != s.Failures {
+		t.Fatal("breaker not open:", s)
+	}
+	if err := e.flush().Err; !errors.Is(err, ErrCircuitOpen) || 2 != dials {
+		t.Fatal("flush not skipped:", dials, err)
+	}
+	if s := e.Breaker(); 1 != s.Skipped {
+		t.Fatal("skipped flush not counted:", s)
+	}
+
+	// A failure after the cool-down opens the breaker again.
+	time.Sleep(60 * time.Millisecond)
+	if s := e.Breaker(); s.Open {
+		t.Fatal("breaker open after the cool-down:", s)
+	}
+	if err := e.flush().Err; nil == err || 3 != dials || !e.Breaker().Open {
+		t.Fatal("failure after the cool-down did not open the breaker:", dials, err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	up = true
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if s := e.Breaker(); (BreakerState{}) != s {
+		t.Fatal("breaker not closed:", s)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	e := New(GraphiteConfig{Addr: "127.0.0.1:1", Registry: metrics.NewRegistry(), FlushInterval: time.Second})
+	for i := 0; i < 5; i++ {
+		if err := e.flush().Err; errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("disabled breaker opened")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package graphite
+
+import (
+	"time"
+)
+
+// errorBudget tracks failed flushes over GraphiteConfig.DegradedWindow and
+// calls OnDegraded and OnRecovered when the exporter enters and leaves the
+// degraded state. The exporter is degraded once DegradedFailures flushes
+// failed within the window, and recovers on the first successful flush
+// after fewer failures remain within the window.
+//
+// record returns the callback to invoke, if any, so the caller may call it
+// without holding its locks.
+type errorBudget struct {
+	failures []time.Time
+	degraded bool
+}
+
+func (b *errorBudget) record(c *GraphiteConfig, res FlushResult) func() {
+	if c.DegradedFailures <= 0 {
+		return nil
+	}
+	now := res.Time
+	kept := b.failures[:0]
+	for _, t := range b.failures {
+		if now.Sub(t) < c.DegradedWindow {
+			kept = append(kept, t)
+		}
+	}
+	b.failures = kept
+	if nil != res.Err {
+		b.failures = append(b.failures, now)
+	}
+
+	switch {
+	case !b.degraded && len(b.failures) >= c.DegradedFailures:
+		b.degraded = true
+		if nil != c.OnDegraded {
+			return func() { c.OnDegraded(res.Err) }
+		}
+	case b.degraded && nil == res.Err && len(b.failures) < c.DegradedFailures:
+		b.degraded = false
+		return c.OnRecovered
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package graphite
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestErrorBudget(t *testing.T) {
+	var degraded, recovered int
+	c := &GraphiteConfig{
+		DegradedFailures: 3,
+		DegradedWindow:   5 * time.Minute,
+		OnDegraded:       func(error) { degraded++ },
+		OnRecovered:      func() { recovered++ },
+	}
+	var b errorBudget
+	start := time.Unix(0, 0)
+	fail := errors.New("fail")
+	for i, err := range []error{fail, nil, fail, fail, fail, fail, nil} {
+		if f := b.record(c, FlushResult{Time: start.Add(time.Duration(i) * time.Minute), Err: err}); f != nil {
+			f()
+		}
+	}
+	if degraded != 1 || recovered != 0 {
+		t.Fatal("bad callbacks:", degraded, recovered)
+	}
+
+	// Failures age out of the window.
+	b.record(c, FlushResult{Time: start.Add(20 * time.Minute)})()
+	if degraded != 1 || recovered != 1 {
+		t.Fatal("bad callbacks:", degraded, recovered)
+	}
+}
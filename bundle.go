@@ -0,0 +1,165 @@
+package graphite
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// payload is the plaintext written by a flush.
+type payload struct {
+	time time.Time
+	data []byte
+}
+
+// capture keeps a copy of data, written by the flush started at t, if
+// c.CapturePayloads is set.
+func (e *Exporter) capture(t time.Time, data []byte) {
+	if e.c.CapturePayloads <= 0 {
+		return
+	}
+	p := payload{time: t, data: append([]byte(nil), data...)}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.enforce()
+	if len(e.payloads) < e.c.CapturePayloads {
+		e.payloads = append(e.payloads, p)
+		return
+	}
+	e.payloads[e.nextPay] = p
+	e.nextPay = (e.nextPay + 1) % len(e.payloads)
+}
+
+// SupportBundle writes to w a gzipped tar archive to attach to bug reports,
+// holding:
+//
+//   - config.json, the configuration of e without registries, callbacks
+//     and secrets;
+//   - state.txt, as written by DumpState;
+//   - history.txt, the results of the flushes returned by History;
+//   - payloads/, the last c.CapturePayloads flush payloads, oldest first.
+func (e *Exporter) SupportBundle(w io.Writer) error {
+	config, err := json.MarshalIndent(scrub(&e.c), "", "  ")
+	if nil != err {
+		return err
+	}
+	var state bytes.Buffer
+	if err := e.DumpState(&state); nil != err {
+		return err
+	}
+	var history bytes.Buffer
+	for _, res := range e.History() {
+		fmt.Fprintf(&history, "%s addr=%s duration=%s lines=%d bytes=%d error=%v\n", res.Time.Format(time.RFC3339Nano), res.Addr, res.Duration, res.Lines, res.Bytes, res.Err)
+	}
+	e.mu.Lock()
+	payloads := append(append([]payload(nil), e.payloads[e.nextPay:]...), e.payloads[:e.nextPay]...)
+	e.mu.Unlock()
+
+	now := time.Now()
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, t time.Time, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: t}
+		if err := tw.WriteHeader(hdr); nil != err {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := add("config.json", now, config); nil != err {
+		return err
+	}
+	if err := add("state.txt", now, state.Bytes()); nil != err {
+		return err
+	}
+	if err := add("history.txt", now, history.Bytes()); nil != err {
+		return err
+	}
+	for i, p := range payloads {
+		name := fmt.Sprintf("payloads/%03d-%s.txt", i, p.time.UTC().Format("20060102T150405.000000000Z"))
+		if err := add(name, p.time, p.data); nil != err {
+			return err
+		}
+	}
+	if err := tw.Close(); nil != err {
+		return err
+	}
+	return gz.Close()
+}
+
+// secretFields are the fields of GraphiteConfig left out of support
+// bundles. TLSConfig holds private keys and session ticket keys.
+var secretFields = map[string]bool{
+	"APIKey":    true,
+	"APIKeys":   true,
+	"HTTPToken": true,
+	"TLSConfig": true,
+}
+
+// scrub returns the fields of c worth reporting, keyed by name: values
+// such as registries, callbacks and secrets are left out, as well as the
+// callbacks held by other fields, such as the functions of Transforms.
+func scrub(c *GraphiteConfig) map[string]interface{} {
+	fields := make(map[string]interface{})
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if opaque(f.Type) || secretFields[f.Name] {
+			continue
+		}
+		fields[f.Name] = summary(v.Field(i))
+	}
+	return fields
+}
+
+// opaque returns true for the types of values which cannot be reported.
+func opaque(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Func, reflect.Interface, reflect.Chan, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+// summary returns v without the opaque values it holds.
+func summary(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() && !opaque(f.Type) {
+				fields[f.Name] = summary(v.Field(i))
+			}
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		if reflect.Slice == v.Kind() && v.IsNil() {
+			return nil
+		}
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elems[i] = summary(v.Index(i))
+		}
+		return elems
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		elems := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			elems[fmt.Sprint(k.Interface())] = summary(v.MapIndex(k))
+		}
+		return elems
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return summary(v.Elem())
+	}
+	return v.Interface()
+}
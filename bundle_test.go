@@ -0,0 +1,103 @@
+package graphite
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSupportBundle(t *testing.T) {
+	e := New(GraphiteConfig{
+		Addr:            "127.0.0.1:2003",
+		Registry:        metrics.NewRegistry(),
+		Prefix:          "app",
+		CapturePayloads: 2,
+		HistorySize:     2,
+		OnConnect:       func(string) {},
+		APIKey:          "secret",
+		TLSConfig:       &tls.Config{SessionTicketKey: [32]byte{1}},
+		APIKeys:         []APIKeyRule{{Pattern: "*", Key: "secret"}},
+		Transforms:      []FieldTransform{{Pattern: "latency.*", Func: Scale(1000)}},
+	})
+	start := time.Unix(1700000000, 0)
+	for i, line := range []string{"a 1 1\n", "b 2 2\n", "c 3 3\n"} {
+		e.capture(start.Add(time.Duration(i)*time.Second), []byte(line))
+	}
+	e.record(FlushResult{Addr: "127.0.0.1:2003", Time: start, Lines: 1, Bytes: 6})
+
+	var buf bytes.Buffer
+	if err := e.SupportBundle(&buf); nil != err {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	names := make([]string, 0)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(b)
+		names = append(names, hdr.Name)
+	}
+	if 5 != len(names) || "payloads/000-20231114T221321.000000000Z.txt" != names[3] {
+		t.Fatal("bad files:", names)
+	}
+	if "b 2 2\n" != files[names[3]] || "c 3 3\n" != files[names[4]] {
+		t.Fatal("bad payloads:", files)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(files["config.json"]), &config); nil != err {
+		t.Fatal(err)
+	}
+	if "app" != config["Prefix"] {
+		t.Fatal("bad config:", config)
+	}
+	if transforms, ok := config["Transforms"].([]interface{}); !ok || 1 != len(transforms) || "latency.*" != transforms[0].(map[string]interface{})["Pattern"] {
+		t.Fatal("bad transforms:", config["Transforms"])
+	}
+	for _, name := range []string{"Registry", "OnConnect", "Tracer", "APIKey", "APIKeys", "TLSConfig"} {
+		if _, ok := config[name]; ok {
+			t.Fatal("unscrubbed field:", name)
+		}
+	}
+	if !strings.Contains(files["state.txt"], "127.0.0.1:2003") || !strings.Contains(files["state.txt"], "[latency.*]") || !strings.Contains(files["history.txt"], "lines=1 bytes=6") {
+		t.Fatal("bad state or history:", files)
+	}
+}
+
+func TestCapturedPayloadsWithoutAPIKeys(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.CapturePayloads = 1
+	c.APIKeys = []APIKeyRule{{Pattern: "*", Key: "secret"}}
+	e := New(c)
+	e.Send("foo", 1, time.Now())
+	wg.Add(1)
+	if err := e.Flush(); nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if _, ok := res["secret.foobar.foo"]; !ok {
+		t.Fatal("API key not sent:", res)
+	}
+	if 1 != len(e.payloads) || !strings.HasPrefix(string(e.payloads[0].data), "foobar.foo ") {
+		t.Fatal("bad captured payload:", e.payloads)
+	}
+}
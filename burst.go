@@ -0,0 +1,107 @@
+package graphite
+
+import (
+	"time"
+)
+
+// Burst temporarily flushes the metrics whose names match any of patterns
+// every interval, on top of the regular flushes, until d elapsed or e is
+// stopped. It is meant to get high resolution data for a few metrics during
+// an incident or a load test without reconfiguring the exporter. Burst does
+// not block. Burst flushes are recorded and published on Results like
+// regular ones and are skipped during blackouts. Regular flushes leave out
+// the metrics of a burst while it lasts, so that counter deltas are not
+// split between both and no series gets two values for the same second.
+func (e *Exporter) Burst(patterns []string, interval, d time.Duration) {
+	b := &burst{keep: func(name string) bool {
+		for _, pattern := range patterns {
+			if match(pattern, name) {
+				return true
+			}
+		}
+		return false
+	}}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	select {
+	case <-e.stop:
+		return
+	default:
+	}
+	e.bursts = append(e.bursts, b)
+	e.spawn(&e.bursting, func() {
+		defer func() {
+			e.mu.Lock()
+			b.ended = true
+			e.mu.Unlock()
+		}()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		deadline := time.After(d)
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-e.ctx.Done():
+				return
+			case <-deadline:
+				return
+			case now := <-ticker.C:
+				if _, ok := e.blackout(now); ok {
+					continue
+				}
+				res := e.flushMatching(b.keep)
+				logFailure(res)
+				e.mu.Lock()
+				b.last = res.Time.Unix()
+				e.mu.Unlock()
+				e.record(res)
+				e.publish(res)
+			}
+		}
+	})
+}
+
+// burst is a burst of flushes started by Exporter.Burst.
+type burst struct {
+	keep  func(name string) bool
+	last  int64 // second of the last flush
+	ended bool
+}
+
+// withoutBursts returns the snapshots of snaps which are not flushed by a
+// burst, for a regular flush at ts. Metrics of bursts which ended during the
+// second of ts are still left out since their timestamps would collide.
+func (e *Exporter) withoutBursts(snaps []MetricSnapshot, ts time.Time) []MetricSnapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	bursts := e.bursts[:0]
+	for _, b := range e.bursts {
+		if !b.ended || b.last >= ts.Unix() {
+			bursts = append(bursts, b)
+		}
+	}
+	e.bursts = bursts
+	if 0 == len(bursts) {
+		return snaps
+	}
+	return filter(snaps, func(name string) bool {
+		for _, b := range bursts {
+			if b.keep(name) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// filter returns the snapshots of snaps for which keep returns true.
+func filter(snaps []MetricSnapshot, keep func(name string) bool) []MetricSnapshot {
+	kept := snaps[:0]
+	for _, s := range snaps {
+		if keep(s.Name) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
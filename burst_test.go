@@ -0,0 +1,120 @@
+package graphite_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberdelia/go-metrics-graphite"
+	"github.com/cyberdelia/go-metrics-graphite/graphitetest"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestBurst(t *testing.T) {
+	s, err := graphitetest.NewFlakyServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("hot.foo", r).Inc(1)
+	metrics.GetOrRegisterCounter("cold", r).Inc(1)
+
+	e := graphite.New(graphite.GraphiteConfig{
+		Addr:          s.Addr(),
+		Registry:      r,
+		FlushInterval: time.Hour,
+		DurationUnit:  time.Millisecond,
+		Prefix:        "foobar",
+	})
+	e.Start()
+	defer e.Stop()
+
+	e.Burst([]string{"hot.*"}, 10*time.Millisecond, 55*time.Millisecond)
+	if !s.WaitLines(1, time.Second) {
+		t.Fatal("no burst flush")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	e.Stop()
+	results := 0
+	for res := range e.Results() {
+		if nil != res.Err || 1 != res.Lines {
+			t.Fatal("bad burst result:", res)
+		}
+		results++
+	}
+	if 0 == results {
+		t.Fatal("burst flushes not published")
+	}
+
+	lines := s.Lines()
+	if len(lines) > 6 {
+		t.Fatal("burst did not stop:", len(lines))
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "foobar.hot.foo ") {
+			t.Fatal("unexpected line:", line)
+		}
+	}
+}
+
+func TestBurstRegularFlushes(t *testing.T) {
+	s, err := graphitetest.NewFlakyServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("hot.foo", r).Inc(1)
+	metrics.GetOrRegisterCounter("cold", r).Inc(1)
+
+	e := graphite.New(graphite.GraphiteConfig{
+		Addr:          s.Addr(),
+		Registry:      r,
+		FlushInterval: 10 * time.Millisecond,
+		DurationUnit:  time.Millisecond,
+		Prefix:        "foobar",
+		CounterDeltas: true,
+	})
+	e.Burst([]string{"hot.*"}, time.Hour, time.Hour)
+	e.Start()
+	if !s.WaitLines(3, time.Second) {
+		t.Fatal("no regular flush")
+	}
+	e.Stop()
+	for _, line := range s.Lines() {
+		if !strings.HasPrefix(line, "foobar.cold ") {
+			t.Fatal("metric of a burst sent by a regular flush:", line)
+		}
+	}
+}
+
+func TestBurstBlackout(t *testing.T) {
+	s, err := graphitetest.NewFlakyServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("hot.foo", r).Inc(1)
+
+	e := graphite.New(graphite.GraphiteConfig{
+		Addr:          s.Addr(),
+		Registry:      r,
+		FlushInterval: time.Hour,
+		DurationUnit:  time.Millisecond,
+		Prefix:        "foobar",
+		Blackouts:     []graphite.BlackoutWindow{{Schedule: "* * * * *", Duration: time.Hour}},
+	})
+	e.Start()
+	e.Burst([]string{"hot.*"}, 5*time.Millisecond, 50*time.Millisecond)
+	time.Sleep(80 * time.Millisecond)
+	e.Stop()
+	if lines := s.Lines(); 0 != len(lines) {
+		t.Fatal("burst flushed during a blackout:", lines)
+	}
+}
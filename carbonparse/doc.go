@@ -0,0 +1,3 @@
+// Package carbonparse parses the Graphite plaintext protocol, including
+// tagged series, for relays, local receivers and test assertions.
+package carbonparse
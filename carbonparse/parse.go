@@ -0,0 +1,145 @@
+package carbonparse
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Datapoint is a single line of the plaintext protocol.
+type Datapoint struct {
+	Path      string // Series name, without tags
+	Value     float64
+	Timestamp int64             // Unix time in seconds
+	Tags      map[string]string // Tags of a tagged series such as "cpu;host=a", nil if none
+}
+
+// Series returns the name of the series of d, with its tags sorted by
+// name the way Graphite normalizes tagged series.
+func (d Datapoint) Series() string {
+	if 0 == len(d.Tags) {
+		return d.Path
+	}
+	keys := make([]string, 0, len(d.Tags))
+	for k := range d.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := d.Path
+	for _, k := range keys {
+		s += ";" + k + "=" + d.Tags[k]
+	}
+	return s
+}
+
+// String returns d formatted as a line of the plaintext protocol, without
+// the trailing newline.
+func (d Datapoint) String() string {
+	return d.Series() + " " + strconv.FormatFloat(d.Value, 'f', -1, 64) + " " + strconv.FormatInt(d.Timestamp, 10)
+}
+
+// ParseError describes a line which could not be parsed.
+type ParseError struct {
+	Line   int    // Number of the line, starting at 1, or 0 for ParseLine
+	Text   string // Offending line
+	Reason string
+}
+
+func (e *ParseError) Error() string {
+	if 0 == e.Line {
+		return fmt.Sprintf("carbonparse: %s: %q", e.Reason, e.Text)
+	}
+	return fmt.Sprintf("carbonparse: line %d: %s: %q", e.Line, e.Reason, e.Text)
+}
+
+// ParseLine parses s, a line of the plaintext protocol optionally
+// terminated by a newline. Errors are of type *ParseError.
+func ParseLine(s string) (Datapoint, error) {
+	text := strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+	if strings.ContainsAny(text, "\r\n") {
+		return Datapoint{}, &ParseError{Text: s, Reason: "embedded newline"}
+	}
+	// Fields are separated by runs of whitespace, leading and trailing
+	// whitespace ignored, as carbon does.
+	parts := strings.Fields(text)
+	if 3 != len(parts) {
+		return Datapoint{}, &ParseError{Text: s, Reason: fmt.Sprintf("%d whitespace separated fields instead of 3", len(parts))}
+	}
+	nodes := strings.Split(parts[0], ";")
+	if "" == nodes[0] {
+		return Datapoint{}, &ParseError{Text: s, Reason: "empty name"}
+	}
+	d := Datapoint{Path: nodes[0]}
+	for _, tag := range nodes[1:] {
+		i := strings.IndexByte(tag, '=')
+		if i <= 0 || i == len(tag)-1 {
+			return Datapoint{}, &ParseError{Text: s, Reason: fmt.Sprintf("malformed tag %q", tag)}
+		}
+		if nil == d.Tags {
+			d.Tags = make(map[string]string)
+		}
+		d.Tags[tag[:i]] = tag[i+1:]
+	}
+	var err error
+	if d.Value, err = strconv.ParseFloat(parts[1], 64); nil != err {
+		return Datapoint{}, &ParseError{Text: s, Reason: "malformed value"}
+	}
+	if d.Timestamp, err = strconv.ParseInt(parts[2], 10, 64); nil != err {
+		return Datapoint{}, &ParseError{Text: s, Reason: "malformed timestamp"}
+	}
+	return d, nil
+}
+
+// Parse parses the lines read from r, skipping blank lines. It stops at
+// the first malformed line, returning the datapoints parsed so far along
+// with a *ParseError. Use a Reader to skip malformed lines instead.
+func Parse(r io.Reader) ([]Datapoint, error) {
+	dps := make([]Datapoint, 0)
+	pr := NewReader(r)
+	for {
+		d, err := pr.Next()
+		if io.EOF == err {
+			return dps, nil
+		}
+		if nil != err {
+			return dps, err
+		}
+		dps = append(dps, d)
+	}
+}
+
+// Reader parses a stream of lines one datapoint at a time.
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader returns a Reader parsing the lines read from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{scanner: bufio.NewScanner(r)}
+}
+
+// Next returns the datapoint of the next non-blank line. It returns a
+// *ParseError for a malformed line, after which Next may be called again
+// to carry on with the following line, and io.EOF at the end of the
+// stream.
+func (r *Reader) Next() (Datapoint, error) {
+	for r.scanner.Scan() {
+		r.line++
+		if "" == strings.TrimSpace(r.scanner.Text()) {
+			continue
+		}
+		d, err := ParseLine(r.scanner.Text())
+		if nil != err {
+			err.(*ParseError).Line = r.line
+		}
+		return d, err
+	}
+	if err := r.scanner.Err(); nil != err {
+		return Datapoint{}, err
+	}
+	return Datapoint{}, io.EOF
+}
@@ -0,0 +1,74 @@
+package carbonparse
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	d, err := ParseLine("cpu.user;host=a;dc=eu 1.5 1234567890\r\n")
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := Datapoint{Path: "cpu.user", Value: 1.5, Timestamp: 1234567890, Tags: map[string]string{"host": "a", "dc": "eu"}}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatal("bad datapoint:", d)
+	}
+	if "cpu.user;dc=eu;host=a 1.5 1234567890" != d.String() {
+		t.Fatal("bad string:", d.String())
+	}
+	for _, tc := range []struct {
+		line     string
+		expected Datapoint
+	}{
+		{"foo 1 2 ", Datapoint{Path: "foo", Value: 1, Timestamp: 2}},
+		{"foo  1 2", Datapoint{Path: "foo", Value: 1, Timestamp: 2}},
+		{"foo\t1\t2", Datapoint{Path: "foo", Value: 1, Timestamp: 2}},
+		{" foo 1 2\r\n", Datapoint{Path: "foo", Value: 1, Timestamp: 2}},
+		{"foo;a=b \t 1   2 \t", Datapoint{Path: "foo", Value: 1, Timestamp: 2, Tags: map[string]string{"a": "b"}}},
+	} {
+		if d, err := ParseLine(tc.line); nil != err || !reflect.DeepEqual(tc.expected, d) {
+			t.Errorf("%q: bad datapoint %v: %v", tc.line, d, err)
+		}
+	}
+	for _, s := range []string{"", "   ", "foo 1", " 1 2", "foo x 1", "foo 1 x", "foo 1 2 3", "foo;bar 1 2", "foo;=a 1 2", "foo;a= 1 2", ";a=b 1 2", "foo\nbar 1 2"} {
+		var perr *ParseError
+		if _, err := ParseLine(s); !errors.As(err, &perr) {
+			t.Errorf("%q: bad error %v", s, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	dps, err := Parse(strings.NewReader("a 1 1\n\nb 2 2 \n \t\nc\nd 4 4\n"))
+	var perr *ParseError
+	if !errors.As(err, &perr) || 5 != perr.Line || "c" != perr.Text {
+		t.Fatal("bad error:", err)
+	}
+	if 2 != len(dps) || "b" != dps[1].Path || nil != dps[1].Tags {
+		t.Fatal("bad datapoints:", dps)
+	}
+}
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader("a 1 1\nbroken\nb 2 2"))
+	var paths []string
+	var errs int
+	for {
+		d, err := r.Next()
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			errs++
+			continue
+		}
+		paths = append(paths, d.Path)
+	}
+	if 1 != errs || !reflect.DeepEqual(paths, []string{"a", "b"}) {
+		t.Fatal("bad datapoints:", paths, errs)
+	}
+}
@@ -0,0 +1,86 @@
+package graphite
+
+import (
+	"math"
+)
+
+// ClampRule bounds the values of the series matching Pattern to
+// [Min, Max]. Use math.Inf to leave one side unbounded. Patterns are
+// matched against series names without prefix, such as "db.query.max".
+type ClampRule struct {
+	Pattern string
+	Min     float64
+	Max     float64
+}
+
+// OutlierRule drops the values of the series matching Pattern which are
+// farther than StdDevs standard deviations from the mean of the values seen
+// so far for that series. No value is dropped until MinSamples values were
+// seen. Dropped values still count towards the mean so that a lasting level
+// shift is eventually accepted.
+type OutlierRule struct {
+	Pattern    string
+	StdDevs    float64
+	MinSamples int
+}
+
+// NegativePolicy controls how counters holding negative values, usually the
+// result of buggy decrements, are exported.
+type NegativePolicy int
+
+// Policies for negative counters.
+const (
+	NegativeSend  NegativePolicy = iota // Send negative values as-is
+	NegativeClamp                       // Send zero instead
+	NegativeDrop                        // Do not send the counter, see Exporter.NegativeCounters
+)
+
+// runningStats computes a running mean and variance using Welford's
+// algorithm.
+type runningStats struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+func (r *runningStats) add(v float64) {
+	r.n++
+	d := v - r.mean
+	r.mean += d / float64(r.n)
+	r.m2 += d * (v - r.mean)
+}
+
+func (r *runningStats) stdDev() float64 {
+	if r.n < 2 {
+		return 0
+	}
+	return math.Sqrt(r.m2 / float64(r.n-1))
+}
+
+// outlier records v for series name and reports whether it is an outlier
+// according to the first matching OutlierRule.
+func (e *Exporter) outlier(name string, v float64) bool {
+	for _, rule := range e.c.Outliers {
+		if !match(rule.Pattern, name) {
+			continue
+		}
+		stats, ok := e.outliers[name]
+		if !ok {
+			stats = &runningStats{}
+			e.outliers[name] = stats
+		}
+		drop := stats.n >= rule.MinSamples && stats.n >= 2 &&
+			math.Abs(v-stats.mean) > rule.StdDevs*stats.stdDev()
+		stats.add(v)
+		return drop
+	}
+	return false
+}
+
+// NegativeCounters returns the number of negative counter values dropped
+// because of the NegativeDrop policy.
+func (e *Exporter) NegativeCounters() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.negatives
+}
@@ -0,0 +1,83 @@
+package graphite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClamp(t *testing.T) {
+	e := New(GraphiteConfig{Clamp: []ClampRule{
+		{Pattern: "disk.*", Min: 0, Max: 100},
+		{Pattern: "temp", Min: -40, Max: math.Inf(1)},
+	}})
+	snaps := e.process([]MetricSnapshot{
+		NewGaugeSnapshot("disk.used", 150),
+		NewGaugeSnapshot("disk.free", -3),
+		NewGaugeSnapshot("temp", -300),
+		NewGaugeSnapshot("other", 1000),
+	})
+	for i, expected := range []float64{100, 0, -40, 1000} {
+		if found := snaps[i].Fields[0].Value; !floatEquals(found, expected) {
+			t.Fatal("bad value:", snaps[i].Name, expected, found)
+		}
+	}
+}
+
+func TestOutliers(t *testing.T) {
+	e := New(GraphiteConfig{Outliers: []OutlierRule{
+		{Pattern: "sensor", StdDevs: 3, MinSamples: 5},
+	}})
+	for i, v := range []int64{10, 11, 9, 10, 11, 9, 10} {
+		if snaps := e.process([]MetricSnapshot{NewGaugeSnapshot("sensor", v)}); len(snaps[0].Fields) != 1 {
+			t.Fatal("dropped regular value:", i, v)
+		}
+	}
+	if snaps := e.process([]MetricSnapshot{NewGaugeSnapshot("sensor", 1000)}); len(snaps[0].Fields) != 0 {
+		t.Fatal("outlier not dropped")
+	}
+	if snaps := e.process([]MetricSnapshot{NewGaugeSnapshot("sensor", 10)}); len(snaps[0].Fields) != 1 {
+		t.Fatal("dropped regular value")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, name string
+		expected      bool
+	}{
+		{"foo.*", "foo.bar", true},
+		{"foo.*", "foo.bar.baz", false},
+		{"foo.*.baz", "foo.bar.baz", true},
+		{"foo.[ab]*", "foo.bar", true},
+		{"foo", "foobar", false},
+	} {
+		if found := match(tc.pattern, tc.name); found != tc.expected {
+			t.Fatal("bad match:", tc.pattern, tc.name, found)
+		}
+	}
+}
+
+func TestNegativeCounters(t *testing.T) {
+	for _, tc := range []struct {
+		policy   NegativePolicy
+		fields   int
+		value    float64
+		negative int64
+	}{
+		{NegativeSend, 1, -5, 0},
+		{NegativeClamp, 1, 0, 0},
+		{NegativeDrop, 0, 0, 1},
+	} {
+		e := New(GraphiteConfig{NegativeCounters: tc.policy})
+		snaps := e.process([]MetricSnapshot{NewCounterSnapshot("foo", -5), NewGaugeSnapshot("bar", -5)})
+		if len(snaps[0].Fields) != tc.fields || (tc.fields > 0 && !floatEquals(snaps[0].Fields[0].Value, tc.value)) {
+			t.Fatal("bad counter:", tc.policy, snaps[0])
+		}
+		if !floatEquals(snaps[1].Fields[0].Value, -5) {
+			t.Fatal("gauge changed:", tc.policy, snaps[1])
+		}
+		if found := e.NegativeCounters(); found != tc.negative {
+			t.Fatal("bad count:", tc.negative, found)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package graphite
+
+// CollisionPolicy controls which value is sent when several datapoints of
+// a flush share a series and a timestamp, for instance a datapoint spooled
+// or left by a failed flush and the live one of the same second. Whisper
+// keeps whichever it receives last, which makes the result depend on the
+// order of the payload.
+type CollisionPolicy int
+
+// Policies for colliding datapoints. Datapoints are considered in the
+// order they were produced: queued ones first, oldest first, then those of
+// the flush.
+const (
+	CollisionSend      CollisionPolicy = iota // Send every datapoint as-is
+	CollisionKeepLast                         // Send the value produced last
+	CollisionKeepFirst                        // Send the value produced first
+	CollisionSum                              // Send the sum of the values
+)
+
+// collide resolves the collisions of dps according to policy, the first
+// live datapoints of dps being followed by the queued ones.
+func collide(policy CollisionPolicy, dps []datapoint, live int) []datapoint {
+	if CollisionSend == policy {
+		return dps
+	}
+	type key struct {
+		path      string
+		timestamp int64
+	}
+	index := make(map[key]int, len(dps))
+	resolved := make([]datapoint, 0, len(dps))
+	for _, dp := range append(dps[live:len(dps):len(dps)], dps[:live]...) {
+		k := key{dp.path, dp.timestamp}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(resolved)
+			resolved = append(resolved, dp)
+			continue
+		}
+		switch policy {
+		case CollisionKeepLast:
+			resolved[i] = dp
+		case CollisionSum:
+			resolved[i].value += dp.value
+			if resolved[i].precision >= 0 && (dp.precision < 0 || dp.precision > resolved[i].precision) {
+				resolved[i].precision = dp.precision
+			}
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,33 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestCollide(t *testing.T) {
+	live := []datapoint{{path: "a", value: 3, precision: 2, timestamp: 10}, {path: "b", value: 1, timestamp: 10}}
+	queued := []datapoint{{path: "a", value: 1, precision: -1, timestamp: 10}, {path: "a", value: 2, precision: -1, timestamp: 9}}
+	for _, tc := range []struct {
+		policy   CollisionPolicy
+		lines    int
+		expected float64
+	}{
+		{CollisionSend, 4, 0},
+		{CollisionKeepLast, 3, 3},
+		{CollisionKeepFirst, 3, 1},
+		{CollisionSum, 3, 4},
+	} {
+		dps := collide(tc.policy, append(append([]datapoint(nil), live...), queued...), len(live))
+		if tc.lines != len(dps) {
+			t.Fatal("bad datapoints:", tc.policy, dps)
+		}
+		for _, dp := range dps {
+			if CollisionSend != tc.policy && "a" == dp.path && 10 == dp.timestamp && !floatEquals(dp.value, tc.expected) {
+				t.Fatal("bad value:", tc.policy, tc.expected, dp)
+			}
+			if CollisionSum == tc.policy && "a" == dp.path && 10 == dp.timestamp && -1 != dp.precision {
+				t.Fatal("sum rounded:", dp)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package compat
+
+import (
+	"net"
+	"time"
+
+	"github.com/cyberdelia/go-metrics-graphite"
+	"github.com/rcrowley/go-metrics"
+)
+
+// GraphiteConfig provides a container with configuration parameters for
+// the Graphite exporter
+type GraphiteConfig struct {
+	Addr          *net.TCPAddr     // Network address to connect to
+	Registry      metrics.Registry // Registry to be exported
+	FlushInterval time.Duration    // Flush interval
+	DurationUnit  time.Duration    // Time conversion unit for durations
+	Prefix        string           // Prefix to be prepended to metric names
+	Percentiles   []float64        // Percentiles to export from timers and histograms
+}
+
+// Graphite is a blocking exporter function which reports metrics in r
+// to a graphite server located at addr, flushing them every d duration
+// and prepending metric names with prefix.
+func Graphite(r metrics.Registry, d time.Duration, prefix string, addr *net.TCPAddr) {
+	GraphiteWithConfig(GraphiteConfig{
+		Addr:          addr,
+		Registry:      r,
+		FlushInterval: d,
+		DurationUnit:  time.Nanosecond,
+		Prefix:        prefix,
+		Percentiles:   []float64{0.5, 0.75, 0.95, 0.99, 0.999},
+	})
+}
+
+// GraphiteWithConfig is a blocking exporter function just like Graphite,
+// but it takes a GraphiteConfig instead.
+func GraphiteWithConfig(c GraphiteConfig) {
+	graphite.GraphiteWithConfig(config(c))
+}
+
+// GraphiteOnce performs a single submission to Graphite, returning a
+// non-nil error on failed connections. This can be used in a loop
+// similar to GraphiteWithConfig for custom error handling.
+func GraphiteOnce(c GraphiteConfig) error {
+	return graphite.GraphiteOnce(config(c))
+}
+
+// config returns the configuration of the exporter of package graphite
+// equivalent to c, sending the series of the exporter formerly part of
+// github.com/rcrowley/go-metrics.
+func config(c GraphiteConfig) graphite.GraphiteConfig {
+	var addr string
+	if nil != c.Addr {
+		addr = c.Addr.String()
+	}
+	return graphite.GraphiteConfig{
+		Addr:          addr,
+		Registry:      c.Registry,
+		FlushInterval: c.FlushInterval,
+		DurationUnit:  c.DurationUnit,
+		Prefix:        c.Prefix,
+		Percentiles:   c.Percentiles,
+		SchemaVersion: graphite.SchemaGoMetrics,
+	}
+}
@@ -0,0 +1,114 @@
+package compat
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// receive returns the address of a server and a function returning the
+// values of the series it received by path, once the connection of the
+// exporter is closed.
+func receive(t *testing.T) (*net.TCPAddr, func() map[string]float64) {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	done := make(chan map[string]float64, 1)
+	go func() {
+		defer ln.Close()
+		series := make(map[string]float64)
+		defer func() { done <- series }()
+		conn, err := ln.Accept()
+		if nil != err {
+			return
+		}
+		defer conn.Close()
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if 3 != len(fields) {
+				t.Error("bad line:", s.Text())
+				continue
+			}
+			v, err := strconv.ParseFloat(fields[1], 64)
+			if nil != err {
+				t.Error("bad value:", s.Text())
+			}
+			series[fields[0]] = v
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr), func() map[string]float64 {
+		select {
+		case series := <-done:
+			return series
+		case <-time.After(5 * time.Second):
+			t.Fatal("nothing received")
+			return nil
+		}
+	}
+}
+
+func TestGraphiteOnce(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("counter", r).Inc(3)
+	metrics.GetOrRegisterGauge("gauge", r).Update(4)
+	metrics.GetOrRegisterGaugeFloat64("float", r).Update(1.5)
+	metrics.GetOrRegisterMeter("meter", r).Mark(5)
+	h := metrics.GetOrRegisterHistogram("histogram", r, metrics.NewUniformSample(10))
+	tm := metrics.GetOrRegisterTimer("timer", r)
+	for i := int64(1); i <= 4; i++ {
+		h.Update(i)
+		tm.Update(time.Duration(i))
+	}
+	c := GraphiteConfig{
+		Registry:      r,
+		FlushInterval: time.Second,
+		DurationUnit:  time.Nanosecond,
+		Prefix:        "app",
+		Percentiles:   []float64{0.5, 0.999},
+	}
+
+	addr, received := receive(t)
+	upstreamConfig := metrics.GraphiteConfig(c)
+	upstreamConfig.Addr = addr
+	if err := metrics.GraphiteOnce(upstreamConfig); nil != err {
+		t.Fatal(err)
+	}
+	upstream := received()
+
+	c.Addr, received = receive(t)
+	if err := GraphiteOnce(c); nil != err {
+		t.Fatal(err)
+	}
+	found := received()
+
+	if expected, paths := sortedPaths(upstream), sortedPaths(found); !reflect.DeepEqual(expected, paths) {
+		t.Fatal("series differ from upstream:", expected, paths)
+	}
+	for path, v := range upstream {
+		// Rates depend on the time at which the registry was walked.
+		if strings.HasSuffix(path, "-minute") || strings.HasSuffix(path, "meter.mean") || strings.HasSuffix(path, ".mean-rate") {
+			continue
+		}
+		if d := v - found[path]; d > 0.01 || d < -0.01 {
+			t.Error("value differs from upstream:", path, v, found[path])
+		}
+	}
+}
+
+func sortedPaths(series map[string]float64) []string {
+	paths := make([]string, 0, len(series))
+	for path := range series {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,12 @@
+// Package compat mirrors the API and the series of the Graphite exporter
+// formerly part of github.com/rcrowley/go-metrics. Functions and types have
+// the same names and signatures and are backed by the exporter of
+// github.com/cyberdelia/go-metrics-graphite, which sends the same series
+// with graphite.SchemaGoMetrics, so that projects migrate by importing this
+// package under the name they used:
+//
+//	import graphite "github.com/cyberdelia/go-metrics-graphite/compat"
+//
+// Code written for github.com/cyberdelia/go-metrics-graphite itself keeps
+// its series by using the root package, whose API is unchanged.
+package compat
@@ -0,0 +1,67 @@
+package graphite
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestConcurrentUse(t *testing.T) {
+	_, l, r, c, _ := NewTestServer(t, "foobar")
+	l.Close()
+	ln := NewDiscardServer(t)
+	defer ln.Close()
+	c.Addr = ln.Addr().String()
+	c.FlushInterval = 5 * time.Millisecond
+	c.CounterDeltas = true
+	c.ReportSeriesCounts = true
+	c.HistorySize = 4
+	c.CapturePayloads = 2
+	c.OrderedDelivery = true
+	e := New(c)
+	counter := metrics.GetOrRegisterCounter("foo", r)
+	e.Start()
+
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				f(i)
+			}
+		}()
+	}
+	run(func(i int) { counter.Inc(1) })
+	run(func(i int) { e.Send("marker", float64(i), time.Now()) })
+	run(func(int) { e.Flush() })
+	run(func(int) { e.FlushMetric("foo") })
+	run(func(int) { e.Burst([]string{"foo"}, time.Millisecond, 2*time.Millisecond) })
+	run(func(int) {
+		e.SeriesCounts()
+		e.History()
+		e.Memory()
+		e.DestinationStats()
+		e.Throughput()
+		e.DumpState(io.Discard)
+		e.SupportBundle(io.Discard)
+	})
+	wg.Wait()
+	e.Stop()
+	if n := e.Goroutines(); 0 != n {
+		t.Fatal("goroutines left after Stop:", n)
+	}
+}
+
+func TestConfigCopied(t *testing.T) {
+	c := GraphiteConfig{Percentiles: []float64{0.5}, Tags: map[string]string{"env": "prod"}}
+	e := New(c)
+	c.Percentiles[0] = 0.9
+	c.Tags["env"] = "dev"
+	if !floatEquals(0.5, e.c.Percentiles[0]) || "prod" != e.c.Tags["env"] {
+		t.Fatal("configuration shared with the caller:", e.c.Percentiles, e.c.Tags)
+	}
+}
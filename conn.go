@@ -0,0 +1,423 @@
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DialFunc dials addr of network until ctx is done, which is at most five
+// seconds. Custom dialers connect through proxies, to in-memory pipes in
+// tests or return instrumented connections.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// connect opens a connection to the Graphite server and returns it along
+// with the address dialed, the last one tried if every attempt failed. The
+// endpoints of c.Addrs are tried in turn, from the last healthy one, or
+// those of c.SRV in order of preference.
+func (e *Exporter) connect(ctx context.Context) (net.Conn, string, error) {
+	if "" != e.c.HTTPURL {
+		return e.dialHTTP(ctx)
+	}
+	dial := e.c.DialFunc
+	if nil == dial {
+		d, err := e.dialer()
+		if nil != err {
+			return nil, e.c.Addr, err
+		}
+		dial = d.DialContext
+		if e.c.LocalPortMin > 0 {
+			dial = func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return e.dialPortRange(ctx, d, addr)
+			}
+		}
+	}
+	eps, first, err := e.candidates(ctx)
+	if nil != err {
+		return nil, e.c.SRV, err
+	}
+	var conn net.Conn
+	var addr string
+	for i := range eps {
+		n := (first + i) % len(eps)
+		if conn, addr, err = e.dialEndpoint(ctx, dial, eps[n]); nil == err {
+			e.mu.Lock()
+			e.healthy = n
+			e.mu.Unlock()
+			break
+		}
+	}
+	if nil != err {
+		return nil, addr, err
+	}
+	if datagram(network(&e.c)) {
+		conn = datagramConn{conn}
+	}
+	if nil != e.c.OnConnect {
+		e.c.OnConnect(e.Endpoint())
+	}
+	return conn, addr, nil
+}
+
+// dialEndpoint opens a connection to endpoint with dial and returns it
+// along with the address dialed, the last one tried if every attempt
+// failed.
+func (e *Exporter) dialEndpoint(ctx context.Context, dial DialFunc, endpoint string) (net.Conn, string, error) {
+	addrs, err := e.resolve(ctx, endpoint)
+	if nil != err {
+		return nil, endpoint, err
+	}
+	var conn net.Conn
+	var addr string
+	for _, addr = range addrs {
+		dctx, cancel := context.WithTimeout(ctx, dialTimeout(&e.c))
+		conn, err = dial(dctx, network(&e.c), addr)
+		cancel()
+		if nil == err {
+			break
+		}
+	}
+	if nil != err {
+		return nil, addr, err
+	}
+	if conn, err = e.secure(ctx, conn, endpoint); nil != err {
+		return nil, addr, err
+	}
+	return conn, addr, nil
+}
+
+// acquire returns the connection of a flush along with the address dialed.
+// With c.KeepAlive it is the connection kept open by the previous flush,
+// unless the server closed it, and e.connMu is held until release so that
+// flushes and probes do not interleave their writes.
+func (e *Exporter) acquire(ctx context.Context) (net.Conn, string, error) {
+	if !keepAlive(&e.c) {
+		return e.connect(ctx)
+	}
+	e.connMu.Lock()
+	if nil != e.conn {
+		err := closed(e.conn)
+		if nil == err && e.moved(ctx, e.conn) {
+			err = errMoved
+		}
+		if nil == err {
+			return e.conn, e.connAddr, nil
+		}
+		e.disconnect(e.conn, err)
+		e.conn = nil
+	}
+	conn, addr, err := e.connect(ctx)
+	if nil != err {
+		e.connMu.Unlock()
+		return nil, addr, err
+	}
+	e.conn, e.connAddr = conn, addr
+	e.mu.Lock()
+	e.resolved = time.Now()
+	e.mu.Unlock()
+	return conn, addr, nil
+}
+
+// release ends the use of conn by a flush which failed with err, if any,
+// conn being nil if the flush failed to reconnect. Connections are closed
+// unless c.KeepAlive is set and the flush succeeded.
+func (e *Exporter) release(conn net.Conn, err error) {
+	if !keepAlive(&e.c) {
+		if nil != conn {
+			e.disconnect(conn, err)
+		}
+		return
+	}
+	defer e.connMu.Unlock()
+	if nil == conn {
+		return
+	}
+	if nil != err {
+		e.disconnect(conn, err)
+		e.conn = nil
+		return
+	}
+	// Clears the deadline set when the context of the flush is done.
+	conn.SetDeadline(time.Time{})
+}
+
+// keepAlive returns true if connections are kept open between flushes,
+// which requests to c.HTTPURL never are.
+func keepAlive(c *GraphiteConfig) bool {
+	return c.KeepAlive && "" == c.HTTPURL
+}
+
+// closeConn closes the connection kept open between flushes, if any.
+func (e *Exporter) closeConn() {
+	e.connMu.Lock()
+	defer e.connMu.Unlock()
+	if nil != e.conn {
+		e.disconnect(e.conn, nil)
+		e.conn = nil
+	}
+}
+
+// closed returns the error reading from conn if the server closed it.
+// Graphite servers never write to their clients, so a read which does not
+// time out means the connection is no longer usable. The deadline is in the
+// future since reads past their deadline fail without reading.
+func closed(conn net.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return nil
+	}
+	if nil == err {
+		return errors.New("graphite: unexpected data from server")
+	}
+	return err
+}
+
+// disconnect closes conn, err being the error which caused it, if any.
+func (e *Exporter) disconnect(conn net.Conn, err error) {
+	conn.Close()
+	if nil != e.c.OnDisconnect {
+		e.c.OnDisconnect(e.Endpoint(), err)
+	}
+}
+
+// dialTimeout returns the time a dial may take, 5 seconds by default.
+func dialTimeout(c *GraphiteConfig) time.Duration {
+	if c.DialTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return c.DialTimeout
+}
+
+// dialer returns the dialer used to connect to the Graphite server.
+func (e *Exporter) dialer() (*net.Dialer, error) {
+	d := &net.Dialer{Timeout: dialTimeout(&e.c)}
+	tcpKeepAlive(d, e.c.TCPKeepAlive)
+	local, err := localAddr(&e.c, -1)
+	if nil != err {
+		return nil, err
+	}
+	d.LocalAddr = local
+	if "" != e.c.BindToDevice {
+		d.Control = bindToDevice(e.c.BindToDevice)
+	}
+	return d, nil
+}
+
+// dialPortRange dials from the first available port of the local port
+// range, starting after the last port used so that successive connections
+// do not wait for the previous one to leave TIME_WAIT.
+func (e *Exporter) dialPortRange(ctx context.Context, d *net.Dialer, addr string) (net.Conn, error) {
+	min, max := e.c.LocalPortMin, e.c.LocalPortMax
+	if max < min {
+		max = min
+	}
+	n := max - min + 1
+	e.mu.Lock()
+	start := e.port
+	e.port = (e.port + 1) % n
+	e.mu.Unlock()
+
+	var err error
+	for i := 0; i < n; i++ {
+		port := min + (start+i)%n
+		if d.LocalAddr, err = localAddr(&e.c, port); nil != err {
+			return nil, err
+		}
+		var conn net.Conn
+		if conn, err = d.DialContext(ctx, network(&e.c), addr); nil == err {
+			return conn, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("graphite: no local port available in [%d, %d]: %v", min, max, err)
+}
+
+// network returns the network of c.Addr: the one of its scheme, if any,
+// c.Network or "tcp".
+func network(c *GraphiteConfig) string {
+	if n, _, ok := unixAddr(c.Addr); ok {
+		return n
+	}
+	if "" == c.Network {
+		return "tcp"
+	}
+	return c.Network
+}
+
+// unixAddr splits addresses such as "unix:/var/run/carbon.sock" or
+// "unixgram:///var/run/carbon.sock" into their network and path.
+func unixAddr(addr string) (string, string, bool) {
+	for _, n := range []string{"unix", "unixgram"} {
+		if strings.HasPrefix(addr, n+":") {
+			return n, strings.TrimPrefix(addr[len(n)+1:], "//"), true
+		}
+	}
+	return "", "", false
+}
+
+// datagram returns true if n is a datagram network.
+func datagram(n string) bool {
+	return strings.HasPrefix(n, "udp") || "unixgram" == n
+}
+
+// datagramConn is a connection of a datagram network whose writes do not
+// fail because a previous datagram was refused, which the system reports
+// on the next write, so that datagrams are sent fire-and-forget: a flush
+// never fails nor blocks because the server is down or slow.
+type datagramConn struct {
+	net.Conn
+}
+
+func (c datagramConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		// Reporting the error cleared it, b was not sent yet.
+		if n, err = c.Conn.Write(b); errors.Is(err, syscall.ECONNREFUSED) {
+			return len(b), nil
+		}
+	}
+	return n, err
+}
+
+// maxDatagram is the size of the largest datagram written, that of the
+// payload of a UDP datagram which is not fragmented on an Ethernet link.
+const maxDatagram = 1472
+
+// maxChunk is the size of the largest write on stream networks.
+const maxChunk = 64 << 10
+
+// writeLines writes the lines of b to conn in writes of at most max bytes,
+// split on line boundaries so that receivers of datagrams parse every
+// datagram on its own, and so that the lines written before a failure are
+// known. Lines longer than max are written alone.
+func writeLines(conn net.Conn, b []byte, max int) (int, error) {
+	var written int
+	for 0 != len(b) {
+		n := len(b)
+		if n > max {
+			n = bytes.LastIndexByte(b[:max], '\n') + 1
+			if 0 == n {
+				n = bytes.IndexByte(b, '\n') + 1
+			}
+			if 0 == n {
+				n = len(b)
+			}
+		}
+		m, err := conn.Write(b[:n])
+		written += m
+		if nil != err {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// write writes dps, the datapoints of the flush started at t, to conn in
+// the protocol of c.Protocol. It returns the bytes written and the number
+// of datapoints written in full, the first ones of dps.
+func (e *Exporter) write(conn net.Conn, t time.Time, dps []datapoint) (int, int, error) {
+	c := &e.c
+	// Payloads are captured as plaintext without API keys for support
+	// bundles, whatever the protocol.
+	var captured []byte
+	if c.CapturePayloads > 0 {
+		buf := bytes.NewBufferString("")
+		encodeLines(buf, dps, lineEnding(c))
+		captured = buf.Bytes()
+		e.capture(t, captured)
+	}
+	dps = withAPIKeys(c, dps)
+	if ProtocolPickle == c.Protocol {
+		if datagram(network(c)) {
+			return 0, 0, errors.New("graphite: the pickle protocol requires a stream network")
+		}
+		return writePickles(conn, dps)
+	}
+	max := maxChunk
+	if datagram(network(c)) {
+		max = maxDatagram
+	}
+	if c.Framed && "" == c.HTTPURL {
+		conn, max = framedConn{Conn: conn, e: e}, max-frameHeader
+	}
+	if nil == captured || hasAPIKeys(c) {
+		return writePipelined(conn, dps, max, lineEnding(c))
+	}
+	n, err := writeLines(conn, captured, max)
+	if nil != err {
+		return n, bytes.Count(captured[:n], []byte{'\n'}), err
+	}
+	return n, len(dps), nil
+}
+
+// localAddr returns the local address connections originate from according
+// to c.LocalAddr and c.Interface, or nil to let the system choose. A
+// non-negative port overrides the port of c.LocalAddr.
+func localAddr(c *GraphiteConfig, p int) (net.Addr, error) {
+	n := network(c)
+	host, port := c.LocalAddr, "0"
+	if h, p, err := net.SplitHostPort(c.LocalAddr); nil == err {
+		host, port = h, p
+	}
+	if p >= 0 {
+		port = strconv.Itoa(p)
+	}
+	if "" == host && "" != c.Interface {
+		ip, err := interfaceIP(c.Interface, n)
+		if nil != err {
+			return nil, err
+		}
+		host = ip.String()
+	}
+	if "" == host && "0" == port {
+		return nil, nil
+	}
+	addr := net.JoinHostPort(host, port)
+	if strings.HasPrefix(n, "udp") {
+		return net.ResolveUDPAddr(n, addr)
+	}
+	return net.ResolveTCPAddr(n, addr)
+}
+
+// interfaceIP returns the first address of the interface called name which
+// can be used on network n.
+func interfaceIP(name, n string) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if nil != err {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if nil != err {
+		return nil, err
+	}
+	var v6 net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip4 := ipnet.IP.To4(); nil != ip4 {
+			if !strings.HasSuffix(n, "6") {
+				return ip4, nil
+			}
+		} else if nil == v6 && !strings.HasSuffix(n, "4") {
+			v6 = ipnet.IP
+		}
+	}
+	if nil != v6 {
+		return v6, nil
+	}
+	return nil, fmt.Errorf("graphite: interface %s has no address usable with %s", name, n)
+}
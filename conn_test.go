@@ -0,0 +1,447 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestConnectionHooks(t *testing.T) {
+	_, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+
+	var connected, disconnected []string
+	c.OnConnect = func(addr string) { connected = append(connected, addr) }
+	c.OnDisconnect = func(addr string, err error) {
+		if err != nil {
+			t.Error("unexpected error:", err)
+		}
+		disconnected = append(disconnected, addr)
+	}
+
+	wg.Add(1)
+	if err := GraphiteOnce(c); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if len(connected) != 1 || connected[0] != c.Addr || len(disconnected) != 1 || disconnected[0] != c.Addr {
+		t.Fatal("bad hooks calls:", connected, disconnected)
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	for _, tc := range []struct {
+		network string
+		ok      bool
+	}{
+		{"", true},
+		{"tcp4", true},
+		{"tcp6", false},
+	} {
+		_, l, _, c, wg := NewTestServer(t, "foobar")
+		c.Network = tc.network
+		c.LocalAddr = "127.0.0.1"
+		if tc.ok {
+			wg.Add(1)
+		}
+		err := GraphiteOnce(c)
+		if tc.ok {
+			wg.Wait()
+		}
+		l.Close()
+		if (err == nil) != tc.ok {
+			t.Fatal("bad outcome:", tc.network, err)
+		}
+	}
+}
+
+func TestDatagrams(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	e := New(GraphiteConfig{Addr: pc.LocalAddr().String(), Network: "udp", Registry: metrics.NewRegistry(), Prefix: "foobar"})
+	for i := 0; i < 1000; i++ {
+		e.Send(fmt.Sprintf("foo.%d", i), 1, time.Now())
+	}
+
+	lines := make(chan int)
+	go func() {
+		b := make([]byte, 1<<16)
+		n := 0
+		for n < 1000 {
+			pc.SetReadDeadline(time.Now().Add(time.Second))
+			m, _, err := pc.ReadFrom(b)
+			if err != nil {
+				break
+			}
+			if m > maxDatagram || '\n' != b[m-1] {
+				t.Error("bad datagram:", m, string(b[:m]))
+			}
+			n += strings.Count(string(b[:m]), "\n")
+		}
+		lines <- n
+	}()
+	if err := e.Flush(); nil != err {
+		t.Fatal(err)
+	}
+	if n := <-lines; 1000 != n {
+		t.Fatal("lines lost:", n)
+	}
+}
+
+func TestInterfaceIP(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		ip, err := interfaceIP(iface.Name, "tcp4")
+		if err != nil {
+			t.Skip(err)
+		}
+		if !ip.IsLoopback() || ip.To4() == nil {
+			t.Fatal("bad address:", ip)
+		}
+		return
+	}
+	t.Skip("no loopback interface")
+}
+
+func TestLocalPortRange(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	ports := make(chan int, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			ports <- conn.RemoteAddr().(*net.TCPAddr).Port
+			conn.Close()
+		}
+	}()
+
+	// Use a port known to be free, and the next one, as the range.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	min := l.Addr().(*net.TCPAddr).Port
+	max := min + 1
+	l.Close()
+
+	e := New(GraphiteConfig{Addr: ln.Addr().String(), LocalAddr: "127.0.0.1", LocalPortMin: min, LocalPortMax: max})
+	for i := 0; i < 2; i++ {
+		conn, _, err := e.connect(context.Background())
+		if err != nil {
+			t.Skip("port range not available:", err)
+		}
+		conn.Close()
+		if port := <-ports; port < min || port > max {
+			t.Fatal("bad local port:", port, min, max)
+		}
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conns := make(chan net.Conn, 2)
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					lines <- line
+				}
+			}()
+		}
+	}()
+
+	var dials int
+	e := New(GraphiteConfig{
+		Addr:      ln.Addr().String(),
+		Registry:  metrics.NewRegistry(),
+		Prefix:    "foobar",
+		KeepAlive: true,
+		OnConnect: func(string) { dials++ },
+	})
+	defer e.Stop()
+	for i := 0; i < 3; i++ {
+		e.Send("foo", 1, time.Now())
+		if err := e.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		<-lines
+	}
+	if 1 != dials {
+		t.Fatal("connection not kept open:", dials)
+	}
+
+	// Connections closed by the server are dialed again.
+	(<-conns).Close()
+	time.Sleep(10 * time.Millisecond)
+	e.Send("foo", 1, time.Now())
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	<-lines
+	if 2 != dials {
+		t.Fatal("closed connection not dialed again:", dials)
+	}
+}
+
+// shortConn is a connection kept open whose writes fail after limit bytes.
+type shortConn struct {
+	net.Conn
+	limit int
+}
+
+func (c *shortConn) Write(b []byte) (int, error) {
+	if len(b) > c.limit {
+		n := c.limit
+		c.limit = 0
+		return n, errors.New("connection reset")
+	}
+	c.limit -= len(b)
+	return len(b), nil
+}
+
+func (c *shortConn) Read([]byte) (int, error)         { return 0, os.ErrDeadlineExceeded }
+func (c *shortConn) SetDeadline(time.Time) error      { return nil }
+func (c *shortConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *shortConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *shortConn) Close() error                     { return nil }
+
+func TestPartialWrite(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	e := New(c)
+	ts := time.Unix(1700000000, 0)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		e.Send(name, 1, ts)
+	}
+
+	// Two lines and a half are written before the connection fails.
+	e.c.KeepAlive = true
+	line := len("foobar.a 1 1700000000\n")
+	e.conn = &shortConn{limit: 2*line + line/2}
+	if err := e.Flush(); err == nil {
+		t.Fatal("expected an error")
+	}
+	if 2 != len(e.queue) || "foobar.c" != e.queue[0].path {
+		t.Fatal("bad remainder:", e.queue)
+	}
+
+	e.c.KeepAlive = false
+	wg.Add(1)
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if 2 != len(res) || 1 != res["foobar.c"] || 1 != res["foobar.d"] {
+		t.Fatal("written lines sent again or remainder lost:", res)
+	}
+}
+
+func TestDatagramsUnreachable(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc.Close()
+	for _, keepAlive := range []bool{false, true} {
+		e := New(GraphiteConfig{Addr: pc.LocalAddr().String(), Network: "udp", Registry: metrics.NewRegistry(), Prefix: "foobar", KeepAlive: keepAlive})
+		for i := 0; i < 3; i++ {
+			// Enough lines for several datagrams, the first of which is
+			// refused before the next ones are written.
+			for j := 0; j < 500; j++ {
+				e.Send(fmt.Sprintf("foo.%d", j), 1, time.Now())
+			}
+			if err := e.Flush(); nil != err {
+				t.Fatal("datagram flush failed:", keepAlive, i, err)
+			}
+		}
+		e.Stop()
+	}
+}
+
+func TestUnixSockets(t *testing.T) {
+	dir := t.TempDir()
+	stream := filepath.Join(dir, "carbon.sock")
+	ln, err := net.Listen("unix", stream)
+	if err != nil {
+		t.Skip("unix sockets not supported:", err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+	}()
+	dgram := filepath.Join(dir, "carbon.dgram")
+	pc, err := net.ListenPacket("unixgram", dgram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	for _, c := range []GraphiteConfig{
+		{Addr: "unix://" + stream},
+		{Addr: "unixgram:" + dgram},
+		{Addr: dgram, Network: "unixgram"},
+	} {
+		c.Registry = metrics.NewRegistry()
+		c.Prefix = "foobar"
+		e := New(c)
+		e.Send("foo", 1, time.Unix(1700000000, 0))
+		if err := e.Flush(); err != nil {
+			t.Fatal(c.Addr, err)
+		}
+		var line string
+		if datagram(network(&c)) {
+			b := make([]byte, maxDatagram)
+			pc.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := pc.ReadFrom(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = string(b[:n])
+		} else {
+			line = <-lines
+		}
+		if "foobar.foo 1 1700000000\n" != line {
+			t.Fatal("bad line:", c.Addr, line)
+		}
+	}
+}
+
+func TestDialFunc(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(server).ReadString('\n')
+		lines <- line
+	}()
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	var dialed string
+	e := New(GraphiteConfig{
+		Addr:          "carbon:2003",
+		Interface:     "no-such-interface",
+		Registry:      r,
+		FlushInterval: time.Second,
+		Prefix:        "p",
+		DialFunc: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("dial without deadline")
+			}
+			dialed = network + " " + addr
+			return client, nil
+		},
+	})
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if "tcp carbon:2003" != dialed {
+		t.Fatal("bad dial:", dialed)
+	}
+	if line := <-lines; !strings.HasPrefix(line, "p.foo 1 ") {
+		t.Fatal("bad line:", line)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		timeout, expected time.Duration
+	}{
+		{0, 5 * time.Second},
+		{50 * time.Millisecond, 50 * time.Millisecond},
+		{time.Minute, time.Minute},
+	} {
+		var found time.Duration
+		e := New(GraphiteConfig{
+			Addr:          "carbon:2003",
+			Registry:      metrics.NewRegistry(),
+			FlushInterval: time.Second,
+			DialTimeout:   tc.timeout,
+			DialFunc: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				deadline, _ := ctx.Deadline()
+				found = time.Until(deadline)
+				return nil, errors.New("unreachable")
+			},
+		})
+		if err := e.flush().Err; nil == err {
+			t.Fatal("dial did not fail")
+		}
+		if found > tc.expected || found < tc.expected-time.Second/4 {
+			t.Error("bad dial timeout:", tc.timeout, found)
+		}
+		if d, _ := e.dialer(); tc.expected != d.Timeout {
+			t.Error("bad dialer timeout:", tc.timeout, d.Timeout)
+		}
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	e := New(GraphiteConfig{
+		Addr:          "carbon:2003",
+		Registry:      metrics.NewRegistry(),
+		FlushInterval: time.Second,
+		WriteTimeout:  50 * time.Millisecond,
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			return client, nil
+		},
+	})
+	e.Send("foo", 1, time.Now())
+	start := time.Now()
+	err := e.flush().Err
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Fatal("stalled write did not time out:", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatal("write timed out late:", elapsed)
+	}
+	if 1 != len(e.queue) {
+		t.Fatal("datapoint not queued again:", e.queue)
+	}
+}
@@ -0,0 +1,64 @@
+package graphite
+
+// counter records the value v of counter name as its baseline and returns the
+// value to send along with whether the counter was reset, that is whether
+// it decreased since the previous flush because of a process restart or a
+// rollover. With CounterDeltas the increase since the previous flush is
+// returned; after a reset the counter is assumed to have restarted from
+// zero.
+func (e *Exporter) counter(name string, v float64) (float64, bool) {
+	if !e.c.CounterDeltas && !e.c.AnnotateCounterResets {
+		return v, false
+	}
+	previous, ok := e.baselines[name]
+	e.baselines[name] = v
+	reset := ok && v < previous
+	if !e.c.CounterDeltas {
+		return v, reset
+	}
+	if !ok || reset {
+		return v, reset
+	}
+	return v - previous, false
+}
+
+// checkpoint returns a function restoring the baselines of the counters of
+// snaps, and the idle counts of its counters and meters, to their current
+// values. They are restored when the flush computed from them is not
+// delivered, so that the next flush sends the deltas and counts again
+// instead of losing them.
+func (e *Exporter) checkpoint(snaps []MetricSnapshot) func() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	type baseline struct {
+		value float64
+		ok    bool
+	}
+	saved := make(map[string]baseline)
+	counts := make(map[string]baseline)
+	for _, s := range snaps {
+		if TypeCounter == s.Type {
+			v, ok := e.baselines[s.Name]
+			saved[s.Name] = baseline{v, ok}
+		}
+		if TypeCounter == s.Type || TypeMeter == s.Type {
+			v, ok := e.counts[s.Name]
+			counts[s.Name] = baseline{v, ok}
+		}
+	}
+	restore := func(m map[string]float64, saved map[string]baseline) {
+		for name, b := range saved {
+			if b.ok {
+				m[name] = b.value
+			} else {
+				delete(m, name)
+			}
+		}
+	}
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		restore(e.baselines, saved)
+		restore(e.counts, counts)
+	}
+}
@@ -0,0 +1,121 @@
+package graphite
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestCounterDeltas(t *testing.T) {
+	e := New(GraphiteConfig{CounterDeltas: true, AnnotateCounterResets: true})
+	for i, tc := range []struct {
+		count    int64
+		expected float64
+		reset    bool
+	}{
+		{10, 10, false},
+		{15, 5, false},
+		{15, 0, false},
+		{3, 3, true},
+		{7, 4, false},
+	} {
+		snaps := e.process([]MetricSnapshot{NewCounterSnapshot("foo", tc.count)})
+		if found := snaps[0].Fields[0].Value; !floatEquals(found, tc.expected) {
+			t.Fatal("bad delta:", i, tc.expected, found)
+		}
+		if reset := len(snaps[0].Fields) == 2 && snaps[0].Fields[1].Name == "reset"; reset != tc.reset {
+			t.Fatal("bad reset annotation:", i, snaps[0].Fields)
+		}
+	}
+}
+
+func TestCounterResetsCumulative(t *testing.T) {
+	e := New(GraphiteConfig{AnnotateCounterResets: true})
+	e.process([]MetricSnapshot{NewCounterSnapshot("foo", 10)})
+	snaps := e.process([]MetricSnapshot{NewCounterSnapshot("foo", 2)})
+	if len(snaps[0].Fields) != 2 || !floatEquals(snaps[0].Fields[0].Value, 2) {
+		t.Fatal("bad fields:", snaps[0].Fields)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	e := New(GraphiteConfig{
+		FlushInterval: time.Second,
+		CounterDeltas: true,
+		Outliers:      []OutlierRule{{Pattern: "*.max", StdDevs: 3}},
+		Thresholds:    []ThresholdRule{{Pattern: "*.max", Value: 1}},
+	})
+	c := &e.c
+	c.Percentiles = nil
+	snaps := func(names ...string) []MetricSnapshot {
+		s := make([]MetricSnapshot, 0)
+		for _, name := range names {
+			s = append(s, NewCounterSnapshot(name, 1), NewHistogramSnapshot(c, name+"h", []int64{5}))
+		}
+		return s
+	}
+	all := snaps("a", "b")
+	e.process(all)
+	e.crossings(all)
+	e.delivered([]datapoint{{path: "relayed", timestamp: 1}})
+	if 2 != len(e.baselines) || 2 != len(e.outliers) || 2 != len(e.crossed) {
+		t.Fatal("state not recorded:", e.baselines, e.outliers, e.crossed)
+	}
+
+	e.prune(snaps("a"))
+	if _, ok := e.baselines["b"]; ok || 1 != len(e.baselines) {
+		t.Fatal("baselines not pruned:", e.baselines)
+	}
+	if _, ok := e.outliers["bh.max"]; ok || 1 != len(e.outliers) {
+		t.Fatal("outliers not pruned:", e.outliers)
+	}
+	if 1 != len(e.crossed) {
+		t.Fatal("thresholds not pruned:", e.crossed)
+	}
+	if 1 != len(e.latest) {
+		t.Fatal("recent delivery pruned:", e.latest)
+	}
+	e.latest["relayed"] = delivery{timestamp: 1, at: time.Now().Add(-time.Hour)}
+	e.prune(snaps("a"))
+	if 0 != len(e.latest) {
+		t.Fatal("deliveries not pruned:", e.latest)
+	}
+}
+
+func TestCounterDeltasUndelivered(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	c.CounterDeltas = true
+	c.OnConnect = func(string) { cancel() }
+	e := New(c)
+	metrics.GetOrRegisterCounter("foo", r).Inc(10)
+
+	wg.Add(1)
+	if err := e.flushContext(ctx, nil).Err; nil == err {
+		t.Fatal("write did not fail")
+	}
+	wg.Wait()
+
+	e.c.Strict = true
+	bad := metrics.GetOrRegisterGaugeFloat64("bad", r)
+	bad.Update(math.NaN())
+	wg.Add(1)
+	if err := e.flush().Err; nil == err {
+		t.Fatal("invalid flush did not fail")
+	}
+	wg.Wait()
+
+	bad.Update(1)
+	wg.Add(1)
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if expected, found := 10.0, res["foobar.foo"]; !floatEquals(found, expected) {
+		t.Fatal("delta lost by undelivered flushes:", expected, found)
+	}
+}
@@ -0,0 +1,138 @@
+package graphite
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// grafanaDashboard is the subset of the Grafana dashboard model produced
+// by GrafanaDashboard.
+type grafanaDashboard struct {
+	Title         string         `json:"title"`
+	Tags          []string       `json:"tags"`
+	SchemaVersion int            `json:"schemaVersion"`
+	Time          grafanaRange   `json:"time"`
+	Panels        []grafanaPanel `json:"panels"`
+}
+
+type grafanaRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaPanel struct {
+	ID          int             `json:"id"`
+	Title       string          `json:"title"`
+	Type        string          `json:"type"`
+	Datasource  grafanaSource   `json:"datasource"`
+	GridPos     grafanaGridPos  `json:"gridPos"`
+	Targets     []grafanaTarget `json:"targets"`
+	FieldConfig grafanaFields   `json:"fieldConfig"`
+}
+
+type grafanaSource struct {
+	Type string `json:"type"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	RefID  string `json:"refId"`
+	Target string `json:"target"`
+}
+
+type grafanaFields struct {
+	Defaults grafanaDefaults `json:"defaults"`
+}
+
+type grafanaDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+// GrafanaDashboard returns a Grafana dashboard, encoded as JSON, with a
+// panel for every metric of c.Registry querying a Graphite datasource:
+// counters as their rate of increase, gauges as is, the rates of meters
+// and the mean and percentiles of timers and histograms. It is meant to
+// bootstrap the dashboard of a new service rather than to be maintained.
+func GrafanaDashboard(c *GraphiteConfig, title string) ([]byte, error) {
+	d := grafanaDashboard{
+		Title:         title,
+		Tags:          []string{"graphite"},
+		SchemaVersion: 39,
+		Time:          grafanaRange{From: "now-6h", To: "now"},
+		Panels:        make([]grafanaPanel, 0),
+	}
+	for _, s := range Snapshot(c) {
+		n := len(d.Panels)
+		p := grafanaPanel{
+			ID:         n + 1,
+			Title:      s.Name,
+			Type:       "timeseries",
+			Datasource: grafanaSource{Type: "graphite"},
+			GridPos:    grafanaGridPos{H: 8, W: 12, X: 12 * (n % 2), Y: 8 * (n / 2)},
+		}
+		for _, f := range s.Fields {
+			if target, ok := panelTarget(c, s, f); ok {
+				p.Targets = append(p.Targets, grafanaTarget{RefID: refID(len(p.Targets)), Target: target})
+			}
+		}
+		if TypeTimer == s.Type {
+			p.FieldConfig.Defaults.Unit = durationUnit(c.DurationUnit)
+		}
+		d.Panels = append(d.Panels, p)
+	}
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// panelTarget returns the query graphing field f of metric s, if it is
+// worth graphing.
+func panelTarget(c *GraphiteConfig, s MetricSnapshot, f Field) (string, bool) {
+	name := seriesName(c, s, f)
+	switch s.Type {
+	case TypeCounter:
+		if c.CounterDeltas {
+			return name, true
+		}
+		return "perSecond(" + name + ")", true
+	case TypeGauge, TypeGaugeFloat64:
+		return name, true
+	case TypeMeter:
+		return name, strings.HasSuffix(f.Name, "-minute")
+	case TypeHistogram, TypeTimer:
+		return name, "mean" == f.Name || strings.HasSuffix(f.Name, "-percentile") || strings.HasSuffix(f.Name, "-precentile")
+	}
+	return "", false
+}
+
+// refID returns the Grafana identifier of the i-th query of a panel.
+func refID(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return refID(i/26-1) + refID(i%26)
+}
+
+// durationUnit returns the Grafana unit of durations expressed in d.
+func durationUnit(d time.Duration) string {
+	switch d {
+	case time.Nanosecond:
+		return "ns"
+	case time.Microsecond:
+		return "µs"
+	case time.Millisecond:
+		return "ms"
+	case time.Second:
+		return "s"
+	case time.Minute:
+		return "m"
+	case time.Hour:
+		return "h"
+	}
+	return ""
+}
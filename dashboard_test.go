@@ -0,0 +1,64 @@
+package graphite
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestGrafanaDashboard(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests", r).Inc(1)
+	metrics.GetOrRegisterTimer("latency", r).Update(time.Millisecond)
+	metrics.GetOrRegisterMeter("errors", r).Mark(1)
+
+	b, err := GrafanaDashboard(&GraphiteConfig{
+		Registry:     r,
+		DurationUnit: time.Millisecond,
+		Prefix:       "app",
+		Percentiles:  []float64{0.99},
+	}, "app")
+	if nil != err {
+		t.Fatal(err)
+	}
+	var d grafanaDashboard
+	if err := json.Unmarshal(b, &d); nil != err {
+		t.Fatal(err)
+	}
+	if 3 != len(d.Panels) {
+		t.Fatal("bad panels:", d.Panels)
+	}
+	targets := func(p grafanaPanel) []string {
+		s := make([]string, 0)
+		for i, target := range p.Targets {
+			if refID(i) != target.RefID {
+				t.Fatal("bad refId:", target)
+			}
+			s = append(s, target.Target)
+		}
+		return s
+	}
+	if expected := []string{"app.errors.one-minute", "app.errors.five-minute", "app.errors.fifteen-minute"}; !reflect.DeepEqual(targets(d.Panels[0]), expected) {
+		t.Fatal("bad meter targets:", targets(d.Panels[0]))
+	}
+	if expected := []string{"app.latency.mean", "app.latency.99-percentile"}; !reflect.DeepEqual(targets(d.Panels[1]), expected) || "ms" != d.Panels[1].FieldConfig.Defaults.Unit {
+		t.Fatal("bad timer panel:", d.Panels[1])
+	}
+	if expected := []string{"perSecond(app.requests)"}; !reflect.DeepEqual(targets(d.Panels[2]), expected) {
+		t.Fatal("bad counter targets:", targets(d.Panels[2]))
+	}
+	if 12 != d.Panels[1].GridPos.X || 8 != d.Panels[2].GridPos.Y {
+		t.Fatal("bad layout:", d.Panels)
+	}
+}
+
+func TestRefID(t *testing.T) {
+	for i, expected := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 52: "BA"} {
+		if id := refID(i); expected != id {
+			t.Fatal("bad refId:", i, id)
+		}
+	}
+}
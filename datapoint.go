@@ -0,0 +1,62 @@
+package graphite
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Datapoint is a value of a series, as produced by walking a registry with
+// Datapoints or injected by Sender.SendDatapoints.
+type Datapoint struct {
+	Path  string            // Full path of the series, prefix included
+	Value float64           // Value of the series
+	Time  time.Time         // Time of the value, that of the flush if zero
+	Tags  map[string]string // Tags of a Graphite 1.1 tagged series, if any
+}
+
+// Sender sends datapoints to Graphite. Exporter is a Sender, so that
+// datapoints which do not come from a registry go through the validation,
+// ordering, routing and retries of flushes.
+type Sender interface {
+	SendDatapoints(ctx context.Context, dps []Datapoint) error
+}
+
+// Datapoints returns the datapoints of snaps as a flush at ts would send
+// them, the tags of tagged series apart from their path, so that they can
+// be filtered or routed before being handed to a Sender.
+func Datapoints(c *GraphiteConfig, snaps []MetricSnapshot, ts time.Time) []Datapoint {
+	dps := datapoints(c, snaps, ts)
+	exported := make([]Datapoint, len(dps))
+	for i, dp := range dps {
+		path, tags := splitTags(dp.path)
+		exported[i] = Datapoint{Path: path, Value: dp.value, Time: time.Unix(dp.timestamp, 0), Tags: tags}
+	}
+	return exported
+}
+
+// SendDatapoints immediately sends dps, outside of the regular schedule and
+// without the registry nor the datapoints queued by Send, as a flush
+// retried with c.RetryAttempts. Their paths are sent as is, with the tags
+// of c.Tags when c.Tagged is set. Paths holding a newline are reported to
+// c.OnMetricError and not sent.
+func (e *Exporter) SendDatapoints(ctx context.Context, dps []Datapoint) error {
+	c := &e.c
+	now := time.Now()
+	sel := selection{shard: allShards, dps: make([]datapoint, 0, len(dps))}
+	for _, dp := range dps {
+		path := tagged(c, dp.Path+tagString(dp.Tags))
+		if brokenPath(path) {
+			skipMetric(c, dp.Path, fmt.Errorf("newline in series %q", path))
+			continue
+		}
+		ts := dp.Time
+		if ts.IsZero() {
+			ts = now
+		}
+		sel.dps = append(sel.dps, datapoint{path: path, value: dp.Value, precision: -1, timestamp: ts.Unix()})
+	}
+	res := e.retryFlush(func() FlushResult { return e.flushSelected(ctx, sel) })
+	e.record(res)
+	return res.Err
+}
@@ -0,0 +1,63 @@
+package graphite
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDatapoints(t *testing.T) {
+	c := &GraphiteConfig{Prefix: "p", Tagged: true}
+	ts := time.Unix(1000, 0)
+	dps := Datapoints(c, []MetricSnapshot{NewCounterSnapshot("foo;env=prod", 3)}, ts)
+	expected := []Datapoint{{Path: "p.foo", Value: 3, Time: ts, Tags: map[string]string{"env": "prod"}}}
+	if !reflect.DeepEqual(dps, expected) {
+		t.Fatal("bad datapoints:", dps)
+	}
+}
+
+func TestSendDatapoints(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.Tagged = true
+	c.Tags = map[string]string{"dc": "ams"}
+	e := New(c)
+	var _ Sender = e
+	wg.Add(1)
+	err := e.SendDatapoints(context.Background(), []Datapoint{
+		{Path: "a.b", Value: 1, Tags: map[string]string{"env": "prod"}},
+		{Path: "c", Value: 2, Time: time.Unix(1000, 0)},
+		{Path: "broken\n", Value: 3},
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if 2 != len(res) || !floatEquals(1, res["a.b;dc=ams;env=prod"]) || !floatEquals(2, res["c;dc=ams"]) {
+		t.Fatal("bad datapoints sent:", res)
+	}
+}
+
+func TestSendDatapointsRetried(t *testing.T) {
+	dials := 0
+	e := New(GraphiteConfig{
+		Addr:            "carbon:2003",
+		FlushInterval:   time.Second,
+		RetryAttempts:   3,
+		RetryBackoff:    time.Millisecond,
+		RetryMaxElapsed: time.Second,
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			dials++
+			return nil, errors.New("unreachable")
+		},
+	})
+	if err := e.SendDatapoints(context.Background(), []Datapoint{{Path: "a", Value: 1}}); nil == err {
+		t.Fatal("send to an unreachable server did not fail")
+	}
+	if 3 != dials {
+		t.Fatal("send not retried:", dials)
+	}
+}
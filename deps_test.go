@@ -0,0 +1,53 @@
+package graphite
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestDependencies keeps the packages of the module free of dependencies
+// other than the standard library and go-metrics, so that embedding the
+// exporter does not drag client libraries into small services. Sinks
+// needing them are modules of their own, in directories holding a go.mod,
+// which are not walked.
+func TestDependencies(t *testing.T) {
+	var files []string
+	err := filepath.WalkDir(".", func(name string, d fs.DirEntry, err error) error {
+		if nil != err {
+			return err
+		}
+		if d.IsDir() && "." != name {
+			if _, err := os.Stat(filepath.Join(name, "go.mod")); nil == err || "testdata" == d.Name() {
+				return filepath.SkipDir
+			}
+		}
+		if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.ImportsOnly)
+		if nil != err {
+			t.Fatal(err)
+		}
+		for _, imp := range f.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			first := strings.SplitN(path, "/", 2)[0]
+			own := strings.HasPrefix(path, "github.com/cyberdelia/go-metrics-graphite") &&
+				!strings.HasPrefix(path, "github.com/cyberdelia/go-metrics-graphite/sinks/")
+			if strings.Contains(first, ".") && "github.com/rcrowley/go-metrics" != path && !own {
+				t.Error("dependency outside of the standard library and go-metrics:", name, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package graphite
+
+// PercentOfTotal derives, for every counter matching Pattern, a series
+// holding its share of the total of all the counters matching Pattern, in
+// percent. Given counters "responses.2xx" and "responses.5xx" and the
+// pattern "responses.*", the series "responses.2xx.percent" and
+// "responses.5xx.percent" are added to every flush. Shares are computed
+// from the values emitted, after every other policy, so with CounterDeltas
+// they are shares of the counts of the last interval, and counters dropped
+// by NegativeCounters or Outliers are not part of the total.
+type PercentOfTotal struct {
+	Pattern string
+	Suffix  string // Node appended to the counter name, "percent" if empty
+}
+
+// derive adds the series derived by c.PercentOfTotal to snaps.
+func derive(c *GraphiteConfig, snaps []MetricSnapshot) []MetricSnapshot {
+	for _, rule := range c.PercentOfTotal {
+		suffix := rule.Suffix
+		if "" == suffix {
+			suffix = "percent"
+		}
+		var total float64
+		members := make([]int, 0)
+		for i, s := range snaps {
+			if TypeCounter == s.Type && 0 != len(s.Fields) && match(rule.Pattern, s.Name) {
+				total += s.Fields[0].Value
+				members = append(members, i)
+			}
+		}
+		if 0 == total {
+			continue
+		}
+		for _, i := range members {
+			snaps[i].Fields = append(snaps[i].Fields, floatField(suffix, 100*snaps[i].Fields[0].Value/total))
+		}
+	}
+	return snaps
+}
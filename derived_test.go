@@ -0,0 +1,44 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestPercentOfTotal(t *testing.T) {
+	c := &GraphiteConfig{PercentOfTotal: []PercentOfTotal{{Pattern: "responses.*"}}}
+	snaps := derive(c, []MetricSnapshot{
+		NewCounterSnapshot("requests", 100),
+		NewCounterSnapshot("responses.2xx", 75),
+		NewCounterSnapshot("responses.5xx", 25),
+	})
+	if len(snaps[0].Fields) != 1 {
+		t.Fatal("unexpected derived series:", snaps[0])
+	}
+	for i, expected := range []float64{75, 25} {
+		f := snaps[i+1].Fields[1]
+		if f.Name != "percent" || !floatEquals(f.Value, expected) {
+			t.Fatal("bad derived series:", expected, f)
+		}
+	}
+}
+
+func TestPercentOfTotalDeltas(t *testing.T) {
+	e := New(GraphiteConfig{
+		CounterDeltas:  true,
+		PercentOfTotal: []PercentOfTotal{{Pattern: "responses.*"}},
+	})
+	flush := func(ok, failed int64) []MetricSnapshot {
+		return derive(&e.c, e.process([]MetricSnapshot{
+			NewCounterSnapshot("responses.2xx", ok),
+			NewCounterSnapshot("responses.5xx", failed),
+		}))
+	}
+	flush(1000, 0)
+	snaps := flush(1010, 10)
+	for i, expected := range []float64{50, 50} {
+		f := snaps[i].Fields[1]
+		if f.Name != "percent" || !floatEquals(f.Value, expected) {
+			t.Fatal("share not computed from deltas:", expected, f)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package graphite
+
+import (
+	"time"
+)
+
+// DestinationStats accounts for the flushes sent to a single destination.
+type DestinationStats struct {
+	Flushes       int           // Number of flushes
+	Failures      int           // Number of failed flushes
+	LastError     error         // Error of the last failed flush
+	LastErrorTime time.Time     // Time of the last failed flush
+	LastLatency   time.Duration // Duration of the last flush
+	TotalLatency  time.Duration // Cumulated duration of every flush
+}
+
+// SuccessRate returns the fraction of flushes which succeeded, or 1 if
+// there was none.
+func (s DestinationStats) SuccessRate() float64 {
+	if 0 == s.Flushes {
+		return 1
+	}
+	return float64(s.Flushes-s.Failures) / float64(s.Flushes)
+}
+
+// MeanLatency returns the mean duration of flushes.
+func (s DestinationStats) MeanLatency() time.Duration {
+	if 0 == s.Flushes {
+		return 0
+	}
+	return s.TotalLatency / time.Duration(s.Flushes)
+}
+
+// account updates the statistics of the destination of res. It needs e.mu.
+func (e *Exporter) account(res FlushResult) {
+	if nil == e.dests {
+		e.dests = make(map[string]*DestinationStats)
+	}
+	s, ok := e.dests[res.Addr]
+	if !ok {
+		s = &DestinationStats{}
+		e.dests[res.Addr] = s
+	}
+	s.Flushes++
+	s.LastLatency = res.Duration
+	s.TotalLatency += res.Duration
+	if nil != res.Err {
+		s.Failures++
+		s.LastError = res.Err
+		s.LastErrorTime = res.Time
+	}
+}
+
+// DestinationStats returns the statistics of every destination flushed to,
+// keyed by the address dialed, so that an unhealthy destination can be told
+// apart from the others.
+func (e *Exporter) DestinationStats() map[string]DestinationStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := make(map[string]DestinationStats, len(e.dests))
+	for addr, s := range e.dests {
+		stats[addr] = *s
+	}
+	return stats
+}
@@ -0,0 +1,60 @@
+package graphite
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDestinationStats(t *testing.T) {
+	e := New(GraphiteConfig{})
+	fail := errors.New("fail")
+	e.record(FlushResult{Addr: "a:2003", Duration: time.Second})
+	e.record(FlushResult{Addr: "a:2003", Duration: 3 * time.Second, Err: fail})
+	e.record(FlushResult{Addr: "b:2003", Duration: time.Second})
+
+	stats := e.DestinationStats()
+	a, b := stats["a:2003"], stats["b:2003"]
+	if a.Flushes != 2 || a.Failures != 1 || a.LastError != fail || a.MeanLatency() != 2*time.Second {
+		t.Fatal("bad stats:", a)
+	}
+	if !floatEquals(a.SuccessRate(), 0.5) || !floatEquals(b.SuccessRate(), 1) {
+		t.Fatal("bad success rates:", a.SuccessRate(), b.SuccessRate())
+	}
+}
+
+func TestDestinationStatsDialed(t *testing.T) {
+	_, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead.Close()
+	_, port, _ := net.SplitHostPort(c.Addr)
+	_, deadPort, _ := net.SplitHostPort(dead.Addr().String())
+	addrs := []string{"127.0.0.1"}
+	c.Addr = net.JoinHostPort("graphite.example", port)
+	c.Resolver = func(ctx context.Context, host string) ([]string, time.Duration, error) {
+		return addrs, time.Nanosecond, nil
+	}
+
+	e := New(c)
+	wg.Add(1)
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	e.c.Addr = net.JoinHostPort("graphite.example", deadPort)
+	if err := e.Flush(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	stats := e.DestinationStats()
+	live, down := stats[net.JoinHostPort("127.0.0.1", port)], stats[net.JoinHostPort("127.0.0.1", deadPort)]
+	if 1 != live.Flushes || 0 != live.Failures || 1 != down.Failures || 2 != len(stats) {
+		t.Fatal("flushes not accounted to the address dialed:", stats)
+	}
+}
@@ -0,0 +1,63 @@
+package graphite
+
+import (
+	"sort"
+)
+
+// SeriesRename is a series whose name differs between two configurations.
+type SeriesRename struct {
+	Old string
+	New string
+}
+
+// SeriesDiff reports how the series emitted for a registry change between
+// two configurations, see DiffSeries.
+type SeriesDiff struct {
+	Renamed []SeriesRename // Series emitted under another name
+	Added   []string       // Series only emitted with the new configuration
+	Removed []string       // Series only emitted with the old configuration
+}
+
+// Empty returns true if both configurations emit the same series.
+func (d SeriesDiff) Empty() bool {
+	return 0 == len(d.Renamed) && 0 == len(d.Added) && 0 == len(d.Removed)
+}
+
+// DiffSeries compares the series emitted for the current content of
+// old.Registry and new.Registry, which are usually the same, and reports
+// which ones a configuration change renames, adds or removes. A series is
+// identified by its metric and field, so that changes of prefix, run
+// identifier, unit suffixes or derived series are told apart from metrics
+// which appear or disappear. It helps rolling out renames without breaking
+// dashboards.
+func DiffSeries(old, new *GraphiteConfig) SeriesDiff {
+	before, after := seriesByField(old), seriesByField(new)
+	var d SeriesDiff
+	for _, id := range sortedKeys(before) {
+		name, ok := after[id]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, before[id])
+		case name != before[id]:
+			d.Renamed = append(d.Renamed, SeriesRename{Old: before[id], New: name})
+		}
+	}
+	for _, id := range sortedKeys(after) {
+		if _, ok := before[id]; !ok {
+			d.Added = append(d.Added, after[id])
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	return d
+}
+
+// seriesByField returns the series listed by SeriesNames keyed by the name
+// of the field they hold, before unit conversion.
+func seriesByField(c *GraphiteConfig) map[string]string {
+	byField := make(map[string]string)
+	for _, s := range series(c) {
+		byField[s.field] = s.path
+	}
+	return byField
+}
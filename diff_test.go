@@ -0,0 +1,43 @@
+package graphite
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestDiffSeries(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("responses.2xx", r).Inc(3)
+	metrics.GetOrRegisterCounter("responses.5xx", r).Inc(1)
+	metrics.GetOrRegisterGauge("heap", r).Update(1 << 20)
+
+	old := &GraphiteConfig{Registry: r, DurationUnit: time.Millisecond, Prefix: "app"}
+	if d := DiffSeries(old, old); !d.Empty() {
+		t.Fatal("configuration differs from itself:", d)
+	}
+
+	new := *old
+	new.Prefix = "svc"
+	new.Units = []UnitConversion{{Pattern: "heap", Factor: BytesToMegabytes, Suffix: "mb"}}
+	new.PercentOfTotal = []PercentOfTotal{{Pattern: "responses.*"}}
+	d := DiffSeries(old, &new)
+	expected := SeriesDiff{
+		Renamed: []SeriesRename{
+			{Old: "app.heap", New: "svc.heap.mb"},
+			{Old: "app.responses.2xx", New: "svc.responses.2xx"},
+			{Old: "app.responses.5xx", New: "svc.responses.5xx"},
+		},
+		Added: []string{"svc.responses.2xx.percent", "svc.responses.5xx.percent"},
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatal("bad diff:", d)
+	}
+
+	d = DiffSeries(&new, old)
+	if !reflect.DeepEqual(d.Removed, expected.Added) || 0 != len(d.Added) {
+		t.Fatal("bad reverse diff:", d)
+	}
+}
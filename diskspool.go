@@ -0,0 +1,188 @@
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberdelia/go-metrics-graphite/carbonparse"
+)
+
+// Segments of c.SpoolDir are named after the Unix time in nanoseconds they
+// were written at, zero padded so that they sort in the order they were
+// written.
+const (
+	segmentPrefix = "graphite-"
+	segmentSuffix = ".spool"
+)
+
+// replayBatch is the number of segments replayed after a successful flush,
+// so that replaying a long outage does not hold up flushes.
+const replayBatch = 10
+
+// parkFlush spools the datapoints of a flush of sel at t which could not
+// connect, if c.SpoolDir or c.ReplayPayloads is set, so that they are
+// replayed with their timestamps rather than lost during an outage.
+func (e *Exporter) parkFlush(sel selection, t time.Time) {
+	if "" == e.c.SpoolDir && e.c.ReplayPayloads <= 0 {
+		return
+	}
+	dps, queued, restore, err := e.prepare(sel, t)
+	if nil != err {
+		return
+	}
+	if !e.park(dps) {
+		restore()
+		e.requeue(queued)
+		return
+	}
+	e.saveState()
+}
+
+// park writes dps, datapoints a flush failed to send, to a new segment of
+// c.SpoolDir, or keeps them in memory with c.ReplayPayloads. It returns
+// false, so that they are queued instead, if neither is set or the segment
+// could not be written. Segments are frames, see ReadFrame, so that
+// corrupted segments are detected.
+func (e *Exporter) park(dps []datapoint) bool {
+	c := &e.c
+	if "" == c.SpoolDir {
+		return e.keepFailed(dps)
+	}
+	if 0 == len(dps) {
+		return false
+	}
+	var buf bytes.Buffer
+	encode(&buf, dps)
+	now := time.Now()
+	name := filepath.Join(c.SpoolDir, fmt.Sprintf("%s%020d%s", segmentPrefix, now.UnixNano(), segmentSuffix))
+	err := os.MkdirAll(c.SpoolDir, 0o755)
+	if nil == err {
+		err = writeFileAtomic(name, appendFrame(nil, uint64(now.UnixNano()), buf.Bytes()))
+	}
+	if nil != err {
+		log.Println("graphite: could not spool datapoints:", err)
+		return false
+	}
+	e.trimSpool(now)
+	return true
+}
+
+// segment is a file of c.SpoolDir.
+type segment struct {
+	name    string
+	written time.Time
+	size    int64
+}
+
+// segments returns the segments of c.SpoolDir, oldest first.
+func (e *Exporter) segments() []segment {
+	entries, err := os.ReadDir(e.c.SpoolDir)
+	if nil != err {
+		return nil
+	}
+	var segs []segment
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), 10, 64)
+		info, ierr := entry.Info()
+		if nil != err || nil != ierr {
+			continue
+		}
+		segs = append(segs, segment{name: filepath.Join(e.c.SpoolDir, name), written: time.Unix(0, nanos), size: info.Size()})
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].name < segs[j].name })
+	return segs
+}
+
+// trimSpool removes the segments older than c.SpoolMaxAge at now, and the
+// oldest ones once they hold more than c.SpoolMaxBytes.
+func (e *Exporter) trimSpool(now time.Time) {
+	maxBytes, maxAge := e.c.SpoolMaxBytes, e.c.SpoolMaxAge
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20
+	}
+	if maxAge <= 0 {
+		maxAge = 24 * time.Hour
+	}
+	segs := e.segments()
+	var total int64
+	dropped := 0
+	for i := len(segs) - 1; i >= 0; i-- {
+		total += segs[i].size
+		if total > maxBytes || now.Sub(segs[i].written) > maxAge {
+			os.Remove(segs[i].name)
+			dropped++
+		}
+	}
+	if 0 != dropped {
+		log.Printf("graphite: dropped %d spooled segments beyond SpoolMaxBytes or SpoolMaxAge", dropped)
+	}
+}
+
+// replay sends the payloads kept by c.ReplayPayloads and the oldest
+// segments of c.SpoolDir on conn, which delivered a flush, removing those
+// delivered. A segment partly delivered is replaced by the datapoints left
+// to send. It returns the error which broke conn, if any. Corrupted
+// segments are logged and removed.
+func (e *Exporter) replay(ctx context.Context, conn net.Conn) error {
+	if err := e.replayFailed(ctx, conn); nil != err || "" == e.c.SpoolDir {
+		return err
+	}
+	segs := e.segments()
+	if len(segs) > replayBatch {
+		segs = segs[:replayBatch]
+	}
+	for _, seg := range segs {
+		dps, err := readSegment(seg.name)
+		if nil != err {
+			log.Println("graphite: dropping corrupted spooled segment:", err)
+			os.Remove(seg.name)
+			continue
+		}
+		_, sent, err := e.attempt(ctx, conn, time.Now(), dps)
+		if nil == err {
+			os.Remove(seg.name)
+			continue
+		}
+		if 0 != sent {
+			var buf bytes.Buffer
+			encode(&buf, dps[sent:])
+			writeFileAtomic(seg.name, appendFrame(nil, uint64(seg.written.UnixNano()), buf.Bytes()))
+		}
+		return err
+	}
+	return nil
+}
+
+// readSegment returns the datapoints of the segment called name.
+func readSegment(name string) ([]datapoint, error) {
+	b, err := os.ReadFile(name)
+	if nil != err {
+		return nil, err
+	}
+	f, err := ReadFrame(bytes.NewReader(b))
+	if nil != err {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	lines, err := carbonparse.Parse(bytes.NewReader(f.Payload))
+	if nil != err {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	dps := make([]datapoint, len(lines))
+	for i, d := range lines {
+		dps[i] = datapoint{path: d.Series(), value: d.Value, precision: -1, timestamp: d.Timestamp}
+	}
+	return dps, nil
+}
@@ -0,0 +1,130 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// NewSpoolExporter returns an exporter spooling to dir whose connections
+// fail until *up is set, and the lines it sent.
+func NewSpoolExporter(t *testing.T, dir string, up *bool) (*Exporter, func() []string) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var lines []string
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	e := New(GraphiteConfig{
+		Addr:          "carbon:2003",
+		Registry:      r,
+		FlushInterval: time.Second,
+		Prefix:        "p",
+		SpoolDir:      dir,
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			if !*up {
+				return nil, errors.New("unreachable")
+			}
+			client, server := net.Pipe()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				scanner := bufio.NewScanner(server)
+				for scanner.Scan() {
+					mu.Lock()
+					lines = append(lines, scanner.Text())
+					mu.Unlock()
+				}
+			}()
+			return client, nil
+		},
+	})
+	return e, func() []string {
+		wg.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		return lines
+	}
+}
+
+func TestDiskSpool(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	up := false
+	e, sent := NewSpoolExporter(t, dir, &up)
+	e.Send("event", 1, time.Unix(1000, 0))
+	if err := e.flush().Err; nil == err {
+		t.Fatal("flush did not fail")
+	}
+	if segs := e.segments(); 1 != len(segs) || 0 != len(e.queue) {
+		t.Fatal("datapoints not spooled:", segs, e.queue)
+	}
+
+	up = true
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	lines := sent()
+	joined := strings.Join(lines, "\n")
+	if 3 != len(lines) || 2 != strings.Count(joined, "p.foo 1 ") || !strings.Contains(joined, "p.event 1 1000") {
+		t.Fatal("spooled datapoints not replayed:", lines)
+	}
+	if segs := e.segments(); 0 != len(segs) {
+		t.Fatal("replayed segments not removed:", segs)
+	}
+}
+
+func TestDiskSpoolCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	up := false
+	e, sent := NewSpoolExporter(t, dir, &up)
+	e.flush()
+	segs := e.segments()
+	if 1 != len(segs) {
+		t.Fatal("datapoints not spooled:", segs)
+	}
+	b, _ := os.ReadFile(segs[0].name)
+	b[len(b)-2] ^= 1
+	os.WriteFile(segs[0].name, b, 0o644)
+
+	up = true
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if lines := sent(); 1 != len(lines) {
+		t.Fatal("corrupted segment replayed:", lines)
+	}
+	if segs := e.segments(); 0 != len(segs) {
+		t.Fatal("corrupted segment not removed:", segs)
+	}
+}
+
+func TestTrimSpool(t *testing.T) {
+	dir := t.TempDir()
+	up := false
+	e, _ := NewSpoolExporter(t, dir, &up)
+	for i := 0; i < 3; i++ {
+		e.flush()
+	}
+	segs := e.segments()
+	if 3 != len(segs) {
+		t.Fatal("datapoints not spooled:", segs)
+	}
+	e.c.SpoolMaxBytes = 2 * segs[0].size
+	e.trimSpool(time.Now())
+	if kept := e.segments(); 2 != len(kept) || kept[0].name != segs[1].name {
+		t.Fatal("oldest segment not dropped:", kept)
+	}
+	e.c.SpoolMaxAge = time.Minute
+	e.trimSpool(time.Now().Add(time.Hour))
+	if kept := e.segments(); 0 != len(kept) {
+		t.Fatal("old segments not dropped:", kept)
+	}
+}
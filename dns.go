@@ -0,0 +1,147 @@
+package graphite
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+)
+
+// Resolver returns the addresses host resolves to along with the time they
+// may be cached for, usually the TTL of the DNS records. The Go resolver
+// does not report TTLs, so a Resolver is typically built on a DNS client
+// library.
+type Resolver func(ctx context.Context, host string) (addrs []string, ttl time.Duration, err error)
+
+// dnsCache holds the addresses the host of an endpoint, see
+// GraphiteConfig.Addrs, resolved to.
+type dnsCache struct {
+	endpoint string
+	addrs    []string
+	expires  time.Time
+}
+
+// resolve returns the addresses to dial to reach endpoint, c.Addr or one of
+// c.Addrs, in order of preference. Without c.DNSCacheTTL nor c.Resolver,
+// endpoint is returned as is and resolved by the dialer on every
+// connection. Otherwise the resolved addresses are cached for the TTL
+// reported by c.Resolver, capped by c.DNSCacheTTL if set, or for
+// c.DNSCacheTTL with the Go resolver, which does not report TTLs. When a
+// lookup fails after the cached addresses expired they are used until a
+// lookup succeeds again. Only the addresses of the endpoint last resolved
+// are cached.
+func (e *Exporter) resolve(ctx context.Context, endpoint string) ([]string, error) {
+	if _, path, ok := unixAddr(endpoint); ok {
+		return []string{path}, nil
+	}
+	if e.c.DNSCacheTTL <= 0 && nil == e.c.Resolver {
+		return []string{endpoint}, nil
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if nil != err || nil != net.ParseIP(host) {
+		return []string{endpoint}, nil
+	}
+
+	e.mu.Lock()
+	if endpoint != e.dns.endpoint {
+		e.dns = dnsCache{endpoint: endpoint}
+	}
+	if time.Now().Before(e.dns.expires) {
+		addrs := e.dns.addrs
+		e.mu.Unlock()
+		return addrs, nil
+	}
+	e.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ips, ttl, err := e.lookup(ctx, host)
+	if nil == err && 0 == len(ips) {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if nil != err {
+		if 0 == len(e.dns.addrs) {
+			return nil, err
+		}
+		log.Println("graphite: using stale addresses:", err)
+		return e.dns.addrs, nil
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, port)
+	}
+	e.dns = dnsCache{endpoint: endpoint, addrs: addrs, expires: time.Now().Add(ttl)}
+	return addrs, nil
+}
+
+// errMoved is the error closing the connection kept open to an endpoint
+// whose host no longer resolves to the address connected to.
+var errMoved = errors.New("graphite: endpoint moved to another address")
+
+// moved returns true if the host of the endpoint conn, the connection kept
+// open by c.KeepAlive, was dialed to no longer resolves to the address of
+// conn, which is checked every c.ResolveInterval. The cached addresses are
+// then dropped so that the new ones are dialed. Connections are kept when
+// the lookup fails.
+func (e *Exporter) moved(ctx context.Context, conn net.Conn) bool {
+	if e.c.ResolveInterval <= 0 {
+		return false
+	}
+	e.mu.Lock()
+	due := time.Since(e.resolved) >= e.c.ResolveInterval
+	if due {
+		e.resolved = time.Now()
+	}
+	e.mu.Unlock()
+	if !due {
+		return false
+	}
+	host, _, err := net.SplitHostPort(e.Endpoint())
+	if nil != err || nil != net.ParseIP(host) {
+		return false
+	}
+	remote, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if nil != err {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ips, _, err := e.lookup(ctx, host)
+	if nil != err || 0 == len(ips) {
+		return false
+	}
+	for _, ip := range ips {
+		if net.ParseIP(ip).Equal(net.ParseIP(remote)) {
+			return false
+		}
+	}
+	e.mu.Lock()
+	e.dns = dnsCache{}
+	e.mu.Unlock()
+	return true
+}
+
+// lookup returns the addresses of host and the time they may be cached for.
+func (e *Exporter) lookup(ctx context.Context, host string) ([]string, time.Duration, error) {
+	if nil == e.c.Resolver {
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		return ips, e.c.DNSCacheTTL, err
+	}
+	ips, ttl, err := e.c.Resolver(ctx, host)
+	if e.c.DNSCacheTTL > 0 && ttl > e.c.DNSCacheTTL {
+		ttl = e.c.DNSCacheTTL
+	}
+	return ips, ttl, err
+}
+
+// Refresh drops the cached addresses of the Graphite server so that the
+// next flush resolves its host again. It is meant to be called when a DNS
+// failover is known to have happened.
+func (e *Exporter) Refresh() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dns = dnsCache{}
+}
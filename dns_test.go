@@ -0,0 +1,139 @@
+package graphite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestDNSCache(t *testing.T) {
+	_, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(c.Addr)
+	c.Addr = net.JoinHostPort("localhost", port)
+	c.DNSCacheTTL = time.Minute
+
+	e := New(c)
+	addrs, err := e.resolve(context.Background(), c.Addr)
+	if err != nil {
+		t.Skip("localhost does not resolve:", err)
+	}
+	if len(addrs) == 0 || e.dns.expires.IsZero() {
+		t.Fatal("addresses not cached:", addrs)
+	}
+
+	// Cached addresses are used as is.
+	e.dns.addrs = []string{net.JoinHostPort("127.0.0.1", port)}
+	wg.Add(1)
+	if res := e.flush(); res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	wg.Wait()
+
+	e.Refresh()
+	if !e.dns.expires.IsZero() || e.dns.addrs != nil {
+		t.Fatal("cache not dropped")
+	}
+}
+
+func TestResolver(t *testing.T) {
+	_, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(c.Addr)
+	c.Addr = net.JoinHostPort("graphite.example", port)
+	var lookups int
+	var fail error
+	c.Resolver = func(ctx context.Context, host string) ([]string, time.Duration, error) {
+		lookups++
+		if "graphite.example" != host {
+			t.Error("bad host:", host)
+		}
+		return []string{"127.0.0.1"}, time.Hour, fail
+	}
+	c.DNSCacheTTL = time.Minute
+
+	e := New(c)
+	wg.Add(1)
+	if res := e.flush(); res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	wg.Wait()
+	if 1 != lookups || time.Until(e.dns.expires) > time.Minute {
+		t.Fatal("TTL not capped by DNSCacheTTL:", lookups, e.dns.expires)
+	}
+
+	// Stale addresses are used when the lookup fails.
+	e.dns.expires = time.Now()
+	fail = errors.New("SERVFAIL")
+	wg.Add(1)
+	if res := e.flush(); res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	wg.Wait()
+	if 2 != lookups {
+		t.Fatal("expired addresses not resolved again:", lookups)
+	}
+
+	e.Refresh()
+	if _, err := e.resolve(context.Background(), c.Addr); err != fail {
+		t.Fatal("lookup error not reported without cached addresses:", err)
+	}
+}
+
+// remoteConn is a connection reporting remote as its remote address.
+type remoteConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c remoteConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func TestResolveInterval(t *testing.T) {
+	ip := "10.0.0.1"
+	var dialed []string
+	var disconnects []error
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	e := New(GraphiteConfig{
+		Addr:            "graphite.example:2003",
+		Registry:        r,
+		FlushInterval:   time.Second,
+		KeepAlive:       true,
+		ResolveInterval: time.Nanosecond,
+		Resolver: func(context.Context, string) ([]string, time.Duration, error) {
+			return []string{ip}, time.Hour, nil
+		},
+		DialFunc: func(_ context.Context, _, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			client, server := net.Pipe()
+			go io.Copy(io.Discard, server)
+			remote, _ := net.ResolveTCPAddr("tcp", addr)
+			return remoteConn{client, remote}, nil
+		},
+		OnDisconnect: func(_ string, err error) { disconnects = append(disconnects, err) },
+	})
+	defer e.closeConn()
+	for i := 0; i < 2; i++ {
+		if err := e.flush().Err; nil != err {
+			t.Fatal(err)
+		}
+	}
+	if 1 != len(dialed) {
+		t.Fatal("connection not kept:", dialed)
+	}
+
+	ip = "10.0.0.2"
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(dialed) || "10.0.0.2:2003" != dialed[1] || 1 != len(disconnects) || errMoved != disconnects[0] {
+		t.Fatal("did not reconnect to the new address:", dialed, disconnects)
+	}
+}
@@ -0,0 +1,102 @@
+package graphite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Encode writes snaps to w using the Graphite plaintext protocol, with
+// every datapoint timestamped at ts. It performs no I/O besides writing to
+// w and depends on nothing but its arguments, which makes it suitable for
+// golden-file tests of the exact payload a service emits. It applies
+// c.Transforms and c.TimestampFunc but none of the policies of flushes:
+// counter deltas and reset annotations, NegativeCounters, Clamp, Outliers,
+// Units, PercentOfTotal and the exporter's own series, so its series can
+// differ from the ones listed by SeriesNames.
+func Encode(w io.Writer, c *GraphiteConfig, snaps []MetricSnapshot, ts time.Time) error {
+	return encodeLines(w, datapoints(c, snaps, ts), lineEnding(c))
+}
+
+// datapoint is a single line of the plaintext protocol.
+type datapoint struct {
+	path      string
+	value     float64
+	precision int
+	timestamp int64
+	relay     *Relay // Relay which received the datapoint, if any
+}
+
+// datapoints returns the datapoints of snaps transformed by c.Transforms and
+// timestamped at ts, or at the time returned by c.TimestampFunc if set.
+func datapoints(c *GraphiteConfig, snaps []MetricSnapshot, ts time.Time) []datapoint {
+	dps := make([]datapoint, 0)
+	for _, s := range snaps {
+		var metric []datapoint
+		err := isolate(c, s.Name, func() { metric = metricDatapoints(c, s, ts) })
+		if nil != err {
+			continue
+		}
+		for _, dp := range metric {
+			if brokenPath(dp.path) {
+				skipMetric(c, s.Name, fmt.Errorf("newline in series %q", dp.path))
+				metric = nil
+				break
+			}
+		}
+		dps = append(dps, metric...)
+	}
+	return dps
+}
+
+// metricDatapoints returns the datapoints of the fields of s.
+func metricDatapoints(c *GraphiteConfig, s MetricSnapshot, ts time.Time) []datapoint {
+	dps := make([]datapoint, 0, len(s.Fields))
+	for _, f := range s.Fields {
+		if 0 != len(c.Transforms) {
+			f = transform(c, fieldName(s, f), f)
+		}
+		timestamp := ts.Unix()
+		if nil != c.TimestampFunc {
+			timestamp = c.TimestampFunc(fieldName(s, f), ts)
+		}
+		dps = append(dps, datapoint{path: seriesName(c, s, f), value: f.Value, precision: f.Precision, timestamp: timestamp})
+	}
+	return dps
+}
+
+func encode(w io.Writer, dps []datapoint) error {
+	return encodeLines(w, dps, "\n")
+}
+
+// encodeLines writes dps to w as plaintext lines terminated by eol, the
+// last one included.
+func encodeLines(w io.Writer, dps []datapoint, eol string) error {
+	bw := bufio.NewWriter(w)
+	var line []byte
+	for _, dp := range dps {
+		line = appendLine(line[:0], dp, eol)
+		bw.Write(line)
+	}
+	return bw.Flush()
+}
+
+// lineEnding returns the terminator of the plaintext lines of c.
+func lineEnding(c *GraphiteConfig) string {
+	if c.CRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// appendLine appends the plaintext line of dp terminated by eol to b.
+func appendLine(b []byte, dp datapoint, eol string) []byte {
+	b = append(b, dp.path...)
+	b = append(b, ' ')
+	b = strconv.AppendFloat(b, dp.value, 'f', dp.precision, 64)
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, dp.timestamp, 10)
+	return append(b, eol...)
+}
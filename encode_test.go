@@ -0,0 +1,139 @@
+package graphite
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEncodeGolden(t *testing.T) {
+	c := &GraphiteConfig{
+		DurationUnit: time.Millisecond,
+		Prefix:       "golden",
+		Percentiles:  []float64{0.5, 0.999},
+	}
+	snaps := []MetricSnapshot{
+		NewCounterSnapshot("requests", 42),
+		NewGaugeFloat64Snapshot("load", 0.25),
+		NewHistogramSnapshot(c, "sizes", []int64{1, 2, 3, 4}),
+		NewMeterSnapshot("hits", 10, Rates{1, 2, 3, 4}),
+		NewTimerSnapshot(c, "latency", []time.Duration{time.Second, 3 * time.Second}, Rates{Mean: 0.5}),
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, c, snaps, time.Unix(1234567890, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `golden.requests 42 1234567890
+golden.load 0.250000 1234567890
+golden.sizes.count 4 1234567890
+golden.sizes.min 1 1234567890
+golden.sizes.max 4 1234567890
+golden.sizes.mean 2.50 1234567890
+golden.sizes.std-dev 1.12 1234567890
+golden.sizes.50-precentile 2.50 1234567890
+golden.sizes.999-precentile 4.00 1234567890
+golden.hits.count 10 1234567890
+golden.hits.one-minute 1.00 1234567890
+golden.hits.five-minute 2.00 1234567890
+golden.hits.fifteen-minute 3.00 1234567890
+golden.hits.mean 4.00 1234567890
+golden.latency.count 2 1234567890
+golden.latency.min 1000.00 1234567890
+golden.latency.max 3000.00 1234567890
+golden.latency.mean 2000.00 1234567890
+golden.latency.std-dev 1000.00 1234567890
+golden.latency.50-percentile 2000.00 1234567890
+golden.latency.999-percentile 3000.00 1234567890
+golden.latency.one-minute 0.00 1234567890
+golden.latency.five-minute 0.00 1234567890
+golden.latency.fifteen-minute 0.00 1234567890
+golden.latency.mean-rate 0.50 1234567890
+`
+	if found := buf.String(); found != expected {
+		t.Fatalf("bad payload:\n%s", found)
+	}
+}
+
+func TestEncodeDurationPrecision(t *testing.T) {
+	for _, tc := range []struct {
+		precision int
+		expected  string
+	}{
+		{0, "p.t.min 0.25 1\n"},
+		{3, "p.t.min 0.250 1\n"},
+		{-1, "p.t.min 0.25 1\n"},
+	} {
+		c := &GraphiteConfig{DurationUnit: time.Millisecond, DurationPrecision: tc.precision, Prefix: "p"}
+		s := NewTimerSnapshot(c, "t", []time.Duration{250 * time.Microsecond}, Rates{})
+		s.Fields = s.Fields[1:2]
+
+		var buf bytes.Buffer
+		Encode(&buf, c, []MetricSnapshot{s}, time.Unix(1, 0))
+		if found := buf.String(); found != tc.expected {
+			t.Fatal("bad payload:", tc.precision, found)
+		}
+	}
+}
+
+func TestTimestampFunc(t *testing.T) {
+	c := &GraphiteConfig{
+		Prefix: "ts",
+		TimestampFunc: func(name string, now time.Time) int64 {
+			if "backfill" == name {
+				return now.Add(-time.Hour).Unix()
+			}
+			return now.Unix()
+		},
+	}
+	snaps := []MetricSnapshot{NewCounterSnapshot("backfill", 1), NewCounterSnapshot("live", 2)}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, c, snaps, time.Unix(1234567890, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "ts.backfill 1 1234564290\nts.live 2 1234567890\n"; expected != buf.String() {
+		t.Fatalf("bad payload:\n%s", buf.String())
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	c := &GraphiteConfig{Prefix: "p", CRLF: true}
+	var b bytes.Buffer
+	snaps := []MetricSnapshot{NewCounterSnapshot("a", 1), NewCounterSnapshot("b", 2)}
+	if err := Encode(&b, c, snaps, time.Unix(1, 0)); nil != err {
+		t.Fatal(err)
+	}
+	if expected := "p.a 1 1\r\np.b 2 1\r\n"; expected != b.String() {
+		t.Fatalf("bad payload: %q", b.String())
+	}
+
+	// Captured payloads are written as they are, the others are encoded
+	// while written.
+	for _, capture := range []int{0, 1} {
+		conn := &recordConn{}
+		e := New(GraphiteConfig{Prefix: "p", CRLF: true, CapturePayloads: capture})
+		e.Send("a", 1, time.Unix(1, 0))
+		if _, _, err := e.write(conn, time.Now(), e.dequeue()); nil != err {
+			t.Fatal(err)
+		}
+		if found := string(bytes.Join(conn.writes, nil)); "p.a 1 1\r\n" != found {
+			t.Fatalf("bad write: %d %q", capture, found)
+		}
+	}
+}
+
+func TestEncodeZeroDurationUnit(t *testing.T) {
+	c := &GraphiteConfig{Percentiles: []float64{0.5}}
+	s := NewTimerSnapshot(c, "t", []time.Duration{250, 750}, Rates{})
+	for _, f := range s.Fields {
+		if math.IsInf(f.Value, 0) || math.IsNaN(f.Value) {
+			t.Fatal("bad field with a zero duration unit:", f)
+		}
+		if "max" == f.Name && !floatEquals(750, f.Value) {
+			t.Fatal("durations not sent in nanoseconds:", f)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package graphite
+
+import (
+	"context"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// FlushResult describes the outcome of a single flush.
+type FlushResult struct {
+	Addr     string        // Address dialed, one of those Addr resolves to with DNSCacheTTL or Resolver
+	Time     time.Time     // Time the flush started
+	Duration time.Duration // Time spent flushing
+	Lines    int           // Number of lines encoded
+	Bytes    int           // Number of bytes written; complete lines are not sent again if the flush failed
+	Err      error         // Error which failed the flush, if any
+}
+
+// Exporter periodically reports the metrics of a registry to Graphite. It is
+// the non-blocking counterpart of GraphiteWithConfig.
+//
+// Its methods are safe for concurrent use by multiple goroutines. Flushes,
+// whether regular, bursts or requested with Flush and FlushMetric, are
+// serialized so that each of them computes counter deltas, orders
+// datapoints and requeues what it failed to deliver on top of the previous
+// one, which is why OnConnect, OnDisconnect and Tracer, called during
+// flushes, must not flush. The configuration is copied by New, slices and
+// maps included, and never modified afterwards.
+type Exporter struct {
+	c       GraphiteConfig
+	ctx     context.Context // cancels the flush loop and the flushes in progress
+	results chan FlushResult
+	stop    chan struct{}
+	done    chan struct{}
+	watched bool  // run by a Supervisor, which restarts it when its flush loop panics
+	crash   error // panic which stopped the flush loop of a watched exporter
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	bursting  sync.WaitGroup // tracks the goroutines of bursts
+	running   sync.WaitGroup // tracks the flush loop
+	spawned   int64          // background goroutines, see Goroutines
+	frames    uint64         // sequence number of the last frame written, see Frame
+	beat      int64          // UnixNano of the last iteration of the flush loop, see Supervisor
+
+	flushMu sync.Mutex // serializes flushes
+
+	connMu   sync.Mutex // protects conn and connAddr
+	conn     net.Conn   // connection kept open between flushes, if any
+	connAddr string     // address conn was dialed to
+
+	mu        sync.Mutex // protects the fields below
+	budget    errorBudget
+	last      *FlushResult
+	outliers  map[string]*runningStats
+	negatives int64
+	baselines map[string]float64
+	counts    map[string]float64 // counts of counters and meters at the previous flush, see ZeroIdle
+	hostLock  *HostLock
+	loaded    bool
+	queue     []datapoint
+	relayed   []datapoint // datapoints queued by relays
+	queued    int         // bytes held by queue and relayed
+	state     int         // bytes held by the per-series state
+	memDrops  int64       // datapoints and payloads dropped by c.MemoryLimit
+	series    map[string]int
+	perShard  []map[string]int // series counted by namespace for every shard
+	history   []FlushResult
+	next      int // index of the oldest entry of history once full
+	payloads  []payload
+	nextPay   int // index of the oldest entry of payloads once full
+	port      int // offset in the local port range of the next connection
+	dns       dnsCache
+	healthy   int      // index in endpoints, or srv, of the endpoint connected to first
+	srv       []string // endpoints of c.SRV last discovered
+	dests     map[string]*DestinationStats
+	latest    map[string]delivery // latest datapoint delivered per series
+	late      int64
+	crossed   map[thresholdKey]bool // whether series are beyond the threshold of rules
+	tput      throughput
+	succeeded time.Time // start of the last successful flush
+	bursts    []*burst
+	breaker   BreakerState
+	replays   [][]datapoint // payloads of failed flushes, see ReplayPayloads
+	replayed  int           // bytes held by replays
+	relays    []*Relay
+	external  map[string]bool // series of the last ExportSnapshot
+	blackouts []*blackoutWindow
+	previous  map[int]map[string]float64 // values of the previous regular flush of every shard, see DiffFlushes
+	registry  metrics.Registry           // registry replacing c.Registry, see SwapRegistry
+	mirrors   []*mirror                  // destinations of c.Mirrors
+	fanning   int32                      // whether the mirrors run in the background, see fanOut
+	resolved  time.Time                  // time the endpoint of conn was last resolved, see ResolveInterval
+}
+
+// New returns an Exporter reporting according to c. It does not report
+// anything until Start is called.
+func New(c GraphiteConfig) *Exporter {
+	c = clone(c)
+	checkSchema(&c)
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = time.Minute
+	}
+	return &Exporter{
+		c:         c,
+		ctx:       context.Background(),
+		blackouts: blackoutWindows(c.Blackouts),
+		mirrors:   mirrors(&c),
+		outliers:  make(map[string]*runningStats),
+		tput:      newThroughput(),
+		results:   make(chan FlushResult, 16),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// clone returns a copy of c sharing no slice nor map with it, so that the
+// caller may reuse c.
+func clone(c GraphiteConfig) GraphiteConfig {
+	c.Addrs = slices.Clone(c.Addrs)
+	c.Percentiles = slices.Clone(c.Percentiles)
+	c.APIKeys = slices.Clone(c.APIKeys)
+	c.Tags = maps.Clone(c.Tags)
+	if nil != c.SummaryFields {
+		fields := make(map[string][]string, len(c.SummaryFields))
+		for typ, names := range c.SummaryFields {
+			fields[typ] = slices.Clone(names)
+		}
+		c.SummaryFields = fields
+	}
+	c.Clamp = slices.Clone(c.Clamp)
+	c.Outliers = slices.Clone(c.Outliers)
+	c.Units = slices.Clone(c.Units)
+	c.PercentOfTotal = slices.Clone(c.PercentOfTotal)
+	c.Transforms = slices.Clone(c.Transforms)
+	c.Thresholds = slices.Clone(c.Thresholds)
+	c.Blackouts = slices.Clone(c.Blackouts)
+	c.Mirrors = slices.Clone(c.Mirrors)
+	return c
+}
+
+// Start starts flushing the registry every c.FlushInterval, a shard at a
+// time with c.Shards, in the background. Calling Start more than once, or
+// after Stop, has no effect.
+func (e *Exporter) Start() {
+	e.startOnce.Do(func() {
+		e.startMirrors()
+		e.spawn(&e.running, e.run)
+	})
+}
+
+// Stop stops the background flushes started by Start and waits for the
+// current one to complete. The Results channel is closed afterwards, as
+// well as the relays of e and the connection kept open with c.KeepAlive.
+// Stop may be called more than once, and before Start.
+func (e *Exporter) Stop() {
+	e.halt()
+	e.closeRelays()
+	e.startOnce.Do(func() {
+		e.bursting.Wait()
+		e.closeConn()
+		e.releaseHostLock()
+		close(e.results)
+		close(e.done)
+	})
+	<-e.done
+	e.running.Wait()
+	e.closeMirrors()
+}
+
+// halt closes e.stop, once. Bursts check e.stop under the lock before being
+// tracked, so that none starts once their goroutines are waited for.
+func (e *Exporter) halt() {
+	e.stopOnce.Do(func() {
+		e.mu.Lock()
+		close(e.stop)
+		e.mu.Unlock()
+	})
+}
+
+// Close stops e like Stop and then performs a final synchronous flush, so
+// that the metrics of the last interval are not lost when a service
+// terminates. The final flush is aborted after c.CloseTimeout. It returns
+// the error of the final flush.
+func (e *Exporter) Close() error {
+	e.Stop()
+	timeout := e.c.CloseTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	defer e.releaseHostLock()
+	defer e.closeConn()
+	defer e.closeMirrors()
+	res := e.flushContext(ctx, nil)
+	e.record(res)
+	return res.Err
+}
+
+// Results returns a channel on which the outcome of every flush is
+// published. Results are dropped rather than delaying the next flush when
+// the channel is not drained fast enough.
+func (e *Exporter) Results() <-chan FlushResult {
+	return e.results
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+	defer close(e.results)
+	defer e.releaseHostLock()
+	defer e.closeConn()
+	defer e.bursting.Wait()
+	if e.watched {
+		defer e.recoverRun()
+	}
+	ticker := time.NewTicker(tick(&e.c))
+	shard := 0
+	defer ticker.Stop()
+	var probes, thresholds <-chan time.Time
+	if e.c.ProbeInterval > 0 {
+		probe := time.NewTicker(e.c.ProbeInterval)
+		defer probe.Stop()
+		probes = probe.C
+	}
+	if 0 != len(e.c.Thresholds) {
+		interval := e.c.ThresholdInterval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		threshold := time.NewTicker(interval)
+		defer threshold.Stop()
+		thresholds = threshold.C
+	}
+	for {
+		e.heartbeat()
+		select {
+		case <-e.stop:
+			return
+		case <-e.ctx.Done():
+			e.halt()
+			return
+		case <-probes:
+			e.probe()
+		case <-thresholds:
+			if _, ok := e.blackout(time.Now()); !ok {
+				e.checkThresholds()
+			}
+		case now := <-ticker.C:
+			current := shard
+			if n := shards(&e.c); n > 1 {
+				shard = (shard + 1) % n
+			} else {
+				current = allShards
+			}
+			if w, ok := e.blackout(now); ok {
+				if w.Spool {
+					e.spool(now, current)
+				}
+				continue
+			}
+			res := e.retryFlush(func() FlushResult { return e.flushShard(current) })
+			logFailure(res)
+			e.record(res)
+			e.publish(res)
+		}
+	}
+}
+
+// record updates the state of e after a flush.
+func (e *Exporter) record(res FlushResult) {
+	e.mu.Lock()
+	e.last = &res
+	e.remember(res)
+	e.account(res)
+	e.throughput(res)
+	if nil == res.Err && res.Time.After(e.succeeded) {
+		e.succeeded = res.Time
+	}
+	callback := e.budget.record(&e.c, res)
+	e.mu.Unlock()
+	if nil == res.Err && e.c.ReportGaps {
+		e.saveState()
+	}
+	if nil != callback {
+		callback()
+	}
+}
+
+func (e *Exporter) publish(res FlushResult) {
+	select {
+	case e.results <- res:
+	default:
+	}
+}
@@ -0,0 +1,138 @@
+package graphite
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestExporterResults(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+
+	metrics.GetOrRegisterCounter("foo", r).Inc(2)
+
+	wg.Add(1)
+	e := New(c)
+	e.Start()
+	result := <-e.Results()
+	wg.Wait()
+	e.Stop()
+
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if result.Lines != 1 || result.Bytes == 0 || result.Duration <= 0 {
+		t.Fatal("bad result:", result)
+	}
+	if expected, found := 2.0, res["foobar.foo"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+
+	for range e.Results() {
+	}
+}
+
+func TestExporterResultsError(t *testing.T) {
+	_, l, _, c, _ := NewTestServer(t, "foobar")
+	l.Close()
+
+	e := New(c)
+	e.Start()
+	defer e.Stop()
+
+	select {
+	case result := <-e.Results():
+		if result.Err == nil {
+			t.Fatal("expected a dial error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no result published")
+	}
+}
+
+func TestExporterLifecycle(t *testing.T) {
+	_, l, _, c, _ := NewTestServer(t, "foobar")
+	defer l.Close()
+
+	// Stopping an exporter which was never started does not block.
+	e := New(c)
+	e.Stop()
+	e.Stop()
+	e.Start()
+	if _, ok := <-e.Results(); ok {
+		t.Fatal("results of a stopped exporter")
+	}
+
+	e = New(c)
+	e.Start()
+	e.Start()
+	e.Stop()
+	e.Stop()
+	for range e.Results() {
+	}
+}
+
+func ExampleExporter() {
+	e := New(GraphiteConfig{
+		Addr:          ":2003",
+		Registry:      metrics.DefaultRegistry,
+		FlushInterval: time.Second,
+		DurationUnit:  time.Millisecond,
+		Prefix:        "some.prefix",
+	})
+	e.Start()
+	defer e.Stop()
+}
+
+func TestExporterClose(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.FlushInterval = time.Hour
+	metrics.GetOrRegisterCounter("foo", r).Inc(2)
+
+	e := New(c)
+	e.Start()
+	wg.Add(1)
+	if err := e.Close(); nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if expected, found := 2.0, res["foobar.foo"]; !floatEquals(found, expected) {
+		t.Fatal("final flush lost:", expected, found)
+	}
+
+	// The final flush gives up after CloseTimeout.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c.Addr = ln.Addr().String()
+	c.CloseTimeout = 50 * time.Millisecond
+	metrics.GetOrRegisterGauge("big", r).Update(1)
+	for i := 0; i < 100000; i++ {
+		metrics.GetOrRegisterGauge("padding."+strconv.Itoa(i), r).Update(int64(i))
+	}
+	start := time.Now()
+	if err := New(c).Close(); nil == err {
+		t.Fatal("expected a timeout")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("CloseTimeout not honored:", time.Since(start))
+	}
+}
+
+func TestExporterZeroFlushInterval(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		e := New(GraphiteConfig{Addr: "localhost:2003", Registry: metrics.NewRegistry(), FlushInterval: d})
+		if time.Minute != e.c.FlushInterval {
+			t.Fatal("flush interval not defaulted:", d, e.c.FlushInterval)
+		}
+		e.Start()
+		e.Stop()
+	}
+}
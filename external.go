@@ -0,0 +1,41 @@
+package graphite
+
+import (
+	"sort"
+	"time"
+)
+
+// ExportSnapshot sends the metrics of snap, keyed by name, which were
+// snapshotted at ts by the caller on its own cadence, such as at the end of
+// a batch of requests, rather than read from c.Registry. They go through
+// the same pipeline as the registry metrics, counter deltas, policies and
+// queued datapoints included, but are not flushed again by regular flushes,
+// which only report the registries. Metrics of unknown types are skipped.
+func (e *Exporter) ExportSnapshot(snap map[string]interface{}, ts time.Time) error {
+	snaps := make([]MetricSnapshot, 0, len(snap))
+	for name, i := range snap {
+		if s, ok := snapshot(&e.c, name, i); ok {
+			snaps = append(snaps, s)
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Name < snaps[j].Name })
+	res := e.flushSelected(e.ctx, selection{snaps: snaps, ts: ts})
+	e.record(res)
+	return res.Err
+}
+
+// keepExternal records the series of snaps, exported with ExportSnapshot, so
+// that regular flushes do not prune their state because they are not part
+// of the registries. Only the series of the last snapshot are remembered.
+func (e *Exporter) keepExternal(snaps []MetricSnapshot) {
+	seen := make(map[string]bool)
+	for _, s := range snaps {
+		seen[s.Name] = true
+		for _, f := range s.Fields {
+			seen[fieldName(s, f)] = true
+		}
+	}
+	e.mu.Lock()
+	e.external = seen
+	e.mu.Unlock()
+}
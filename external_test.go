@@ -0,0 +1,61 @@
+package graphite
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestExportSnapshot(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				return
+			}
+			r := bufio.NewReader(conn)
+			for line, err := r.ReadString('\n'); nil == err; line, err = r.ReadString('\n') {
+				lines <- line
+			}
+			conn.Close()
+		}
+	}()
+
+	e := New(GraphiteConfig{Addr: ln.Addr().String(), Registry: metrics.NewRegistry(), Prefix: "p", CounterDeltas: true})
+	c := metrics.NewCounter()
+	for i, expected := range []string{"p.jobs 5 1000\n", "p.jobs 3 1060\n"} {
+		c.Inc(int64(5 - 2*i))
+		ts := time.Unix(1000+60*int64(i), 0)
+		if err := e.ExportSnapshot(map[string]interface{}{"jobs": c.Snapshot(), "unknown": 1}, ts); nil != err {
+			t.Fatal(err)
+		}
+		select {
+		case line := <-lines:
+			if expected != line {
+				t.Fatalf("bad line: %q", line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("nothing received")
+		}
+
+		// Regular flushes neither send the series of snapshots nor prune
+		// their baselines.
+		if err := e.Flush(); nil != err {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case line := <-lines:
+		t.Fatalf("snapshot series sent by a regular flush: %q", line)
+	default:
+	}
+}
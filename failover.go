@@ -0,0 +1,55 @@
+package graphite
+
+import (
+	"context"
+	"log"
+)
+
+// endpoints returns the carbon endpoints of c, c.Addr followed by c.Addrs.
+func endpoints(c *GraphiteConfig) []string {
+	return append([]string{c.Addr}, c.Addrs...)
+}
+
+// candidates returns the endpoints a connection is attempted to in turn,
+// starting with the one at the index returned: the endpoints of c.SRV,
+// discovered again for every connection, or those of c.Addrs.
+func (e *Exporter) candidates(ctx context.Context) ([]string, int, error) {
+	if "" == e.c.SRV {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return endpoints(&e.c), e.healthy, nil
+	}
+	eps, err := e.discover(ctx)
+	return eps, 0, err
+}
+
+// Endpoint returns the endpoint flushes connect to first: the last one
+// a connection was established to, one of c.Addr and c.Addrs unless writing
+// to it failed since, or one of c.SRV.
+func (e *Exporter) Endpoint() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if "" != e.c.SRV {
+		if e.healthy < len(e.srv) {
+			return e.srv[e.healthy]
+		}
+		return e.c.SRV
+	}
+	return endpoints(&e.c)[e.healthy]
+}
+
+// failover makes flushes connect to the endpoint following the current one
+// first, after a flush failed for err to write to the current one. Errors
+// of ctx, the context of the flush, are not failures of the endpoint.
+func (e *Exporter) failover(ctx context.Context, err error) {
+	eps := endpoints(&e.c)
+	if 1 == len(eps) || "" != e.c.SRV || nil != ctx.Err() {
+		return
+	}
+	e.mu.Lock()
+	failed := e.healthy
+	e.healthy = (e.healthy + 1) % len(eps)
+	next := eps[e.healthy]
+	e.mu.Unlock()
+	log.Printf("graphite: failing over from %s to %s: %v", eps[failed], next, err)
+}
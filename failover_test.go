@@ -0,0 +1,103 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// NewFailoverExporter returns an exporter failing over between a, b and c,
+// whose dials to the addresses down fail, and the addresses dialed.
+func NewFailoverExporter(t *testing.T, down map[string]bool) (*Exporter, func() []string) {
+	var mu sync.Mutex
+	var dialed []string
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	e := New(GraphiteConfig{
+		Addr:          "a:2003",
+		Addrs:         []string{"b:2003", "c:2003"},
+		Registry:      r,
+		FlushInterval: time.Second,
+		Prefix:        "p",
+		DialFunc: func(_ context.Context, _, addr string) (net.Conn, error) {
+			mu.Lock()
+			dialed = append(dialed, addr)
+			mu.Unlock()
+			if down[addr] {
+				return nil, errors.New("unreachable")
+			}
+			client, server := net.Pipe()
+			go func() {
+				defer server.Close()
+				scanner := bufio.NewScanner(server)
+				for scanner.Scan() {
+				}
+			}()
+			return client, nil
+		},
+	})
+	return e, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		defer func() { dialed = nil }()
+		return dialed
+	}
+}
+
+func TestFailoverDial(t *testing.T) {
+	down := map[string]bool{"a:2003": true, "b:2003": true}
+	e, dialed := NewFailoverExporter(t, down)
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if found := dialed(); 3 != len(found) || "c:2003" != e.Endpoint() {
+		t.Fatal("did not fail over:", found, e.Endpoint())
+	}
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if found := dialed(); 1 != len(found) || "c:2003" != found[0] {
+		t.Fatal("healthy endpoint not remembered:", found)
+	}
+
+	down["c:2003"] = true
+	if err := e.flush().Err; nil == err {
+		t.Fatal("flush did not fail")
+	}
+	if found := dialed(); 3 != len(found) || "c:2003" != found[0] || "b:2003" != found[2] {
+		t.Fatal("endpoints not tried in turn:", found)
+	}
+}
+
+func TestFailoverWrite(t *testing.T) {
+	e, dialed := NewFailoverExporter(t, map[string]bool{})
+	e.c.WriteRetries = 1
+	var once sync.Once
+	dial := e.c.DialFunc
+	e.c.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		// The first connection is broken.
+		once.Do(func() { conn.Close() })
+		return conn, err
+	}
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if found := dialed(); 2 != len(found) || "b:2003" != found[1] || "b:2003" != e.Endpoint() {
+		t.Fatal("did not fail over after a broken write:", found, e.Endpoint())
+	}
+}
+
+func TestFailoverSingle(t *testing.T) {
+	e := New(GraphiteConfig{Addr: "a:2003"})
+	e.failover(context.Background(), errors.New("broken"))
+	if "a:2003" != e.Endpoint() {
+		t.Fatal("single endpoint failed over:", e.Endpoint())
+	}
+}
@@ -0,0 +1,107 @@
+package graphite
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxListed is the number of series a line logged by DiffFlushes lists.
+const maxListed = 20
+
+// SeriesChange is a series whose value changed dramatically between two
+// flushes, see GraphiteConfig.DiffFlushes.
+type SeriesChange struct {
+	Name string
+	Old  float64
+	New  float64
+}
+
+// FlushDiff reports how the series of a regular flush differ from those of
+// the previous one.
+type FlushDiff struct {
+	Appeared    []string       // Series missing from the previous flush
+	Disappeared []string       // Series of the previous flush missing from this one
+	Changed     []SeriesChange // Series whose value changed beyond DiffChange and DiffMinDelta
+}
+
+// Empty returns true if no series appeared, disappeared or changed.
+func (d FlushDiff) Empty() bool {
+	return 0 == len(d.Appeared) && 0 == len(d.Disappeared) && 0 == len(d.Changed)
+}
+
+// diffFlushes compares the values of the series of a flush, cur, with
+// those of the previous one, prev.
+func diffFlushes(c *GraphiteConfig, prev, cur map[string]float64) FlushDiff {
+	change := c.DiffChange
+	if change <= 0 {
+		change = 1
+	}
+	var d FlushDiff
+	for name, v := range cur {
+		old, ok := prev[name]
+		switch {
+		case !ok:
+			d.Appeared = append(d.Appeared, name)
+		case math.Abs(v-old) <= c.DiffMinDelta:
+		case math.Abs(v-old) > change*math.Abs(old):
+			d.Changed = append(d.Changed, SeriesChange{Name: name, Old: old, New: v})
+		}
+	}
+	for name := range prev {
+		if _, ok := cur[name]; !ok {
+			d.Disappeared = append(d.Disappeared, name)
+		}
+	}
+	sort.Strings(d.Appeared)
+	sort.Strings(d.Disappeared)
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Name < d.Changed[j].Name })
+	return d
+}
+
+// diff logs how dps, the datapoints of the registries for a regular flush
+// of shard, differ from those of the previous flush of shard, with
+// c.DiffFlushes. The first flush of a shard is not logged.
+func (e *Exporter) diff(shard int, dps []datapoint) {
+	if !e.c.DiffFlushes {
+		return
+	}
+	cur := make(map[string]float64, len(dps))
+	for _, dp := range dps {
+		cur[dp.path] = dp.value
+	}
+	e.mu.Lock()
+	if nil == e.previous {
+		e.previous = make(map[int]map[string]float64)
+	}
+	prev, ok := e.previous[shard]
+	e.previous[shard] = cur
+	e.mu.Unlock()
+	if !ok {
+		return
+	}
+	d := diffFlushes(&e.c, prev, cur)
+	if 0 != len(d.Appeared) {
+		log.Printf("graphite: %d series appeared since the previous flush: %s", len(d.Appeared), listed(d.Appeared))
+	}
+	if 0 != len(d.Disappeared) {
+		log.Printf("graphite: %d series disappeared since the previous flush: %s", len(d.Disappeared), listed(d.Disappeared))
+	}
+	if 0 != len(d.Changed) {
+		changes := make([]string, 0, len(d.Changed))
+		for _, ch := range d.Changed {
+			changes = append(changes, fmt.Sprintf("%s %g -> %g", ch.Name, ch.Old, ch.New))
+		}
+		log.Printf("graphite: %d series changed since the previous flush: %s", len(d.Changed), listed(changes))
+	}
+}
+
+// listed joins the first maxListed entries of names.
+func listed(names []string) string {
+	if len(names) <= maxListed {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:maxListed], ", "), len(names)-maxListed)
+}
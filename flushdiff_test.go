@@ -0,0 +1,69 @@
+package graphite
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestDiffFlushes(t *testing.T) {
+	c := &GraphiteConfig{DiffMinDelta: 1}
+	d := diffFlushes(c,
+		map[string]float64{"kept": 10, "gone": 1, "doubled": 10, "jumped": 1, "small": 0.1},
+		map[string]float64{"kept": 15, "new": 1, "doubled": 30, "jumped": 100, "small": 0.9},
+	)
+	if !reflect.DeepEqual(d.Appeared, []string{"new"}) || !reflect.DeepEqual(d.Disappeared, []string{"gone"}) {
+		t.Fatal("bad appeared or disappeared series:", d)
+	}
+	expected := []SeriesChange{{Name: "doubled", Old: 10, New: 30}, {Name: "jumped", Old: 1, New: 100}}
+	if !reflect.DeepEqual(d.Changed, expected) {
+		t.Fatal("bad changed series:", d.Changed)
+	}
+	c.DiffChange = 10
+	if d := diffFlushes(c, map[string]float64{"doubled": 10}, map[string]float64{"doubled": 30}); !d.Empty() {
+		t.Fatal("change below DiffChange reported:", d)
+	}
+}
+
+func TestDiffFlushesLogged(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	r := metrics.NewRegistry()
+	e := New(GraphiteConfig{Registry: r, FlushInterval: time.Second, Prefix: "p", DiffFlushes: true})
+	g := metrics.GetOrRegisterGauge("foo", r)
+	g.Update(1)
+	e.diff(0, e.registryDatapoints())
+	if 0 != buf.Len() {
+		t.Fatal("first flush diffed:", buf.String())
+	}
+	g.Update(50)
+	metrics.GetOrRegisterCounter("bar", r).Inc(1)
+	e.diff(0, e.registryDatapoints())
+	if out := buf.String(); !strings.Contains(out, "1 series appeared since the previous flush: p.bar") ||
+		!strings.Contains(out, "p.foo 1 -> 50") {
+		t.Fatal("diff not logged:", out)
+	}
+}
+
+func TestListed(t *testing.T) {
+	names := make([]string, maxListed+2)
+	for i := range names {
+		names[i] = "s"
+	}
+	if found := listed(names); !strings.HasSuffix(found, "s and 2 more") {
+		t.Fatal("bad list:", found)
+	}
+}
+
+// registryDatapoints returns the datapoints of the registry of e, as a
+// regular flush collects them.
+func (e *Exporter) registryDatapoints() []datapoint {
+	return datapoints(&e.c, e.snapshot(), time.Now())
+}
@@ -0,0 +1,18 @@
+package graphite
+
+import (
+	"fmt"
+)
+
+// FlushMetric immediately sends the metric called name, outside of the
+// regular schedule, for state transitions such as a leader election which
+// should not wait for the next flush. Datapoints queued by Send are sent
+// along.
+func (e *Exporter) FlushMetric(name string) error {
+	if nil == e.currentRegistry().Get(name) && (nil == e.c.HostRegistry || nil == e.c.HostRegistry.Get(name)) {
+		return fmt.Errorf("graphite: no metric called %q", name)
+	}
+	res := e.flushMatching(func(n string) bool { return n == name })
+	e.record(res)
+	return res.Err
+}
@@ -0,0 +1,30 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFlushMetric(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	metrics.GetOrRegisterGauge("leader", r).Update(1)
+	metrics.GetOrRegisterCounter("requests", r).Inc(1)
+
+	e := New(c)
+	if err := e.FlushMetric("missing"); nil == err {
+		t.Fatal("expected an error")
+	}
+	wg.Add(1)
+	if err := e.FlushMetric("leader"); nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if 1 != len(res) || 1 != res["foobar.leader"] {
+		t.Fatal("bad series:", res)
+	}
+	if 1 != len(e.DestinationStats()) {
+		t.Fatal("flush not recorded")
+	}
+}
@@ -0,0 +1,21 @@
+package graphite
+
+import (
+	"context"
+)
+
+// Flush immediately sends the registry outside of the regular schedule,
+// along with the datapoints queued by Send, and returns the error of the
+// flush. It does not require Start, which suits batch jobs and cron-style
+// workers pushing once before they exit.
+func (e *Exporter) Flush() error {
+	return e.FlushContext(e.ctx)
+}
+
+// FlushContext is Flush aborted when ctx is done. The span of the flush, if
+// c.Tracer is set, is a child of the span of ctx.
+func (e *Exporter) FlushContext(ctx context.Context) error {
+	res := e.flushContext(ctx, nil)
+	e.record(res)
+	return res.Err
+}
@@ -0,0 +1,30 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestFlush(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	metrics.GetOrRegisterCounter("foo", r).Inc(3)
+
+	e := New(c)
+	wg.Add(1)
+	if err := e.Flush(); nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if expected, found := 3.0, res["foobar.foo"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+	if 1 != len(e.DestinationStats()) {
+		t.Fatal("flush not recorded")
+	}
+
+	l.Close()
+	if err := e.Flush(); nil == err {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,90 @@
+package graphite
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync/atomic"
+)
+
+// frameHeader is the size of the header of a frame: the length of the
+// payload, its CRC-32C and the sequence number of the frame, big-endian.
+const frameHeader = 4 + 4 + 8
+
+// maxFrame is the size of the largest payload ReadFrame accepts.
+const maxFrame = 16 << 20
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrFrameChecksum is returned by ReadFrame for frames whose payload does
+// not match its checksum.
+var ErrFrameChecksum = errors.New("graphite: bad frame checksum")
+
+// Frame is a batch of plaintext lines written with c.Framed. Sequence
+// numbers start at 1 and increase by one with every frame written by an
+// exporter, reconnections included, so that receivers detect lost frames.
+type Frame struct {
+	Seq     uint64
+	Payload []byte
+}
+
+// ReadFrame reads a frame from r, for receivers of c.Framed and to verify
+// the integrity of stored frames.
+func ReadFrame(r io.Reader) (Frame, error) {
+	var h [frameHeader]byte
+	if _, err := io.ReadFull(r, h[:]); nil != err {
+		return Frame{}, err
+	}
+	n := binary.BigEndian.Uint32(h[0:])
+	if n > maxFrame {
+		return Frame{}, fmt.Errorf("graphite: frame of %d bytes too large", n)
+	}
+	f := Frame{Seq: binary.BigEndian.Uint64(h[8:]), Payload: make([]byte, n)}
+	if _, err := io.ReadFull(r, f.Payload); nil != err {
+		return Frame{}, noEOF(err)
+	}
+	if crc32.Checksum(f.Payload, crc32c) != binary.BigEndian.Uint32(h[4:]) {
+		return f, ErrFrameChecksum
+	}
+	return f, nil
+}
+
+// noEOF turns the end of a stream in the middle of a frame into an error.
+func noEOF(err error) error {
+	if io.EOF == err {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// appendFrame appends to b the frame of payload with sequence number seq.
+func appendFrame(b []byte, seq uint64, payload []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	b = binary.BigEndian.AppendUint32(b, crc32.Checksum(payload, crc32c))
+	b = binary.BigEndian.AppendUint64(b, seq)
+	return append(b, payload...)
+}
+
+// framedConn frames every write to a connection, each frame being written
+// at once so that datagrams hold a single frame.
+type framedConn struct {
+	net.Conn
+	e *Exporter
+}
+
+// Write returns the bytes of b written, which are none unless the whole
+// frame is.
+func (c framedConn) Write(b []byte) (int, error) {
+	frame := appendFrame(nil, atomic.AddUint64(&c.e.frames, 1), b)
+	n, err := c.Conn.Write(frame)
+	if nil != err {
+		return 0, err
+	}
+	if n < len(frame) {
+		return 0, io.ErrShortWrite
+	}
+	return len(b), nil
+}
@@ -0,0 +1,82 @@
+package graphite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestReadFrame(t *testing.T) {
+	frame := appendFrame(nil, 7, []byte("foo 1 1\n"))
+	f, err := ReadFrame(bytes.NewReader(frame))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 7 != f.Seq || "foo 1 1\n" != string(f.Payload) {
+		t.Fatal("bad frame:", f)
+	}
+	corrupt := append([]byte(nil), frame...)
+	corrupt[len(corrupt)-2] = '2'
+	if _, err := ReadFrame(bytes.NewReader(corrupt)); ErrFrameChecksum != err {
+		t.Fatal("corrupt frame accepted:", err)
+	}
+	if _, err := ReadFrame(bytes.NewReader(frame[:len(frame)-1])); io.ErrUnexpectedEOF != err {
+		t.Fatal("truncated frame accepted:", err)
+	}
+	if _, err := ReadFrame(bytes.NewReader(nil)); io.EOF != err {
+		t.Fatal("bad end of stream:", err)
+	}
+}
+
+func TestFramedDatagrams(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	e := New(GraphiteConfig{
+		Addr:          l.LocalAddr().String(),
+		Network:       "udp",
+		Framed:        true,
+		Registry:      metrics.NewRegistry(),
+		FlushInterval: time.Second,
+		Prefix:        "p",
+	})
+	ts := time.Unix(1, 0)
+	for i := 0; i < 200; i++ {
+		e.Send(fmt.Sprintf("metric%03d", i), float64(i), ts)
+	}
+	res := e.flush()
+	if nil != res.Err {
+		t.Fatal(res.Err)
+	}
+
+	var payloads bytes.Buffer
+	buf := make([]byte, 64<<10)
+	for seq := uint64(1); payloads.Len() < res.Bytes; seq++ {
+		l.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := l.ReadFrom(buf)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if n > maxDatagram {
+			t.Fatal("datagram too large:", n)
+		}
+		f, err := ReadFrame(bytes.NewReader(buf[:n]))
+		if nil != err {
+			t.Fatal(err)
+		}
+		if seq != f.Seq {
+			t.Fatal("bad sequence number:", seq, f.Seq)
+		}
+		payloads.Write(f.Payload)
+	}
+	if lines := bytes.Count(payloads.Bytes(), []byte{'\n'}); res.Lines != lines {
+		t.Fatal("lines lost:", lines)
+	}
+}
@@ -0,0 +1,42 @@
+package graphite
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// errStopped is returned when starting background work on a stopped
+// exporter.
+var errStopped = errors.New("graphite: exporter stopped")
+
+// Goroutines returns the number of background goroutines of e: the flush
+// loop started by Start, those of bursts and those of the relays reading
+// from their clients. It is zero once Stop returns, which lets tests assert
+// that exporters do not leak goroutines.
+func (e *Exporter) Goroutines() int {
+	return int(atomic.LoadInt64(&e.spawned))
+}
+
+// spawn runs f in a background goroutine counted by Goroutines and tracked
+// by wg. The goroutine is no longer counted once wg is done with it.
+func (e *Exporter) spawn(wg *sync.WaitGroup, f func()) {
+	atomic.AddInt64(&e.spawned, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer atomic.AddInt64(&e.spawned, -1)
+		f()
+	}()
+}
+
+// closeRelays closes the relays of e, which cannot be created anymore.
+func (e *Exporter) closeRelays() {
+	e.mu.Lock()
+	relays := e.relays
+	e.relays = nil
+	e.mu.Unlock()
+	for _, r := range relays {
+		r.Close()
+	}
+}
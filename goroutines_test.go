@@ -0,0 +1,41 @@
+package graphite
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGoroutines(t *testing.T) {
+	_, l, _, c, _ := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.FlushInterval = time.Hour
+	e := New(c)
+	e.Start()
+	e.Burst([]string{"*"}, time.Hour, time.Hour)
+	r, err := e.Relay("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", r.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The flush loop, the burst, the relay and the reader of its client.
+	deadline := time.Now().Add(5 * time.Second)
+	for 4 != e.Goroutines() {
+		if time.Now().After(deadline) {
+			t.Fatal("bad goroutine count:", e.Goroutines())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	e.Stop()
+	if n := e.Goroutines(); 0 != n {
+		t.Fatal("goroutines left after Stop:", n)
+	}
+	if _, err := e.Relay("tcp", "127.0.0.1:0"); errStopped != err {
+		t.Fatal("relay created by a stopped exporter:", err)
+	}
+}
@@ -1,26 +1,180 @@
 package graphite
 
 import (
-	"fmt"
+	"context"
+	"crypto/tls"
 	"log"
-	"net"
-	"strconv"
-	"strings"
+	"net/http"
+	"slices"
 	"time"
 
-	"bytes"
 	"github.com/rcrowley/go-metrics"
 )
 
 // GraphiteConfig provides a container with configuration parameters for
 // the Graphite exporter
 type GraphiteConfig struct {
-	Addr          string           // Network address to connect to
+	Addr          string           // Network address to connect to, or the path of a unix socket prefixed with "unix:" or "unixgram:"
+	Addrs         []string         // Endpoints failed over to in turn after Addr when dialing or writing fails, the healthy one being remembered, see Exporter.Endpoint
+	SRV           string           // DNS SRV record, such as "_carbon._tcp.metrics.internal", whose targets are dialed in order of preference instead of Addr and Addrs, looked up for every connection
+	LookupSRV     SRVLookup        // Looks up the targets of SRV, net.DefaultResolver if nil
+	Protocol      Protocol         // Protocol datapoints are sent with, plaintext if zero
+	CRLF          bool             // Terminate plaintext lines with "\r\n" rather than "\n", the last line of a payload included
+	Framed        bool             // Write plaintext in frames carrying their length, CRC-32C and a sequence number, for receivers reading them with ReadFrame
+	Network       string           // Network of Addr: "tcp" if empty, "tcp4", "tcp6", "unix", or "udp", "udp4", "udp6" and "unixgram" to send fire-and-forget datagrams split on line boundaries
+	LocalAddr     string           // Local IP address, optionally with a port, connections originate from
+	Interface     string           // Network interface connections originate from, when LocalAddr is empty
+	LocalPortMin  int              // First local port connections may originate from
+	LocalPortMax  int              // Last local port connections may originate from
+	BindToDevice  string           // Device sockets are bound to with SO_BINDTODEVICE, Linux only
+	DialFunc      DialFunc         // Dials the addresses Addr resolves to instead of LocalAddr, Interface, the port range and BindToDevice
+	DialTimeout   time.Duration    // Time a dial to one of the addresses Addr resolves to may take, 5 seconds if zero
+	WriteTimeout  time.Duration    // Time writing the datapoints of a flush may take before the flush fails, no limit if zero
+	WriteRetries  int              // Times a flush whose write failed reconnects and resumes with the datapoints left to send, none if zero
+	TLSConfig     *tls.Config      // Configuration of the TLS connections to Addr, including SNI and root CAs; plain TCP if nil
+	ClientCert    ClientCertFunc   // Client certificate presented by TLS connections, see LoadClientCert
+	DNSCacheTTL   time.Duration    // Time the addresses Addr resolves to are cached, see Exporter.Refresh
+	Resolver      Resolver         // Resolver reporting the TTL of the addresses of Addr, see Exporter.Refresh
 	Registry      metrics.Registry // Registry to be exported
-	FlushInterval time.Duration    // Flush interval
-	DurationUnit  time.Duration    // Time conversion unit for durations
+	FlushInterval time.Duration    // Flush interval, a minute if zero or negative
+	Shards        int              // Number of shards regular flushes send in turn, each every FlushInterval/Shards
+	DurationUnit  time.Duration    // Time conversion unit for durations, nanoseconds if zero
 	Prefix        string           // Prefix to be prepended to metric names
+	RunID         string           // Instance identifier appended to Prefix, see PIDRunID and RandomRunID
+	HostRegistry  metrics.Registry // Host-level metrics, exported by a single process per host
+	HostLock      string           // Name of the host lock guarding HostRegistry, see AcquireHostLock
 	Percentiles   []float64        // Percentiles to export from timers and histograms
+	APIKey        string           // API key of the hosted account, such as HostedGraphite, series are sent to unless they match APIKeys
+	APIKeys       []APIKeyRule     // API keys of the hosted accounts matching series are sent to
+
+	HTTPURL    string       // Graphite HTTP ingestion endpoint, such as Grafana Cloud's, datapoints are posted to as JSON instead of being written to Addr
+	HTTPToken  string       // Bearer token of the requests to HTTPURL, "<instance id>:<API key>" for Grafana Cloud
+	HTTPClient *http.Client // Client of the requests to HTTPURL, http.DefaultClient if nil
+
+	Tagged bool              // Send the tags of metric names as Graphite 1.1 tagged series, see TaggedName and Names
+	Tags   map[string]string // Tags added to every series with Tagged, overridden by those of metric names
+	Names  NameParser        // Parser of the path and tags of metric names with Tagged, see BraceTags
+
+	// SchemaVersion pins the fields emitted for every metric type, such as
+	// SchemaV2; SchemaV1 if zero. Unknown versions are logged by New and
+	// replaced by LatestSchema.
+	SchemaVersion int
+
+	// SummaryFields lists the only fields emitted for the metric types it
+	// holds, such as those of CompactFields, for hosted Graphite accounts
+	// billed by series. Histogram percentiles are spelled "-percentile",
+	// whatever SchemaVersion.
+	SummaryFields map[string][]string
+
+	// TimestampFunc returns the Unix timestamp of the series called name,
+	// without prefix, for a flush started at now. Series are timestamped at
+	// now if nil. It lets backfills and simulations control timestamps.
+	TimestampFunc func(name string, now time.Time) int64
+
+	// OnMetricError is called for every metric left out of a flush because
+	// snapshotting or encoding it failed, see MetricError; errors are
+	// logged if nil.
+	OnMetricError func(err *MetricError)
+
+	// DurationPrecision is the number of decimals of the timer values
+	// converted to DurationUnit; 2 if zero, or as many as needed if negative.
+	DurationPrecision int
+
+	// PercentileMethod is the method estimating the percentiles of
+	// histograms and timers, so that they match other tooling reporting
+	// the same data; PercentileDefault, that of go-metrics, if zero. The
+	// timers of go-metrics do not expose their sample and always use
+	// PercentileDefault; those of NewSlidingWindowTimer do.
+	PercentileMethod PercentileMethod
+
+	DegradedFailures int             // Failed flushes within DegradedWindow after which the exporter is degraded
+	DegradedWindow   time.Duration   // Window over which failed flushes are counted
+	OnDegraded       func(err error) // Called with the last error when the exporter becomes degraded
+	OnRecovered      func()          // Called when a degraded exporter is healthy again
+
+	Clamp                 []ClampRule      // Bounds applied to the values of matching series
+	Outliers              []OutlierRule    // Outlier detection applied to matching series
+	NegativeCounters      NegativePolicy   // How counters holding negative values are exported
+	CounterDeltas         bool             // Send counters as their increase since the previous flush
+	AnnotateCounterResets bool             // Send "<name>.reset 1" when a counter decreased since the previous flush
+	ZeroIdle              bool             // Send the counts of counters and meters as their increase since the previous flush, 0 when idle
+	RegistrySwap          SwapPolicy       // What happens to the per-series state when Exporter.SwapRegistry replaces Registry
+	Units                 []UnitConversion // Unit conversions applied to matching series
+	PercentOfTotal        []PercentOfTotal // Counter families for which shares of the total are derived
+	Transforms            []FieldTransform // Transforms applied to the fields of matching series when encoded
+	StateFile             string           // File in which per-series baselines are persisted across restarts
+	MaxQueued             int              // Maximum number of datapoints queued by Exporter.Send, 10000 if zero
+	MaxRelayed            int              // Maximum number of datapoints queued by relays, 10000 if zero
+	MemoryLimit           int              // Bytes the queues, captured payloads and per-series state may hold, unlimited if zero, see Exporter.Memory
+	ReportSeriesCounts    bool             // Send "<prefix>.exporter.series.<namespace>" series counts
+	ReportGaps            bool             // Send "<prefix>.exporter.gap-seconds" after flushes were missed, see StateFile
+	ReportThroughput      bool             // Send "<prefix>.exporter.{lines,bytes}.{one,five}-minute" rates, see Exporter.Throughput
+	ReportConfig          bool             // Send "<prefix>.exporter.config.*" series: flush interval, series count and destination hash
+	HistorySize           int              // Number of recent flush results kept for Exporter.History
+	CapturePayloads       int              // Number of recent flush payloads kept for Exporter.SupportBundle
+	Collisions            CollisionPolicy  // Value sent for datapoints of a flush sharing a series and a timestamp
+	OrderedDelivery       bool             // Guarantee datapoints of a series are delivered in timestamp order
+	ValidateLines         bool             // Drop and log the lines failing ValidateLine instead of sending them
+	Strict                bool             // Fail flushes with an InvalidLinesError if any line fails ValidateLine
+	Tracer                Tracer           // Tracer starting a span for every flush
+
+	DiffFlushes  bool    // Log the series of regular flushes which appeared, disappeared or changed dramatically since the previous one, for debugging
+	DiffChange   float64 // Change of a series, relative to its previous value, logged by DiffFlushes, 1 (100%) if zero
+	DiffMinDelta float64 // Absolute change of a series below which DiffFlushes never logs it
+
+	Thresholds        []ThresholdRule // Rules triggering an immediate export of the metrics crossing them
+	ThresholdInterval time.Duration   // Interval at which Thresholds are evaluated, a second if zero
+
+	Blackouts []BlackoutWindow // Windows during which the regular and burst flushes are paused
+
+	RetryAttempts   int           // Attempts of a failed regular flush, retried with exponential backoff and jitter; one if zero
+	RetryBackoff    time.Duration // Wait before the first retry, doubled for every other one, 100 milliseconds if zero
+	RetryMaxElapsed time.Duration // Time after which failed regular flushes are no longer retried, the interval between them if zero
+
+	BreakerFailures int           // Consecutive failed flushes after which flushes fail with ErrCircuitOpen without dialing for BreakerCooldown
+	BreakerCooldown time.Duration // Time flushes are skipped once the circuit breaker opened, a minute if zero, see Exporter.Breaker
+
+	Acknowledge Acknowledger  // Reads the acknowledgement of the relay after every flush, only acknowledged datapoints being delivered, see AckLines
+	AckTimeout  time.Duration // Time the acknowledgement of a flush is waited for, 5 seconds if zero
+
+	KeepAlive     bool          // Keep the connection open between flushes, dialing again only after a failure
+	ProbeInterval time.Duration // Interval at which connections kept open by KeepAlive are probed
+	ProbeMetric   string        // Series written by probes, with value 1; a bare newline, or an empty pickle, if empty
+
+	// TCPKeepAlive configures the TCP keep-alive probes of the connections
+	// dialed, which detect connections to a server which rebooted even when
+	// they are idle: Go's defaults if zero, disabled if Idle is negative,
+	// enabled without setting Enable otherwise. DialFunc ignores it. Only
+	// Idle applies before Go 1.23, as the keep-alive period.
+	TCPKeepAlive KeepAliveConfig
+
+	// ResolveInterval is the interval at which the host of the connection
+	// kept open by KeepAlive is resolved again, with Resolver if set, so
+	// that the exporter reconnects once the host moved to another address
+	// rather than sending to the old one forever; never if zero.
+	ResolveInterval time.Duration
+
+	SpoolDir      string        // Directory in which the datapoints of failed flushes are kept, and replayed after a successful flush, instead of queued in memory
+	SpoolMaxBytes int64         // Bytes kept in SpoolDir, the oldest datapoints being dropped first, 64 MiB if zero
+	SpoolMaxAge   time.Duration // Age after which the datapoints kept in SpoolDir are dropped, a day if zero
+
+	ReplayPayloads int // Payloads of failed flushes kept in memory without SpoolDir, resent oldest first after a successful flush
+	ReplayMaxBytes int // Bytes the payloads kept by ReplayPayloads may hold, the oldest being dropped first, 4 MiB if zero
+
+	CloseTimeout time.Duration // Time Exporter.Close waits for the final flush, 5 seconds if zero
+
+	StallTimeout time.Duration   // Time without a flush tick handled after which a Supervisor restarts the exporter, three flush intervals and at least a minute if zero
+	OnRestart    func(err error) // Called when a Supervisor restarted the exporter, with ErrStalled or the *PanicError which stopped the flush loop
+
+	// Mirrors are destinations every flush is also sent to, such as a cloud
+	// Graphite next to an on-premises carbon, each with its own connection,
+	// retries, circuit breaker, spool and replays; only their transport
+	// settings are used. Mirrors falling behind drop their oldest flushes,
+	// see DestinationStats for their failures.
+	Mirrors []GraphiteConfig
+
+	OnConnect    func(addr string)            // Called when a connection to addr is established
+	OnDisconnect func(addr string, err error) // Called when a connection to addr is closed, with the error which caused it if any
 }
 
 // Graphite is a blocking exporter function which reports metrics in r
@@ -40,75 +194,185 @@ func Graphite(r metrics.Registry, d time.Duration, prefix string, addr string) {
 // GraphiteWithConfig is a blocking exporter function just like Graphite,
 // but it takes a GraphiteConfig instead.
 func GraphiteWithConfig(c GraphiteConfig) {
-	for _ = range time.Tick(c.FlushInterval) {
-		if err := graphite(&c); nil != err {
-			log.Println(err)
-		}
-	}
+	New(c).run()
+}
+
+// GraphiteWithContext is a blocking exporter function just like
+// GraphiteWithConfig, which returns once ctx is cancelled. The flush in
+// progress, if any, is aborted.
+func GraphiteWithContext(ctx context.Context, c GraphiteConfig) {
+	e := New(c)
+	e.ctx = ctx
+	e.run()
 }
 
 // GraphiteOnce performs a single submission to Graphite, returning a
 // non-nil error on failed connections. This can be used in a loop
 // similar to GraphiteWithConfig for custom error handling.
 func GraphiteOnce(c GraphiteConfig) error {
-	return graphite(&c)
+	e := New(c)
+	defer e.releaseHostLock()
+	defer e.closeConn()
+	return e.flush().Err
 }
 
-func graphite(c *GraphiteConfig) error {
-	now := time.Now().Unix()
-	du := float64(c.DurationUnit)
-	conn, err := net.DialTimeout("tcp", c.Addr, 5*time.Second)
+// flush sends a snapshot of the registry of e and reports how it went.
+func (e *Exporter) flush() FlushResult {
+	return e.flushMatching(nil)
+}
+
+// selection selects the metrics of a flush.
+type selection struct {
+	keep  func(name string) bool // metrics of a partial flush, all of them if nil
+	shard int                    // shard of a regular flush, allShards for all of them
+	snaps []MetricSnapshot       // metrics snapshotted elsewhere, flushed instead of the registries if not nil
+	ts    time.Time              // time snaps were taken at
+	dps   []datapoint            // datapoints sent instead of the metrics if not nil, see SendDatapoints
+}
+
+// regular returns true for the selection of regular flushes, which prune
+// the per-series state, leave out bursts and send the exporter's own
+// series.
+func (sel selection) regular() bool {
+	return nil == sel.keep && nil == sel.snaps && nil == sel.dps
+}
+
+// collect returns the datapoints of a flush at ts of the metrics of sel,
+// followed by the datapoints dequeued, which are also returned on their
+// own, and a function restoring the counter baselines if the datapoints are
+// not delivered.
+func (e *Exporter) collect(sel selection, ts time.Time) (dps, queued []datapoint, restore func()) {
+	c := &e.c
+	if nil != sel.dps {
+		return slices.Clone(sel.dps), nil, func() {}
+	}
+	e.loadState()
+	var snaps []MetricSnapshot
+	switch {
+	case nil != sel.snaps:
+		snaps, ts = sel.snaps, sel.ts
+		e.keepExternal(snaps)
+	case nil != sel.keep:
+		snaps = filter(e.snapshot(), sel.keep)
+	default:
+		snaps = e.snapshot()
+		e.prune(snaps)
+		snaps = e.shard(e.withoutBursts(snaps, ts), sel.shard)
+	}
+	restore = e.checkpoint(snaps)
+	snaps = derive(c, e.process(snaps))
+	queued = e.dequeue()
+	dps = datapoints(c, snaps, ts)
+	if sel.regular() {
+		e.diff(sel.shard, dps)
+		series := e.countSeries(snaps, sel.shard)
+		if allShards == sel.shard || 0 == sel.shard {
+			dps = append(dps, e.self(series, ts)...)
+		}
+	}
+	return append(dps, queued...), queued, restore
+}
+
+// flushMatching sends the metrics for which keep returns true, or every
+// metric if keep is nil.
+func (e *Exporter) flushMatching(keep func(name string) bool) FlushResult {
+	return e.flushContext(e.ctx, keep)
+}
+
+// flushContext is flushMatching aborted when ctx is done.
+func (e *Exporter) flushContext(ctx context.Context, keep func(name string) bool) FlushResult {
+	return e.flushSelected(ctx, selection{keep: keep, shard: allShards})
+}
+
+// flushSelected is flushContext sending the metrics of sel.
+func (e *Exporter) flushSelected(ctx context.Context, sel selection) (res FlushResult) {
+	if 0 != len(e.mirrors) {
+		return e.fanFlush(ctx, sel)
+	}
+	c := &e.c
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	res.Time = time.Now()
+	defer func() { res.Duration = time.Since(res.Time) }()
+	if res.Err = e.allow(res.Time); nil != res.Err {
+		e.parkFlush(sel, res.Time)
+		return res
+	}
+	defer func() { e.trip(res) }()
+	ctx, end := e.trace(ctx)
+	defer end(&res)
+	conn, addr, err := e.acquire(ctx)
+	res.Addr = addr
 	if nil != err {
-		return err
+		res.Err = err
+		e.parkFlush(sel, res.Time)
+		return res
 	}
-	defer conn.Close()
-	buf := bytes.NewBufferString("")
-	c.Registry.Each(func(name string, i interface{}) {
-		switch metric := i.(type) {
-		case metrics.Counter:
-			buf.WriteString(fmt.Sprintf("%s.%s %d %d\n", c.Prefix, name, metric.Count(), now))
-		case metrics.Gauge:
-			buf.WriteString(fmt.Sprintf("%s.%s %d %d\n", c.Prefix, name, metric.Value(), now))
-		case metrics.GaugeFloat64:
-			buf.WriteString(fmt.Sprintf("%s.%s %f %d\n", c.Prefix, name, metric.Value(), now))
-		case metrics.Histogram:
-			h := metric.Snapshot()
-			ps := h.Percentiles(c.Percentiles)
-			buf.WriteString(fmt.Sprintf("%s.%s.count %d %d\n", c.Prefix, name, h.Count(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.min %d %d\n", c.Prefix, name, h.Min(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.max %d %d\n", c.Prefix, name, h.Max(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.mean %.2f %d\n", c.Prefix, name, h.Mean(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.std-dev %.2f %d\n", c.Prefix, name, h.StdDev(), now))
-			for psIdx, psKey := range c.Percentiles {
-				key := strings.Replace(strconv.FormatFloat(psKey*100.0, 'f', -1, 64), ".", "", 1)
-				buf.WriteString(fmt.Sprintf("%s.%s.%s-precentile %.2f %d\n", c.Prefix, name, key, ps[psIdx], now))
-			}
-		case metrics.Meter:
-			m := metric.Snapshot()
-			buf.WriteString(fmt.Sprintf("%s.%s.count %d %d\n", c.Prefix, name, m.Count(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.one-minute %.2f %d\n", c.Prefix, name, m.Rate1(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.five-minute %.2f %d\n", c.Prefix, name, m.Rate5(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.fifteen-minute %.2f %d\n", c.Prefix, name, m.Rate15(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.mean %.2f %d\n", c.Prefix, name, m.RateMean(), now))
-		case metrics.Timer:
-			t := metric.Snapshot()
-			ps := t.Percentiles(c.Percentiles)
-			buf.WriteString(fmt.Sprintf("%s.%s.count %d %d\n", c.Prefix, name, t.Count(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.min %d %d\n", c.Prefix, name, t.Min()/int64(du), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.max %d %d\n", c.Prefix, name, t.Max()/int64(du), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.mean %.2f %d\n", c.Prefix, name, t.Mean()/du, now))
-			buf.WriteString(fmt.Sprintf("%s.%s.std-dev %.2f %d\n", c.Prefix, name, t.StdDev()/du, now))
-			for psIdx, psKey := range c.Percentiles {
-				key := strings.Replace(strconv.FormatFloat(psKey*100.0, 'f', -1, 64), ".", "", 1)
-				buf.WriteString(fmt.Sprintf("%s.%s.%s-percentile %.2f %d\n", c.Prefix, name, key, ps[psIdx]/du, now))
-			}
-			buf.WriteString(fmt.Sprintf("%s.%s.one-minute %.2f %d\n", c.Prefix, name, t.Rate1(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.five-minute %.2f %d\n", c.Prefix, name, t.Rate5(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.fifteen-minute %.2f %d\n", c.Prefix, name, t.Rate15(), now))
-			buf.WriteString(fmt.Sprintf("%s.%s.mean-rate %.2f %d\n", c.Prefix, name, t.RateMean(), now))
+	// broken is the error which broke conn after the flush was delivered.
+	var broken error
+	defer func() {
+		if nil == broken {
+			broken = res.Err
 		}
+		e.release(conn, broken)
+	}()
+	stop := watch(ctx, conn)
+	defer func() { stop() }()
+	dps, queued, restore, err := e.prepare(sel, res.Time)
+	if nil != err {
+		res.Err = err
+		return res
+	}
+	if c.OrderedDelivery {
+		dps = e.order(dps)
+	}
+	res.Lines = len(dps)
+	var sent int
+	conn, sent = e.deliver(ctx, conn, &stop, &res, dps)
+	// The lines which were not written are spooled to c.SpoolDir if set, in
+	// which case the baselines they were computed from are kept.
+	parked := sent < len(dps) && e.park(dps[sent:])
+	if 0 == sent && 0 != len(dps) && !parked {
+		restore()
+		e.requeue(queued)
+		return res
+	}
+	// The lines which were not written after a partial write are queued
+	// rather than sent again along with those which were.
+	if sent < len(dps) && !parked {
+		e.requeue(dps[sent:])
+	}
+	if nil == res.Err {
+		broken = e.replay(ctx, conn)
+	}
+	e.saveState()
+	if c.OrderedDelivery {
+		e.delivered(dps[:sent])
+	}
+	return res
+}
 
-		conn.Write(buf.Bytes())
-	})
-	return nil
+// prepare returns the datapoints of a flush of sel at t, the datapoints
+// dequeued among them and the function restoring the baselines they were
+// computed from, as collect, without the invalid lines with c.Strict or
+// c.ValidateLines. With c.Strict, it fails with an InvalidLinesError if any
+// line is invalid, having restored the baselines and requeued the queued
+// datapoints.
+func (e *Exporter) prepare(sel selection, t time.Time) ([]datapoint, []datapoint, func(), error) {
+	c := &e.c
+	dps, queued, restore := e.collect(sel, t)
+	dps = collide(c.Collisions, dps, len(dps)-len(queued))
+	if c.Strict || c.ValidateLines {
+		var errs []error
+		dps, errs = validate(dps)
+		if c.Strict && 0 != len(errs) {
+			restore()
+			e.requeue(queued)
+			return nil, nil, nil, &InvalidLinesError{Errs: errs}
+		}
+		for _, err := range errs {
+			log.Println(err)
+		}
+	}
+	return dps, queued, restore, nil
 }
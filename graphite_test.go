@@ -2,6 +2,9 @@ package graphite
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"io"
 	"net"
 	"strconv"
 	"strings"
@@ -17,14 +20,12 @@ func floatEquals(a, b float64) bool {
 }
 
 func ExampleGraphite() {
-	addr, _ := net.ResolveTCPAddr("net", ":2003")
-	go Graphite(metrics.DefaultRegistry, 1*time.Second, "some.prefix", addr)
+	go Graphite(metrics.DefaultRegistry, 1*time.Second, "some.prefix", ":2003")
 }
 
 func ExampleGraphiteWithConfig() {
-	addr, _ := net.ResolveTCPAddr("net", ":2003")
 	go GraphiteWithConfig(GraphiteConfig{
-		Addr:          addr,
+		Addr:          ":2003",
 		Registry:      metrics.DefaultRegistry,
 		FlushInterval: 1 * time.Second,
 		DurationUnit:  time.Millisecond,
@@ -32,6 +33,43 @@ func ExampleGraphiteWithConfig() {
 	})
 }
 
+// fixedMeter is a metrics.Meter holding fixed values.
+type fixedMeter struct {
+	count int64
+	rate1 float64
+}
+
+func (m fixedMeter) Count() int64            { return m.count }
+func (m fixedMeter) Mark(int64)              {}
+func (m fixedMeter) Rate1() float64          { return m.rate1 }
+func (m fixedMeter) Rate5() float64          { return 0 }
+func (m fixedMeter) Rate15() float64         { return 0 }
+func (m fixedMeter) RateMean() float64       { return 0 }
+func (m fixedMeter) Snapshot() metrics.Meter { return m }
+func (m fixedMeter) Stop()                   {}
+
+// NewDiscardServer starts a server discarding what it receives, from any
+// number of connections.
+func NewDiscardServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("could not start dummy server:", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return ln
+}
+
 func NewTestServer(t *testing.T, prefix string) (map[string]float64, net.Listener, metrics.Registry, GraphiteConfig, *sync.WaitGroup) {
 	res := make(map[string]float64)
 
@@ -45,7 +83,8 @@ func NewTestServer(t *testing.T, prefix string) (map[string]float64, net.Listene
 		for {
 			conn, err := ln.Accept()
 			if err != nil {
-				t.Fatal("dummy server error:", err)
+				// The listener is closed once the test is done.
+				return
 			}
 			r := bufio.NewReader(conn)
 			line, err := r.ReadString('\n')
@@ -66,7 +105,7 @@ func NewTestServer(t *testing.T, prefix string) (map[string]float64, net.Listene
 	r := metrics.NewRegistry()
 
 	c := GraphiteConfig{
-		Addr:          ln.Addr().(*net.TCPAddr),
+		Addr:          ln.Addr().String(),
 		Registry:      r,
 		FlushInterval: 10 * time.Millisecond,
 		DurationUnit:  time.Millisecond,
@@ -83,11 +122,9 @@ func TestWrites(t *testing.T) {
 
 	metrics.GetOrRegisterCounter("foo", r).Inc(2)
 
-	// TODO: Use a mock meter rather than wasting 10s to get a QPS.
-	for i := 0; i < 10*4; i++ {
-		metrics.GetOrRegisterMeter("bar", r).Mark(1)
-		time.Sleep(250 * time.Millisecond)
-	}
+	// A fixed meter, as the rates of a real one depend on when the ticks of
+	// the global meter arbiter happen.
+	r.Register("bar", fixedMeter{count: 40, rate1: 4})
 
 	metrics.GetOrRegisterTimer("baz", r).Update(time.Second * 5)
 	metrics.GetOrRegisterTimer("baz", r).Update(time.Second * 4)
@@ -99,7 +136,7 @@ func TestWrites(t *testing.T) {
 	GraphiteOnce(c)
 	wg.Wait()
 
-	if expected, found := 2.0, res["foobar.foo.count"]; !floatEquals(found, expected) {
+	if expected, found := 2.0, res["foobar.foo"]; !floatEquals(found, expected) {
 		t.Fatal("bad value:", expected, found)
 	}
 
@@ -123,3 +160,45 @@ func TestWrites(t *testing.T) {
 		t.Fatal("bad value:", expected, found)
 	}
 }
+
+func TestGraphiteWithContext(t *testing.T) {
+	_, l, r, c, _ := NewTestServer(t, "foobar")
+	l.Close()
+	metrics.GetOrRegisterCounter("foo", r).Inc(1)
+
+	// Several flushes may happen before the context is cancelled, so they
+	// go to a server which does not count connections.
+	ln := NewDiscardServer(t)
+	defer ln.Close()
+	c.Addr = ln.Addr().String()
+	flushed := make(chan error, 1)
+	c.OnDisconnect = func(addr string, err error) {
+		select {
+		case flushed <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		GraphiteWithContext(ctx, c)
+		close(done)
+	}()
+	if err := <-flushed; nil != err {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("not stopped by the context")
+	}
+
+	// Flushes fail as soon as the context is cancelled.
+	e := New(c)
+	e.ctx = ctx
+	if err := e.flush().Err; !errors.Is(err, context.Canceled) {
+		t.Fatal("bad error:", err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package graphitetest provides utilities to exercise the Graphite exporter
+// and the carbon servers it talks to: synthetic load generation and a carbon
+// server injecting faults into its connections.
+package graphitetest
@@ -0,0 +1,233 @@
+package graphitetest
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Fault is a misbehavior a FlakyServer applies to a connection.
+type Fault int
+
+// Faults injected by FlakyServer.
+const (
+	// Healthy reads the connection until the client closes it.
+	Healthy Fault = iota
+
+	// Reset aborts the connection with a TCP reset as soon as it is accepted.
+	Reset
+
+	// SlowRead reads the connection one small chunk at a time, waiting
+	// ReadDelay between chunks, to exert back-pressure on the client.
+	SlowRead
+
+	// Partial reads PartialBytes from the connection and then resets it.
+	Partial
+
+	// Stall accepts the connection but never reads from it until the server
+	// is closed.
+	Stall
+)
+
+// FlakyServer is a carbon plaintext server which injects faults into the
+// connections it accepts, in order to deterministically test how clients
+// cope with unreliable endpoints. The nth accepted connection gets the nth
+// fault given to SetFaults; connections beyond those are healthy. Complete
+// lines received on any connection are recorded.
+//
+// ReadDelay and PartialBytes are set between NewUnstartedFlakyServer and
+// Start, never while the server runs.
+type FlakyServer struct {
+	ReadDelay    time.Duration // Delay between reads for SlowRead
+	PartialBytes int           // Number of bytes read before resetting for Partial
+
+	ln     net.Listener
+	mu     sync.Mutex
+	faults []Fault
+	conns  int
+	lines  []string
+	done   chan struct{}
+	wg     sync.WaitGroup
+	start  sync.Once
+}
+
+// NewFlakyServer starts a FlakyServer on a random local port which applies
+// faults to its first connections, with the default ReadDelay and
+// PartialBytes.
+func NewFlakyServer(faults ...Fault) (*FlakyServer, error) {
+	s, err := NewUnstartedFlakyServer(faults...)
+	if nil != err {
+		return nil, err
+	}
+	s.Start()
+	return s, nil
+}
+
+// NewUnstartedFlakyServer returns a FlakyServer listening on a random local
+// port which applies faults to its first connections, but accepts none
+// until Start is called, so that ReadDelay and PartialBytes can be set.
+func NewUnstartedFlakyServer(faults ...Fault) (*FlakyServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		return nil, err
+	}
+	return &FlakyServer{
+		ReadDelay:    10 * time.Millisecond,
+		PartialBytes: 16,
+		ln:           ln,
+		faults:       faults,
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// Start starts accepting connections. Calling Start more than once has no
+// effect.
+func (s *FlakyServer) Start() {
+	s.start.Do(func() {
+		s.wg.Add(1)
+		go s.serve()
+	})
+}
+
+// Addr returns the address the server listens on.
+func (s *FlakyServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// SetFaults resets the connection count and applies faults to the next
+// connections.
+func (s *FlakyServer) SetFaults(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = faults
+	s.conns = 0
+}
+
+// Conns returns the number of connections accepted since the last call to
+// SetFaults.
+func (s *FlakyServer) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// Lines returns a copy of every complete line received so far, without
+// their trailing newline.
+func (s *FlakyServer) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lines...)
+}
+
+// WaitLines waits until at least n lines were received or until timeout
+// elapses, and reports whether enough lines were received.
+func (s *FlakyServer) WaitLines(n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if len(s.Lines()) >= n {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Close stops the server and closes every open connection.
+func (s *FlakyServer) Close() error {
+	close(s.done)
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *FlakyServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if nil != err {
+			return
+		}
+		s.mu.Lock()
+		fault := Healthy
+		if s.conns < len(s.faults) {
+			fault = s.faults[s.conns]
+		}
+		s.conns++
+		s.mu.Unlock()
+
+		s.wg.Add(2)
+		finished := make(chan struct{})
+		go func() {
+			defer s.wg.Done()
+			select {
+			case <-s.done:
+			case <-finished:
+			}
+			conn.Close()
+		}()
+		go func() {
+			defer s.wg.Done()
+			defer close(finished)
+			s.handle(conn, fault)
+		}()
+	}
+}
+
+func (s *FlakyServer) handle(conn net.Conn, fault Fault) {
+	switch fault {
+	case Reset:
+		reset(conn)
+		return
+	case Stall:
+		<-s.done
+		return
+	}
+
+	chunk := make([]byte, 4096)
+	if SlowRead == fault {
+		chunk = chunk[:64]
+	}
+	read := 0
+	var partial []byte
+	for {
+		if SlowRead == fault {
+			select {
+			case <-s.done:
+				return
+			case <-time.After(s.ReadDelay):
+			}
+		}
+		n, err := conn.Read(chunk)
+		if Partial == fault && read+n > s.PartialBytes {
+			n = s.PartialBytes - read
+		}
+		read += n
+		for _, b := range chunk[:n] {
+			if '\n' != b {
+				partial = append(partial, b)
+				continue
+			}
+			s.mu.Lock()
+			s.lines = append(s.lines, string(partial))
+			s.mu.Unlock()
+			partial = partial[:0]
+		}
+		if Partial == fault && read >= s.PartialBytes {
+			reset(conn)
+			return
+		}
+		if nil != err {
+			return
+		}
+	}
+}
+
+// reset closes conn with a TCP reset rather than a graceful shutdown.
+func reset(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,118 @@
+package graphitetest
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+)
+
+func send(addr, payload string) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(payload)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func TestFlakyServerHealthy(t *testing.T) {
+	s, err := NewFlakyServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := send(s.Addr(), "foo 1 1\nbar 2 1\nbaz"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.WaitLines(2, time.Second) {
+		t.Fatal("lines not received:", s.Lines())
+	}
+	time.Sleep(10 * time.Millisecond)
+	if lines := s.Lines(); len(lines) != 2 || lines[0] != "foo 1 1" || lines[1] != "bar 2 1" {
+		t.Fatal("bad lines:", lines)
+	}
+}
+
+func TestFlakyServerPartial(t *testing.T) {
+	s, err := NewUnstartedFlakyServer(Partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.PartialBytes = 10
+	s.Start()
+
+	send(s.Addr(), "foo 1 1\nbar 2 1\n")
+	send(s.Addr(), "baz 3 1\n")
+	if !s.WaitLines(2, time.Second) {
+		t.Fatal("lines not received:", s.Lines())
+	}
+	lines := s.Lines()
+	sort.Strings(lines)
+	if len(lines) != 2 || lines[0] != "baz 3 1" || lines[1] != "foo 1 1" {
+		t.Fatal("bad lines:", lines)
+	}
+	if s.Conns() != 2 {
+		t.Fatal("bad connection count:", s.Conns())
+	}
+}
+
+func TestFlakyServerReset(t *testing.T) {
+	s, err := NewFlakyServer(Reset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		// The reset may already be seen while connecting.
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected the connection to be reset")
+	}
+}
+
+func TestFlakyServerStall(t *testing.T) {
+	s, err := NewFlakyServer(Stall)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := send(s.Addr(), "foo 1 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	if s.WaitLines(1, 50*time.Millisecond) {
+		t.Fatal("stalled connection was read")
+	}
+	s.Close()
+}
+
+func TestFlakyServerSlowRead(t *testing.T) {
+	s, err := NewUnstartedFlakyServer(SlowRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.ReadDelay = 50 * time.Millisecond
+	s.Start()
+
+	start := time.Now()
+	if err := send(s.Addr(), "foo 1 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.WaitLines(1, time.Second) {
+		t.Fatal("lines not received:", s.Lines())
+	}
+	if elapsed := time.Since(start); elapsed < s.ReadDelay {
+		t.Fatal("read not delayed:", elapsed)
+	}
+}
@@ -0,0 +1,138 @@
+package graphitetest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/cyberdelia/go-metrics-graphite"
+	"github.com/rcrowley/go-metrics"
+)
+
+// LoadConfig configures the synthetic metrics created by a LoadGenerator.
+type LoadConfig struct {
+	Counters int     // Number of counters
+	Gauges   int     // Number of gauges
+	Timers   int     // Number of timers
+	Churn    float64 // Fraction of metrics replaced by new ones at every tick
+	Updates  int     // Number of updates applied to every timer per tick
+	Seed     int64   // Seed of the random source, for reproducible runs
+}
+
+// LoadStats summarizes the flushes performed by LoadGenerator.Run.
+type LoadStats struct {
+	Ticks    int           // Number of ticks performed
+	Errors   int           // Number of flushes which returned an error
+	Series   int           // Number of series emitted by the last flush
+	Total    time.Duration // Cumulated time spent flushing
+	MaxFlush time.Duration // Longest flush
+}
+
+// LoadGenerator maintains a set of synthetic counters, gauges and timers in
+// a registry, updating them with random values and replacing a fraction of
+// them at every tick.
+type LoadGenerator struct {
+	c   LoadConfig
+	r   metrics.Registry
+	rnd *rand.Rand
+	ms  []synthetic
+	gen int
+}
+
+// synthetic is a metric maintained by a LoadGenerator. Its registry name is
+// made of its kind, index and the generation in which it was created.
+type synthetic struct {
+	kind  string
+	index int
+	gen   int
+}
+
+func (s synthetic) name() string {
+	return fmt.Sprintf("synthetic.%s.%d.g%d", s.kind, s.index, s.gen)
+}
+
+// NewLoadGenerator registers the metrics described by c in r.
+func NewLoadGenerator(c LoadConfig, r metrics.Registry) *LoadGenerator {
+	g := &LoadGenerator{c: c, r: r, rnd: rand.New(rand.NewSource(c.Seed))}
+	for i := 0; i < c.Counters; i++ {
+		g.ms = append(g.ms, synthetic{kind: "counter", index: i})
+	}
+	for i := 0; i < c.Gauges; i++ {
+		g.ms = append(g.ms, synthetic{kind: "gauge", index: i})
+	}
+	for i := 0; i < c.Timers; i++ {
+		g.ms = append(g.ms, synthetic{kind: "timer", index: i})
+	}
+	g.Tick()
+	return g
+}
+
+// Len returns the number of synthetic metrics maintained by g.
+func (g *LoadGenerator) Len() int {
+	return len(g.ms)
+}
+
+// Tick replaces a fraction of the metrics, as configured by Churn, and
+// updates every metric with new random values.
+func (g *LoadGenerator) Tick() {
+	g.gen++
+	for i := range g.ms {
+		if g.c.Churn > 0 && g.rnd.Float64() < g.c.Churn {
+			g.r.Unregister(g.ms[i].name())
+			g.ms[i].gen = g.gen
+		}
+		g.update(g.ms[i])
+	}
+}
+
+func (g *LoadGenerator) update(s synthetic) {
+	name := s.name()
+	switch s.kind {
+	case "counter":
+		metrics.GetOrRegisterCounter(name, g.r).Inc(g.rnd.Int63n(100))
+	case "gauge":
+		gauge := metrics.GetOrRegisterGauge(name, g.r)
+		gauge.Update(gauge.Value() + g.rnd.Int63n(21) - 10)
+	case "timer":
+		t := metrics.GetOrRegisterTimer(name, g.r)
+		n := g.c.Updates
+		if n <= 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			// Log-normal latencies centered around 10ms.
+			t.Update(time.Duration(math.Exp(g.rnd.NormFloat64()*0.5) * float64(10*time.Millisecond)))
+		}
+	}
+}
+
+// Run ticks g and exports the registry with c every interval until stop is
+// closed, and returns statistics about the flushes. c.Registry is replaced
+// by the registry of g.
+func (g *LoadGenerator) Run(c graphite.GraphiteConfig, interval time.Duration, stop <-chan struct{}) LoadStats {
+	c.Registry = g.r
+	var stats LoadStats
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return stats
+		case <-ticker.C:
+		}
+		g.Tick()
+		start := time.Now()
+		err := graphite.GraphiteOnce(c)
+		d := time.Since(start)
+		stats.Ticks++
+		stats.Total += d
+		if d > stats.MaxFlush {
+			stats.MaxFlush = d
+		}
+		if nil != err {
+			stats.Errors++
+		}
+		stats.Series = len(graphite.SeriesNames(&c))
+	}
+}
@@ -0,0 +1,71 @@
+package graphitetest
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cyberdelia/go-metrics-graphite"
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestLoadGeneratorChurn(t *testing.T) {
+	r := metrics.NewRegistry()
+	g := NewLoadGenerator(LoadConfig{Counters: 10, Gauges: 10, Timers: 10, Churn: 0.5, Seed: 1}, r)
+	if g.Len() != 30 {
+		t.Fatal("bad length:", g.Len())
+	}
+	before := r.GetAll()
+	g.Tick()
+	after := r.GetAll()
+	if len(after) != 30 {
+		t.Fatal("bad registry size:", len(after))
+	}
+	replaced := 0
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			replaced++
+		}
+	}
+	if replaced == 0 || replaced == 30 {
+		t.Fatal("bad churn:", replaced)
+	}
+}
+
+func TestLoadGeneratorRun(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(ioutil.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	g := NewLoadGenerator(LoadConfig{Counters: 5, Timers: 5}, metrics.NewRegistry())
+	stop := make(chan struct{})
+	time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+	stats := g.Run(graphite.GraphiteConfig{
+		Addr:         ln.Addr().String(),
+		DurationUnit: time.Millisecond,
+		Prefix:       "load",
+	}, 10*time.Millisecond, stop)
+
+	if stats.Ticks == 0 || stats.Errors != 0 {
+		t.Fatal("bad stats:", stats)
+	}
+	if expected := 5 + 5*9; stats.Series != expected {
+		t.Fatal("bad series count:", expected, stats.Series)
+	}
+}
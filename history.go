@@ -0,0 +1,25 @@
+package graphite
+
+// remember appends res to the history ring buffer. It needs e.mu.
+func (e *Exporter) remember(res FlushResult) {
+	if e.c.HistorySize <= 0 {
+		return
+	}
+	if len(e.history) < e.c.HistorySize {
+		e.history = append(e.history, res)
+		return
+	}
+	e.history[e.next] = res
+	e.next = (e.next + 1) % len(e.history)
+}
+
+// History returns the results of the last c.HistorySize flushes, oldest
+// first, so that health endpoints and debugging tools can show trends such
+// as payload growth or latency creep.
+func (e *Exporter) History() []FlushResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	history := make([]FlushResult, 0, len(e.history))
+	history = append(history, e.history[e.next:]...)
+	return append(history, e.history[:e.next]...)
+}
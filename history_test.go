@@ -0,0 +1,24 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	e := New(GraphiteConfig{HistorySize: 3})
+	if len(e.History()) != 0 {
+		t.Fatal("unexpected history")
+	}
+	for i := 1; i <= 5; i++ {
+		e.record(FlushResult{Lines: i})
+	}
+	history := e.History()
+	if len(history) != 3 {
+		t.Fatal("bad history size:", len(history))
+	}
+	for i, res := range history {
+		if res.Lines != i+3 {
+			t.Fatal("bad history order:", history)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package graphite
+
+import (
+	"errors"
+)
+
+// ErrLocked is returned by AcquireHostLock when another process holds the
+// lock.
+var ErrLocked = errors.New("graphite: host lock held by another process")
+
+// HostLock is an advisory lock held by at most one process per host. It is
+// used to have a single process out of several identical ones export the
+// host-level metrics of GraphiteConfig.HostRegistry. The lock is released
+// when the process exits, even abnormally.
+type HostLock struct {
+	name    string
+	release func() error
+}
+
+// AcquireHostLock acquires the host lock called name, returning ErrLocked
+// if it is held by another process. On Linux the lock is an abstract unix
+// socket, which is per network namespace rather than per host, on other
+// unix systems a lock on a file in the temporary directory. Host locks are
+// not supported on other platforms, where it always returns an error.
+func AcquireHostLock(name string) (*HostLock, error) {
+	release, err := acquireHostLock(name)
+	if nil != err {
+		return nil, err
+	}
+	return &HostLock{name: name, release: release}, nil
+}
+
+// Release releases the lock.
+func (l *HostLock) Release() error {
+	return l.release()
+}
+
+// snapshot returns the snapshot of the registry of e, along with the
+// host-level registry if e holds the host lock or manages to acquire it.
+func (e *Exporter) snapshot() []MetricSnapshot {
+	snaps := snapshotRegistry(&e.c, e.currentRegistry(), make([]MetricSnapshot, 0))
+	if nil == e.c.HostRegistry {
+		return snaps
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if nil == e.hostLock {
+		if "" == e.c.HostLock {
+			return snaps
+		}
+		l, err := AcquireHostLock(e.c.HostLock)
+		if nil != err {
+			return snaps
+		}
+		e.hostLock = l
+	}
+	return snapshotRegistry(&e.c, e.c.HostRegistry, snaps)
+}
+
+// releaseHostLock releases the host lock if e holds it.
+func (e *Exporter) releaseHostLock() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if nil != e.hostLock {
+		e.hostLock.Release()
+		e.hostLock = nil
+	}
+}
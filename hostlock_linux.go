@@ -0,0 +1,21 @@
+package graphite
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+func acquireHostLock(name string) (func() error, error) {
+	// Abstract sockets vanish along with the process holding them. They
+	// belong to a network namespace, so containers which do not share the
+	// network namespace of the host each get their own lock.
+	ln, err := net.Listen("unix", "@go-metrics-graphite/"+name)
+	if nil != err {
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return ln.Close, nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package graphite
+
+import (
+	"errors"
+)
+
+func acquireHostLock(name string) (func() error, error) {
+	return nil, errors.New("graphite: host locks are not supported on this platform")
+}
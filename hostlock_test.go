@@ -0,0 +1,47 @@
+package graphite
+
+import (
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestHostLock(t *testing.T) {
+	l, err := AcquireHostLock("test-host-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireHostLock("test-host-lock"); err != ErrLocked {
+		t.Fatal("expected ErrLocked:", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	l, err = AcquireHostLock("test-host-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Release()
+}
+
+func TestHostRegistry(t *testing.T) {
+	r, host := metrics.NewRegistry(), metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("app", r).Inc(1)
+	metrics.GetOrRegisterCounter("host", host).Inc(1)
+	c := GraphiteConfig{Registry: r, HostRegistry: host, HostLock: "test-host-registry"}
+
+	first, second := New(c), New(c)
+	defer first.releaseHostLock()
+	defer second.releaseHostLock()
+	if snaps := first.snapshot(); len(snaps) != 2 {
+		t.Fatal("host registry not exported:", snaps)
+	}
+	if snaps := second.snapshot(); len(snaps) != 1 {
+		t.Fatal("host registry exported twice:", snaps)
+	}
+
+	first.releaseHostLock()
+	if snaps := second.snapshot(); len(snaps) != 2 {
+		t.Fatal("host lock not taken over:", snaps)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build unix && !linux
+
+package graphite
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+func acquireHostLock(name string) (func() error, error) {
+	f, err := os.OpenFile(filepath.Join(os.TempDir(), "go-metrics-graphite-"+name+".lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if nil != err {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); nil != err {
+		f.Close()
+		if syscall.EWOULDBLOCK == err {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return f.Close, nil
+}
@@ -0,0 +1,143 @@
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cyberdelia/go-metrics-graphite/carbonparse"
+)
+
+// httpMetric is a datapoint of the JSON body of Graphite HTTP ingestion
+// endpoints such as Grafana Cloud's.
+type httpMetric struct {
+	Name     string   `json:"name"`
+	Interval int      `json:"interval"`
+	Value    float64  `json:"value"`
+	Time     int64    `json:"time"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// httpConn is the connection of a flush posting its lines to c.HTTPURL.
+// Every write posts the lines it holds, so that the lines written before a
+// failed request are not sent again, like those of a partial write.
+type httpConn struct {
+	e   *Exporter
+	ctx context.Context
+
+	mu       sync.Mutex // protects deadline
+	deadline time.Time  // deadline of the requests, none if zero
+}
+
+// dialHTTP returns the connection of a flush to c.HTTPURL along with the
+// host of the URL.
+func (e *Exporter) dialHTTP(ctx context.Context) (net.Conn, string, error) {
+	u, err := url.Parse(e.c.HTTPURL)
+	if nil != err {
+		return nil, e.c.HTTPURL, err
+	}
+	if nil != e.c.OnConnect {
+		e.c.OnConnect(e.c.HTTPURL)
+	}
+	return &httpConn{e: e, ctx: ctx}, u.Host, nil
+}
+
+func (h *httpConn) Write(b []byte) (int, error) {
+	c := &h.e.c
+	if ProtocolPickle == c.Protocol {
+		return 0, errors.New("graphite: the pickle protocol cannot be posted to HTTPURL")
+	}
+	interval := int(c.FlushInterval / time.Second)
+	if interval < 1 {
+		interval = 1
+	}
+	// Lines are posted up to the first one the endpoint would reject, which
+	// fails the write like a partial one.
+	body := make([]httpMetric, 0)
+	var n int
+	var rejected error
+	for n < len(b) {
+		line, _, _ := bytes.Cut(b[n:], []byte("\n"))
+		if 0 == len(bytes.TrimSpace(line)) {
+			n += len(line) + 1
+			continue
+		}
+		d, err := carbonparse.ParseLine(string(line))
+		if nil != err {
+			rejected = fmt.Errorf("graphite: line not posted to %s: %w", c.HTTPURL, err)
+			break
+		}
+		n += len(line) + 1
+		m := httpMetric{Name: d.Path, Interval: interval, Value: d.Value, Time: d.Timestamp}
+		for _, k := range sortedKeys(d.Tags) {
+			m.Tags = append(m.Tags, k+"="+d.Tags[k])
+		}
+		body = append(body, m)
+	}
+	n = min(n, len(b))
+	if 0 == len(body) {
+		return n, rejected
+	}
+	data, err := json.Marshal(body)
+	if nil != err {
+		return 0, err
+	}
+	ctx := h.ctx
+	h.mu.Lock()
+	deadline := h.deadline
+	h.mu.Unlock()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.HTTPURL, bytes.NewReader(data))
+	if nil != err {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if "" != c.HTTPToken {
+		req.Header.Set("Authorization", "Bearer "+c.HTTPToken)
+	}
+	client := c.HTTPClient
+	if nil == client {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("graphite: %s: %s: %s", c.HTTPURL, resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return n, rejected
+}
+
+// Read fails since HTTP ingestion endpoints are not read from.
+func (h *httpConn) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (h *httpConn) Close() error                    { return nil }
+func (h *httpConn) LocalAddr() net.Addr             { return nil }
+func (h *httpConn) RemoteAddr() net.Addr            { return nil }
+func (h *httpConn) SetDeadline(t time.Time) error   { return h.SetWriteDeadline(t) }
+func (h *httpConn) SetReadDeadline(time.Time) error { return nil }
+
+// SetWriteDeadline sets the deadline of the requests of the next writes,
+// such as that of c.WriteTimeout.
+func (h *httpConn) SetWriteDeadline(t time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deadline = t
+	return nil
+}
@@ -0,0 +1,100 @@
+package graphite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestHTTP(t *testing.T) {
+	var auth string
+	var body []httpMetric
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("load;host=a", r).Update(2)
+	e := New(GraphiteConfig{
+		HTTPURL:       s.URL + "/graphite/metrics",
+		HTTPToken:     "123:key",
+		KeepAlive:     true,
+		Registry:      r,
+		FlushInterval: 10 * time.Second,
+		Prefix:        "p",
+		Tagged:        true,
+	})
+	if err := e.Flush(); nil != err {
+		t.Fatal(err)
+	}
+	if "Bearer 123:key" != auth {
+		t.Fatal("bad authorization:", auth)
+	}
+	if 1 != len(body) {
+		t.Fatal("bad body:", body)
+	}
+	expected := httpMetric{Name: "p.load", Interval: 10, Value: 2, Time: body[0].Time, Tags: []string{"host=a"}}
+	if !reflect.DeepEqual(expected, body[0]) || 0 == body[0].Time {
+		t.Fatal("bad metric:", body[0])
+	}
+
+	// Datapoints of failed requests are sent again.
+	status = http.StatusUnauthorized
+	e.Send("marker", 1, time.Unix(1000, 0))
+	if err := e.Flush(); nil == err {
+		t.Fatal("failed request did not fail the flush")
+	}
+	status = http.StatusOK
+	if err := e.Flush(); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(body) || "p.marker" != body[1].Name || 1000 != body[1].Time {
+		t.Fatal("datapoints of the failed request lost:", body)
+	}
+}
+
+func TestHTTPRejectedLine(t *testing.T) {
+	var body []httpMetric
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer s.Close()
+	h := &httpConn{e: New(GraphiteConfig{HTTPURL: s.URL}), ctx: context.Background()}
+	b := []byte("foo 1 1000\nbar baz 2 1000\nqux 3 1000\n")
+	n, err := h.Write(b)
+	if nil == err || 11 != n {
+		t.Fatal("rejected line counted as written:", n, err)
+	}
+	if 1 != len(body) || "foo" != body[0].Name {
+		t.Fatal("bad body:", body)
+	}
+}
+
+func TestHTTPWriteTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer s.Close()
+	defer close(unblock)
+	e := New(GraphiteConfig{HTTPURL: s.URL, Registry: metrics.NewRegistry(), WriteTimeout: 50 * time.Millisecond})
+	e.Send("foo", 1, time.Now())
+	start := time.Now()
+	if err := e.Flush(); nil == err {
+		t.Fatal("stuck request did not fail the flush")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatal("WriteTimeout ignored:", d)
+	}
+}
@@ -0,0 +1,24 @@
+package graphite
+
+// zeroFill returns the value to send for f, a field of s, with ZeroIdle:
+// the increase of the count of a counter or a meter since the previous
+// flush, 0 for an idle series instead of its cumulative count, recording
+// the count for the next one. Counters sent as CounterDeltas already are
+// increases and other fields are returned as is. It needs e.mu.
+func (e *Exporter) zeroFill(s MetricSnapshot, f Field) float64 {
+	switch {
+	case TypeCounter == s.Type && "" == f.Name && !e.c.CounterDeltas:
+	case TypeMeter == s.Type && "count" == f.Name:
+	default:
+		return f.Value
+	}
+	if nil == e.counts {
+		e.counts = make(map[string]float64)
+	}
+	previous, ok := e.counts[s.Name]
+	e.counts[s.Name] = f.Value
+	if !ok || f.Value < previous {
+		return f.Value
+	}
+	return f.Value - previous
+}
@@ -0,0 +1,73 @@
+package graphite
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestZeroIdle(t *testing.T) {
+	for _, zero := range []bool{false, true} {
+		e := New(GraphiteConfig{ZeroIdle: zero})
+		for i, tc := range []struct {
+			count          int64
+			counter, meter float64
+		}{
+			{5, 5, 5},
+			{5, 0, 0},
+			{7, 2, 2},
+			{7, 0, 0},
+			{3, 3, 3},
+		} {
+			snaps := e.process([]MetricSnapshot{
+				NewCounterSnapshot("c", tc.count),
+				NewMeterSnapshot("m", tc.count, Rates{1, 1, 1, 1}),
+				NewGaugeSnapshot("g", tc.count),
+			})
+			counter, meter := tc.counter, tc.meter
+			if !zero {
+				counter, meter = float64(tc.count), float64(tc.count)
+			}
+			if found := snaps[0].Fields[0].Value; !floatEquals(found, counter) {
+				t.Error("bad counter:", zero, i, counter, found)
+			}
+			if found := snaps[1].Fields[0].Value; !floatEquals(found, meter) {
+				t.Error("bad meter count:", zero, i, meter, found)
+			}
+			if !floatEquals(snaps[1].Fields[1].Value, 1) || !floatEquals(snaps[2].Fields[0].Value, float64(tc.count)) {
+				t.Error("bad rates or gauge:", zero, i, snaps[1].Fields, snaps[2].Fields)
+			}
+		}
+	}
+}
+
+func TestZeroIdleCounterDeltas(t *testing.T) {
+	e := New(GraphiteConfig{ZeroIdle: true, CounterDeltas: true, AnnotateCounterResets: true})
+	for i, expected := range [][]float64{{5}, {0}, {2}, {3, 1}} {
+		snaps := e.process([]MetricSnapshot{NewCounterSnapshot("c", []int64{5, 5, 7, 3}[i])})
+		var found []float64
+		for _, f := range snaps[0].Fields {
+			found = append(found, f.Value)
+		}
+		if !slices.Equal(expected, found) {
+			t.Error("bad counter:", i, expected, found)
+		}
+	}
+}
+
+func TestZeroIdleUndelivered(t *testing.T) {
+	e := New(GraphiteConfig{ZeroIdle: true})
+	e.process([]MetricSnapshot{NewCounterSnapshot("c", 1)})
+	snaps := []MetricSnapshot{NewCounterSnapshot("c", 2)}
+	restore := e.checkpoint(snaps)
+	e.process(snaps)
+	restore()
+	snaps = e.process([]MetricSnapshot{NewCounterSnapshot("c", 2)})
+	if found := snaps[0].Fields[0].Value; !floatEquals(found, 1) {
+		t.Fatal("undelivered increase lost:", found)
+	}
+
+	e.prune(nil)
+	if 0 != len(e.counts) {
+		t.Fatal("idle counts not pruned:", e.counts)
+	}
+}
@@ -0,0 +1,54 @@
+package graphite
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// MetricError reports a metric left out of a snapshot or a payload because
+// snapshotting or encoding it failed, such as a metric of a custom type
+// panicking, so that a single metric never fails nor corrupts a flush.
+type MetricError struct {
+	Name string // Name of the metric
+	Err  error
+}
+
+func (e *MetricError) Error() string {
+	return fmt.Sprintf("graphite: metric %q skipped: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the error of the metric.
+func (e *MetricError) Unwrap() error {
+	return e.Err
+}
+
+// isolate calls f for the metric called name, returning a MetricError
+// reported to c.OnMetricError if f panicked.
+func isolate(c *GraphiteConfig, name string, f func()) (err error) {
+	defer func() {
+		if r := recover(); nil != r {
+			err = skipMetric(c, name, fmt.Errorf("panic: %v", r))
+		}
+	}()
+	f()
+	return nil
+}
+
+// skipMetric reports err, the failure of the metric called name, to
+// c.OnMetricError, or logs it if nil.
+func skipMetric(c *GraphiteConfig, name string, err error) error {
+	merr := &MetricError{Name: name, Err: err}
+	if nil != c.OnMetricError {
+		c.OnMetricError(merr)
+	} else {
+		log.Println(merr)
+	}
+	return merr
+}
+
+// brokenPath returns true if path would break the lines following it in a
+// plaintext payload.
+func brokenPath(path string) bool {
+	return strings.ContainsAny(path, "\r\n")
+}
@@ -0,0 +1,65 @@
+package graphite
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// panickingGauge is a gauge of a custom type whose Value panics.
+type panickingGauge struct{ metrics.Gauge }
+
+func (panickingGauge) Value() int64 { panic("broken gauge") }
+
+func TestMetricErrors(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	metrics.GetOrRegisterGauge("a", r).Update(1)
+	r.Register("broken", panickingGauge{})
+	metrics.GetOrRegisterGauge("bad\nname", r).Update(2)
+	metrics.GetOrRegisterGauge("transformed", r).Update(3)
+	metrics.GetOrRegisterGauge("z", r).Update(4)
+	c.Transforms = []FieldTransform{{Pattern: "transformed", Func: func(Field) Field { panic("broken transform") }}}
+	var errs []*MetricError
+	c.OnMetricError = func(err *MetricError) { errs = append(errs, err) }
+	e := New(c)
+
+	wg.Add(1)
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if !floatEquals(res["foobar.a"], 1) || !floatEquals(res["foobar.z"], 4) {
+		t.Fatal("metrics lost:", res)
+	}
+	for name := range res {
+		if strings.Contains(name, "broken") || strings.Contains(name, "transformed") || strings.Contains(name, "name") {
+			t.Fatal("failed metric sent:", name)
+		}
+	}
+	names := make([]string, 0)
+	for _, err := range errs {
+		names = append(names, err.Name)
+	}
+	if "broken bad\nname transformed" != strings.Join(names, " ") {
+		t.Fatal("bad metric errors:", names)
+	}
+}
+
+func TestMetricErrorUnwrap(t *testing.T) {
+	cause := errors.New("cause")
+	c := &GraphiteConfig{OnMetricError: func(*MetricError) {}}
+	err := skipMetric(c, "foo", cause)
+	if !errors.Is(err, cause) || !strings.Contains(err.Error(), `"foo"`) {
+		t.Fatal("bad metric error:", err)
+	}
+	if err := isolate(c, "foo", func() {}); nil != err {
+		t.Fatal(err)
+	}
+	if 0 != len(datapoints(c, []MetricSnapshot{NewGaugeSnapshot("foo\r", 1)}, time.Now())) {
+		t.Fatal("broken series encoded")
+	}
+}
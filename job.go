@@ -0,0 +1,40 @@
+package graphite
+
+import (
+	"time"
+)
+
+// RunAndReport runs f, a short-lived job, and reports its outcome along
+// with the registry in a final synchronous flush before returning, in the
+// manner of a push gateway. The following series are sent under the job
+// name:
+//
+//	<job>.duration      time spent in f, in DurationUnit
+//	<job>.success       1 if f returned nil, 0 otherwise
+//	<job>.last-success  time f succeeded, as a Unix timestamp
+//
+// It returns the error of f, or the error of the flush if f succeeded.
+func (e *Exporter) RunAndReport(job string, f func() error) error {
+	start := time.Now()
+	err := f()
+	end := time.Now()
+
+	du := e.c.DurationUnit
+	if du <= 0 {
+		du = time.Nanosecond
+	}
+	e.Send(job+".duration", float64(end.Sub(start))/float64(du), end)
+	if nil != err {
+		e.Send(job+".success", 0, end)
+	} else {
+		e.Send(job+".success", 1, end)
+		e.Send(job+".last-success", float64(end.Unix()), end)
+	}
+
+	res := e.flush()
+	e.record(res)
+	if nil != err {
+		return err
+	}
+	return res.Err
+}
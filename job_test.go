@@ -0,0 +1,38 @@
+package graphite
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunAndReport(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+
+	e := New(c)
+	wg.Add(1)
+	if err := e.RunAndReport("job", func() error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if res["foobar.job.duration"] < 10 || res["foobar.job.success"] != 1 || res["foobar.job.last-success"] == 0 {
+		t.Fatal("bad report:", res)
+	}
+
+	fail := errors.New("fail")
+	wg.Add(1)
+	if err := e.RunAndReport("failing", func() error { return fail }); err != fail {
+		t.Fatal("bad error:", err)
+	}
+	wg.Wait()
+	if _, ok := res["foobar.failing.success"]; !ok {
+		t.Fatal("missing failure report:", res)
+	}
+	if _, ok := res["foobar.failing.last-success"]; ok {
+		t.Fatal("unexpected last success:", res)
+	}
+}
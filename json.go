@@ -0,0 +1,68 @@
+package graphite
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// SnapshotJSON returns the current snapshot of c.Registry, as returned by
+// Snapshot, encoded as JSON.
+func SnapshotJSON(c *GraphiteConfig) ([]byte, error) {
+	return json.Marshal(Snapshot(c))
+}
+
+// jsonField is the JSON encoding of a Field, whose value is either a number
+// or, for values JSON numbers cannot represent, one of the strings "NaN",
+// "+Inf" and "-Inf".
+type jsonField struct {
+	Name  string      `json:"name,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON encodes f, writing non-finite values as strings.
+func (f Field) MarshalJSON() ([]byte, error) {
+	j := jsonField{Name: f.Name, Value: f.Value}
+	if math.IsNaN(f.Value) || math.IsInf(f.Value, 0) {
+		j.Value = strconv.FormatFloat(f.Value, 'f', -1, 64)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes f as encoded by MarshalJSON.
+func (f *Field) UnmarshalJSON(b []byte) error {
+	var j jsonField
+	if err := json.Unmarshal(b, &j); nil != err {
+		return err
+	}
+	f.Name = j.Name
+	switch v := j.Value.(type) {
+	case float64:
+		f.Value = v
+	case string:
+		value, err := strconv.ParseFloat(v, 64)
+		if nil != err || !(math.IsNaN(value) || math.IsInf(value, 0)) {
+			return fmt.Errorf("graphite: invalid field value %q", v)
+		}
+		f.Value = value
+	default:
+		return fmt.Errorf("graphite: invalid field value %v", v)
+	}
+	return nil
+}
+
+// SnapshotHandler returns an http.Handler which responds with the current
+// snapshot of c.Registry encoded as JSON.
+func SnapshotHandler(c GraphiteConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := SnapshotJSON(&c)
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
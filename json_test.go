@@ -0,0 +1,84 @@
+package graphite
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSnapshotJSON(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("foo", r).Inc(2)
+	metrics.GetOrRegisterTimer("bar", r).Update(5 * time.Millisecond)
+
+	b, err := SnapshotJSON(&GraphiteConfig{
+		Registry:     r,
+		DurationUnit: time.Millisecond,
+		Percentiles:  []float64{0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var snaps []MetricSnapshot
+	if err := json.Unmarshal(b, &snaps); err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 {
+		t.Fatal("bad snapshot count:", len(snaps))
+	}
+	if snaps[0].Name != "bar" || snaps[0].Type != TypeTimer {
+		t.Fatal("bad snapshot:", snaps[0])
+	}
+	if expected, found := 5.0, snaps[0].Fields[2].Value; snaps[0].Fields[2].Name != "max" || !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+	if snaps[1].Name != "foo" || snaps[1].Type != TypeCounter || !floatEquals(snaps[1].Fields[0].Value, 2) {
+		t.Fatal("bad snapshot:", snaps[1])
+	}
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(3)
+
+	w := httptest.NewRecorder()
+	SnapshotHandler(GraphiteConfig{Registry: r}).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatal("bad content type:", ct)
+	}
+	if expected, found := `[{"name":"foo","type":"gauge","fields":[{"value":3}]}]`, w.Body.String(); expected != found {
+		t.Fatal("bad body:", expected, found)
+	}
+}
+
+func TestSnapshotJSONNonFinite(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGaugeFloat64("nan", r).Update(math.NaN())
+	metrics.GetOrRegisterGaugeFloat64("inf", r).Update(math.Inf(-1))
+	metrics.GetOrRegisterGaugeFloat64("ok", r).Update(1.5)
+
+	b, err := SnapshotJSON(&GraphiteConfig{Registry: r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"name":"inf","type":"gauge_float64","fields":[{"value":"-Inf"}]},{"name":"nan","type":"gauge_float64","fields":[{"value":"NaN"}]},{"name":"ok","type":"gauge_float64","fields":[{"value":1.5}]}]`; expected != string(b) {
+		t.Fatal("bad JSON:", string(b))
+	}
+	var snaps []MetricSnapshot
+	if err := json.Unmarshal(b, &snaps); err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(snaps[0].Fields[0].Value, -1) || !math.IsNaN(snaps[1].Fields[0].Value) || 1.5 != snaps[2].Fields[0].Value {
+		t.Fatal("bad round trip:", snaps)
+	}
+	var f Field
+	if err := json.Unmarshal([]byte(`{"value":"1"}`), &f); nil == err {
+		t.Fatal("finite value accepted as a string")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build go1.23
+
+package graphite
+
+import (
+	"net"
+)
+
+// KeepAliveConfig configures the TCP keep-alive probes of the connections
+// dialed, see GraphiteConfig.TCPKeepAlive.
+type KeepAliveConfig = net.KeepAliveConfig
+
+// tcpKeepAlive configures the keep-alive probes of the connections of d
+// according to k.
+func tcpKeepAlive(d *net.Dialer, k KeepAliveConfig) {
+	switch {
+	case k.Idle < 0:
+		d.KeepAlive = -1
+	case (KeepAliveConfig{}) != k:
+		k.Enable = true
+		d.KeepAliveConfig = k
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !go1.23
+
+package graphite
+
+import (
+	"net"
+	"time"
+)
+
+// KeepAliveConfig configures the TCP keep-alive probes of the connections
+// dialed, see GraphiteConfig.TCPKeepAlive. It has the fields of the
+// net.KeepAliveConfig of Go 1.23, only Idle being applied before it: as
+// the keep-alive period.
+type KeepAliveConfig struct {
+	Enable   bool
+	Idle     time.Duration
+	Interval time.Duration
+	Count    int
+}
+
+// tcpKeepAlive configures the keep-alive probes of the connections of d
+// according to k.
+func tcpKeepAlive(d *net.Dialer, k KeepAliveConfig) {
+	if 0 != k.Idle {
+		d.KeepAlive = max(k.Idle, -1)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build go1.23
+
+package graphite
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPKeepAlive(t *testing.T) {
+	for _, tc := range []struct {
+		config    net.KeepAliveConfig
+		keepAlive time.Duration
+		expected  net.KeepAliveConfig
+	}{
+		{net.KeepAliveConfig{}, 0, net.KeepAliveConfig{}},
+		{net.KeepAliveConfig{Idle: -1}, -1, net.KeepAliveConfig{}},
+		{net.KeepAliveConfig{Idle: time.Minute, Count: 3}, 0, net.KeepAliveConfig{Enable: true, Idle: time.Minute, Count: 3}},
+	} {
+		e := New(GraphiteConfig{Addr: "carbon:2003", TCPKeepAlive: tc.config})
+		d, err := e.dialer()
+		if nil != err {
+			t.Fatal(err)
+		}
+		if tc.keepAlive != d.KeepAlive || tc.expected != d.KeepAliveConfig {
+			t.Error("bad keep-alive:", tc.config, d.KeepAlive, d.KeepAliveConfig)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package graphite
+
+import "unsafe"
+
+// entryOverhead is the estimated number of bytes a map entry takes besides
+// its key and value.
+const entryOverhead = 48
+
+// MemoryStats is the memory the exporter holds, estimated in bytes.
+type MemoryStats struct {
+	Queued   int   // Datapoints queued by Send and relays
+	Payloads int   // Payloads captured for support bundles
+	Replays  int   // Payloads of failed flushes kept by c.ReplayPayloads
+	State    int   // Per-series state: counter baselines, idle counts, outlier statistics, thresholds and deliveries
+	Dropped  int64 // Datapoints and payloads dropped to stay within c.MemoryLimit
+}
+
+// Total returns the bytes held by the exporter.
+func (m MemoryStats) Total() int {
+	return m.Queued + m.Payloads + m.Replays + m.State
+}
+
+// Memory returns the memory held by e. The per-series state is measured by
+// every regular flush.
+func (e *Exporter) Memory() MemoryStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.memory()
+}
+
+// memory is Memory for callers holding e.mu.
+func (e *Exporter) memory() MemoryStats {
+	m := MemoryStats{Queued: e.queued, Replays: e.replayed, State: e.state, Dropped: e.memDrops}
+	for _, p := range e.payloads {
+		m.Payloads += len(p.data) + int(unsafe.Sizeof(p))
+	}
+	return m
+}
+
+// size returns the bytes held by dp.
+func (dp datapoint) size() int {
+	return len(dp.path) + int(unsafe.Sizeof(dp))
+}
+
+// measure updates the bytes held by the per-series state. It needs e.mu.
+func (e *Exporter) measure() {
+	n := 0
+	for name := range e.baselines {
+		n += len(name) + 8 + entryOverhead
+	}
+	for name := range e.counts {
+		n += len(name) + 8 + entryOverhead
+	}
+	for name := range e.outliers {
+		n += len(name) + int(unsafe.Sizeof(runningStats{})) + entryOverhead
+	}
+	for key := range e.crossed {
+		n += len(key.name) + int(unsafe.Sizeof(key)) + entryOverhead
+	}
+	for path := range e.latest {
+		n += len(path) + int(unsafe.Sizeof(delivery{})) + entryOverhead
+	}
+	e.state = n
+}
+
+// enforce drops what e holds beyond c.MemoryLimit: the oldest captured
+// payloads first, then the oldest payloads of failed flushes, the oldest
+// datapoints queued by relays and lastly those queued by Send. The
+// per-series state is never dropped, since deltas and ordering depend on
+// it, but counts towards the limit. It needs e.mu.
+func (e *Exporter) enforce() {
+	limit := e.c.MemoryLimit
+	if limit <= 0 {
+		return
+	}
+	m := e.memory()
+	if m.Total() <= limit {
+		return
+	}
+	if 0 != len(e.payloads) {
+		e.payloads = append(append([]payload(nil), e.payloads[e.nextPay:]...), e.payloads[:e.nextPay]...)
+		e.nextPay = 0
+		for 0 != len(e.payloads) && m.Total() > limit {
+			m.Payloads -= len(e.payloads[0].data) + int(unsafe.Sizeof(e.payloads[0]))
+			e.payloads = e.payloads[1:]
+			e.memDrops++
+		}
+	}
+	for 0 != len(e.replays) && m.Total() > limit {
+		n := payloadSize(e.replays[0])
+		m.Replays -= n
+		e.replayed -= n
+		e.memDrops += int64(len(e.replays[0]))
+		e.replays = e.replays[1:]
+	}
+	for _, q := range []*[]datapoint{&e.relayed, &e.queue} {
+		n := 0
+		for queued := e.queued; n < len(*q) && queued+m.Payloads+m.Replays+m.State > limit; n++ {
+			queued -= (*q)[n].size()
+		}
+		e.memDrops += int64(n)
+		*q = e.drop(*q, n)
+	}
+}
@@ -0,0 +1,68 @@
+package graphite
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimitQueue(t *testing.T) {
+	e := New(GraphiteConfig{Prefix: "p"})
+	e.Send("a", 1, time.Now())
+	size := e.Memory().Queued
+	if 0 == size {
+		t.Fatal("queued datapoint not accounted for")
+	}
+
+	e = New(GraphiteConfig{Prefix: "p", MemoryLimit: 10 * size})
+	start := time.Unix(1000, 0)
+	for i := 0; i < 100; i++ {
+		e.Send("a", float64(i), start.Add(time.Duration(i)*time.Second))
+	}
+	m := e.Memory()
+	if 10 != len(e.queue) || 90 != m.Dropped || m.Queued != 10*size {
+		t.Fatal("queue beyond the memory limit:", len(e.queue), m)
+	}
+	if expected, found := 90.0, e.queue[0].value; !floatEquals(found, expected) {
+		t.Fatal("newest datapoints dropped:", expected, found)
+	}
+
+	dps := e.dequeue()
+	if 0 != e.Memory().Queued {
+		t.Fatal("dequeued datapoints accounted for:", e.Memory())
+	}
+	e.requeue(dps)
+	if m := e.Memory(); m.Queued != 10*size || 90 != m.Dropped {
+		t.Fatal("requeued datapoints badly accounted for:", m)
+	}
+}
+
+func TestMemoryLimitPayloads(t *testing.T) {
+	e := New(GraphiteConfig{CapturePayloads: 10})
+	data := bytes.Repeat([]byte("x"), 1000)
+	e.capture(time.Unix(0, 0), data)
+	size := e.Memory().Payloads
+
+	e = New(GraphiteConfig{CapturePayloads: 10, MemoryLimit: 3 * size})
+	for i := 0; i < 10; i++ {
+		e.capture(time.Unix(int64(i), 0), data)
+	}
+	if m := e.Memory(); 3 != len(e.payloads) || 7 != m.Dropped || m.Total() > 3*size {
+		t.Fatal("payloads beyond the memory limit:", len(e.payloads), m)
+	}
+	for i, p := range e.payloads {
+		if expected, found := int64(7+i), p.time.Unix(); expected != found {
+			t.Fatal("newest payloads dropped:", i, expected, found)
+		}
+	}
+}
+
+func TestMemoryState(t *testing.T) {
+	e := New(GraphiteConfig{CounterDeltas: true})
+	snaps := []MetricSnapshot{NewCounterSnapshot("foo", 1)}
+	e.process(snaps)
+	e.prune(snaps)
+	if 0 == e.Memory().State {
+		t.Fatal("counter baselines not accounted for")
+	}
+}
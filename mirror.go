@@ -0,0 +1,204 @@
+package graphite
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// mirrorBacklog is the number of flushes waiting to be sent to a mirror,
+// the oldest being parked or dropped first when it falls behind.
+const mirrorBacklog = 4
+
+// mirror is a destination of GraphiteConfig.Mirrors, an exporter used only
+// for its connection, retries, circuit breaker, spool and replays.
+type mirror struct {
+	e       *Exporter
+	pending chan mirrored
+}
+
+// mirrored is a flush waiting to be sent to a mirror.
+type mirrored struct {
+	t   time.Time
+	dps []datapoint
+}
+
+// mirrors returns the mirrors of c.
+func mirrors(c *GraphiteConfig) []*mirror {
+	ms := make([]*mirror, 0, len(c.Mirrors))
+	for _, mc := range c.Mirrors {
+		mc.Mirrors = nil
+		ms = append(ms, &mirror{e: New(mc), pending: make(chan mirrored, mirrorBacklog)})
+	}
+	return ms
+}
+
+// fanFlush is flushSelected with c.Mirrors: the datapoints of sel are
+// collected once and sent to Addr, the result of the flush being that of
+// Addr, and to every mirror, in the background once e is started so that a
+// slow mirror delays neither Addr nor the other mirrors. The datapoints a
+// destination failed to send are parked, see park, or dropped, rather than
+// sent again by the next flush, so that every destination receives them
+// once.
+func (e *Exporter) fanFlush(ctx context.Context, sel selection) (res FlushResult) {
+	c := &e.c
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	t := time.Now()
+	ctx, end := e.trace(ctx)
+	defer end(&res)
+	dps, _, _, err := e.prepare(sel, t)
+	if nil != err {
+		return FlushResult{Time: t, Err: err}
+	}
+	if c.OrderedDelivery {
+		dps = e.order(dps)
+	}
+	wait := e.fanOut(ctx, t, dps)
+	defer wait()
+	res = e.transmit(ctx, t, dps)
+	e.saveState()
+	if c.OrderedDelivery && nil == res.Err {
+		e.delivered(dps)
+	}
+	return res
+}
+
+// fanOut hands dps, the datapoints of the flush started at t, to the
+// mirrors of e. Unless the mirrors are run in the background by Start,
+// they are sent right away and the returned function waits for them.
+func (e *Exporter) fanOut(ctx context.Context, t time.Time, dps []datapoint) func() {
+	if 0 == atomic.LoadInt32(&e.fanning) {
+		var wg sync.WaitGroup
+		for _, m := range e.mirrors {
+			wg.Add(1)
+			go func(m *mirror) {
+				defer wg.Done()
+				e.mirrored(m.e.mirror(ctx, t, dps))
+			}(m)
+		}
+		return wg.Wait
+	}
+	for _, m := range e.mirrors {
+		m.enqueue(mirrored{t: t, dps: dps})
+	}
+	return func() {}
+}
+
+// enqueue queues p to be sent by the background goroutine of m, parking or
+// dropping the oldest flush queued if m fell behind.
+func (m *mirror) enqueue(p mirrored) {
+	for {
+		select {
+		case m.pending <- p:
+			return
+		default:
+		}
+		select {
+		case old := <-m.pending:
+			m.drop(old)
+		default:
+		}
+	}
+}
+
+// drop parks p, a flush m did not send, or drops it.
+func (m *mirror) drop(p mirrored) {
+	m.e.parkOrDrop(p.dps)
+}
+
+// startMirrors runs the mirrors of e in the background until e is stopped.
+func (e *Exporter) startMirrors() {
+	if 0 == len(e.mirrors) {
+		return
+	}
+	atomic.StoreInt32(&e.fanning, 1)
+	for _, m := range e.mirrors {
+		m := m
+		e.spawn(&e.running, func() {
+			for {
+				select {
+				case <-e.stop:
+					atomic.StoreInt32(&e.fanning, 0)
+					for 0 != len(m.pending) {
+						m.drop(<-m.pending)
+					}
+					return
+				case p := <-m.pending:
+					e.mirrored(m.e.mirror(e.ctx, p.t, p.dps))
+				}
+			}
+		})
+	}
+}
+
+// closeMirrors closes the connections kept open by the mirrors of e.
+func (e *Exporter) closeMirrors() {
+	for _, m := range e.mirrors {
+		m.e.closeConn()
+	}
+}
+
+// mirrored accounts for res, the outcome of a flush sent to a mirror.
+func (e *Exporter) mirrored(res FlushResult) {
+	logFailure(res)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.account(res)
+}
+
+// mirror sends dps, the datapoints of a flush of another exporter started
+// at t, as a flush of e.
+func (e *Exporter) mirror(ctx context.Context, t time.Time, dps []datapoint) FlushResult {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	return e.transmit(ctx, t, dps)
+}
+
+// transmit sends dps, the datapoints collected by a flush started at t,
+// and the datapoints kept by earlier flushes to be replayed. The datapoints
+// it failed to send are parked, see park, or dropped. It needs e.flushMu.
+func (e *Exporter) transmit(ctx context.Context, t time.Time, dps []datapoint) (res FlushResult) {
+	res.Time, res.Lines = t, len(dps)
+	defer func() { res.Duration = time.Since(res.Time) }()
+	if res.Err = e.allow(time.Now()); nil != res.Err {
+		e.parkOrDrop(dps)
+		return res
+	}
+	defer func() { e.trip(res) }()
+	conn, addr, err := e.acquire(ctx)
+	res.Addr = addr
+	if nil != err {
+		res.Err = err
+		e.parkOrDrop(dps)
+		return res
+	}
+	var broken error
+	defer func() {
+		if nil == broken {
+			broken = res.Err
+		}
+		e.release(conn, broken)
+	}()
+	stop := watch(ctx, conn)
+	defer func() { stop() }()
+	var sent int
+	conn, sent = e.deliver(ctx, conn, &stop, &res, dps)
+	if sent < len(dps) {
+		e.parkOrDrop(dps[sent:])
+	}
+	if nil == res.Err {
+		broken = e.replay(ctx, conn)
+	}
+	return res
+}
+
+// parkOrDrop parks dps, datapoints e failed to send, or logs that they are
+// dropped.
+func (e *Exporter) parkOrDrop(dps []datapoint) {
+	if 0 != len(dps) && !e.park(dps) {
+		log.Printf("graphite: dropped %d datapoints not sent to %s", len(dps), e.c.Addr)
+	}
+}
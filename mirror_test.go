@@ -0,0 +1,85 @@
+package graphite
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestMirrors(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	mres, ml, _, mc, mwg := NewTestServer(t, "foobar")
+	defer ml.Close()
+	down := GraphiteConfig{
+		Addr: "down:2003",
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("unreachable")
+		},
+	}
+	c.Mirrors = []GraphiteConfig{mc, down}
+	e := New(c)
+	metrics.GetOrRegisterCounter("foo", r).Inc(2)
+
+	wg.Add(1)
+	mwg.Add(1)
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	mwg.Wait()
+	if !floatEquals(2, res["foobar.foo"]) || !floatEquals(2, mres["foobar.foo"]) {
+		t.Fatal("flush not mirrored:", res, mres)
+	}
+	stats := e.DestinationStats()
+	if s := stats["down:2003"]; 1 != s.Failures || 1 != stats[mc.Addr].Flushes {
+		t.Fatal("bad destination stats:", stats)
+	}
+}
+
+func TestMirrorsBackground(t *testing.T) {
+	unblock := make(chan struct{})
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("foo", r).Inc(1)
+	e := New(GraphiteConfig{
+		Addr:          "carbon:2003",
+		Registry:      r,
+		FlushInterval: 10 * time.Millisecond,
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go io.Copy(io.Discard, server)
+			return client, nil
+		},
+		Mirrors: []GraphiteConfig{{
+			Addr: "slow:2003",
+			DialFunc: func(context.Context, string, string) (net.Conn, error) {
+				<-unblock
+				return nil, errors.New("unreachable")
+			},
+		}},
+	})
+	e.Start()
+	// Flushes complete while the mirror is stuck dialing.
+	for i := 0; i < 2; i++ {
+		if res := <-e.Results(); nil != res.Err {
+			t.Fatal(res.Err)
+		}
+	}
+	close(unblock)
+	e.Stop()
+}
+
+func TestMirrorBacklog(t *testing.T) {
+	m := mirrors(&GraphiteConfig{Mirrors: []GraphiteConfig{{ReplayPayloads: 10}}})[0]
+	for i := 0; i <= mirrorBacklog; i++ {
+		m.enqueue(mirrored{t: time.Now(), dps: []datapoint{{path: "foo", value: float64(i)}}})
+	}
+	if mirrorBacklog != len(m.pending) || 1 != len(m.e.replays) || !floatEquals(0, m.e.replays[0][0].value) {
+		t.Fatal("oldest flush not parked:", len(m.pending), m.e.replays)
+	}
+}
@@ -0,0 +1,59 @@
+package graphite
+
+import (
+	"sort"
+	"time"
+)
+
+// order sorts dps by series and timestamp so that the datapoints of every
+// series are sent in timestamp order, which matters when live flushes are
+// mixed with queued or replayed datapoints. Datapoints older than one
+// already delivered for the same series can no longer be sequenced and
+// are dropped; see Exporter.LateDatapoints.
+func (e *Exporter) order(dps []datapoint) []datapoint {
+	sort.SliceStable(dps, func(i, j int) bool {
+		if dps[i].path != dps[j].path {
+			return dps[i].path < dps[j].path
+		}
+		return dps[i].timestamp < dps[j].timestamp
+	})
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	kept := dps[:0]
+	for _, dp := range dps {
+		if latest, ok := e.latest[dp.path]; ok && dp.timestamp < latest.timestamp {
+			e.late++
+			continue
+		}
+		kept = append(kept, dp)
+	}
+	return kept
+}
+
+// delivered records the timestamps of dps, which were delivered.
+func (e *Exporter) delivered(dps []datapoint) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if nil == e.latest {
+		e.latest = make(map[string]delivery)
+	}
+	now := time.Now()
+	for _, dp := range dps {
+		e.latest[dp.path] = delivery{timestamp: dp.timestamp, at: now}
+	}
+}
+
+// delivery records the latest datapoint delivered for a series.
+type delivery struct {
+	timestamp int64     // timestamp of the datapoint
+	at        time.Time // time it was delivered
+}
+
+// LateDatapoints returns the number of datapoints dropped by
+// OrderedDelivery because a more recent datapoint of the same series had
+// already been delivered.
+func (e *Exporter) LateDatapoints() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.late
+}
@@ -0,0 +1,27 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestOrderedDelivery(t *testing.T) {
+	e := New(GraphiteConfig{OrderedDelivery: true})
+	dps := e.order([]datapoint{
+		{path: "b", timestamp: 2},
+		{path: "a", timestamp: 3},
+		{path: "b", timestamp: 1},
+		{path: "a", timestamp: 1},
+	})
+	expected := []datapoint{{path: "a", timestamp: 1}, {path: "a", timestamp: 3}, {path: "b", timestamp: 1}, {path: "b", timestamp: 2}}
+	for i := range expected {
+		if dps[i] != expected[i] {
+			t.Fatal("bad order:", dps)
+		}
+	}
+	e.delivered(dps)
+
+	dps = e.order([]datapoint{{path: "a", timestamp: 2}, {path: "a", timestamp: 3}, {path: "b", timestamp: 5}})
+	if len(dps) != 2 || dps[0].timestamp != 3 || e.LateDatapoints() != 1 {
+		t.Fatal("late datapoint not dropped:", dps)
+	}
+}
@@ -0,0 +1,58 @@
+package graphite
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/cyberdelia/go-metrics-graphite/carbonparse"
+)
+
+// Line is a single datapoint of the plaintext protocol.
+type Line struct {
+	Path      string
+	Value     float64
+	Timestamp int64 // Unix time in seconds
+}
+
+// ParseError describes a line Parse or ParseLine could not parse.
+type ParseError = carbonparse.ParseError
+
+// ParseLine parses s, a line of the plaintext protocol optionally
+// terminated by a newline. It only checks the line is well-formed; see
+// ValidateLine for the checks carbon applies. The tags of tagged series are
+// kept in Path, sorted by name. Use the carbonparse package to get them
+// apart.
+func ParseLine(s string) (Line, error) {
+	d, err := carbonparse.ParseLine(s)
+	if nil != err {
+		return Line{}, err
+	}
+	return Line{Path: d.Series(), Value: d.Value, Timestamp: d.Timestamp}, nil
+}
+
+// Parse parses the lines of the plaintext protocol read from r, skipping
+// empty lines. It stops at the first malformed line, returning the lines
+// parsed so far along with a *ParseError.
+func Parse(r io.Reader) ([]Line, error) {
+	dps, err := carbonparse.Parse(r)
+	lines := make([]Line, len(dps))
+	for i, d := range dps {
+		lines[i] = Line{Path: d.Series(), Value: d.Value, Timestamp: d.Timestamp}
+	}
+	return lines, err
+}
+
+// Lines returns the lines Encode writes for snaps, with values rounded as
+// they are encoded, so that Parse reading the output of Encode returns
+// exactly Lines. This makes round-trip and property tests of payloads
+// straightforward.
+func Lines(c *GraphiteConfig, snaps []MetricSnapshot, ts time.Time) []Line {
+	dps := datapoints(c, snaps, ts)
+	lines := make([]Line, len(dps))
+	for i, dp := range dps {
+		v, _ := strconv.ParseFloat(strconv.FormatFloat(dp.value, 'f', dp.precision, 64), 64)
+		lines[i] = Line{Path: dp.path, Value: v, Timestamp: dp.timestamp}
+	}
+	return lines
+}
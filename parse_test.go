@@ -0,0 +1,87 @@
+package graphite
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	l, err := ParseLine("foo.bar 1.5 1234567890\n")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if (Line{Path: "foo.bar", Value: 1.5, Timestamp: 1234567890}) != l {
+		t.Fatal("bad line:", l)
+	}
+	l, err = ParseLine("cpu;host=a;dc=eu 1 2")
+	if nil != err || "cpu;dc=eu;host=a" != l.Path {
+		t.Fatal("bad tagged line:", l, err)
+	}
+	for _, s := range []string{"", "foo 1", " 1 2", "foo x 1", "foo 1 x", "foo 1 2 3", "foo;bar 1 2"} {
+		if _, err := ParseLine(s); nil == err {
+			t.Errorf("%q parsed", s)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	lines, err := Parse(strings.NewReader("a 1 1\n\nb 2 2\nc\nd 4 4\n"))
+	var perr *ParseError
+	if !errors.As(err, &perr) || 4 != perr.Line || "c" != perr.Text {
+		t.Fatal("bad error:", err)
+	}
+	if 2 != len(lines) || "b" != lines[1].Path {
+		t.Fatal("bad lines:", lines)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	c := &GraphiteConfig{DurationUnit: time.Millisecond, Prefix: "rt", Percentiles: []float64{0.5, 0.99}}
+	snaps := []MetricSnapshot{
+		NewCounterSnapshot("requests", -42),
+		NewGaugeFloat64Snapshot("load", 1.0/3),
+		NewHistogramSnapshot(c, "sizes", []int64{1, 2, 3, 7}),
+		NewMeterSnapshot("hits", 10, Rates{1.005, 2, 3, 4}),
+		NewTimerSnapshot(c, "latency", []time.Duration{time.Second, 1234567 * time.Nanosecond}, Rates{Mean: 0.5}),
+	}
+	ts := time.Unix(1234567890, 0)
+	var buf bytes.Buffer
+	if err := Encode(&buf, c, snaps, ts); nil != err {
+		t.Fatal(err)
+	}
+	lines, err := Parse(&buf)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if expected := Lines(c, snaps, ts); !reflect.DeepEqual(lines, expected) {
+		t.Fatal("round trip mismatch:", lines, expected)
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add("foo.bar", 1.5, int64(1234567890))
+	f.Add("x", -1e300, int64(1))
+	f.Add("a;tag=b", 0.001, int64(0))
+	f.Fuzz(func(t *testing.T, name string, v float64, ts int64) {
+		if l, err := ParseLine(name + " 0 0"); nil != err || name != l.Path || math.IsNaN(v) {
+			t.Skip()
+		}
+		snaps := []MetricSnapshot{NewGaugeFloat64Snapshot(name, v)}
+		var buf bytes.Buffer
+		if err := Encode(&buf, &GraphiteConfig{Prefix: "fuzz"}, snaps, time.Unix(ts, 0)); nil != err {
+			t.Fatal(err)
+		}
+		lines, err := Parse(&buf)
+		if nil != err {
+			t.Fatal(err)
+		}
+		if expected := Lines(&GraphiteConfig{Prefix: "fuzz"}, snaps, time.Unix(ts, 0)); !reflect.DeepEqual(lines, expected) {
+			t.Fatal("round trip mismatch:", lines, expected)
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package graphite
+
+import (
+	"path"
+	"strings"
+)
+
+// match reports whether name matches the Graphite style glob pattern, in
+// which "*" matches any sequence of characters within a single dot
+// separated node. The syntax otherwise follows path.Match. Malformed
+// patterns never match.
+func match(pattern, name string) bool {
+	ok, err := path.Match(strings.Replace(pattern, ".", "/", -1), strings.Replace(name, ".", "/", -1))
+	return nil == err && ok
+}
+
+// fieldName returns the name of field f of metric s, without prefix, which
+// is what per-series patterns are matched against.
+func fieldName(s MetricSnapshot, f Field) string {
+	if "" == f.Name {
+		return s.Name
+	}
+	return s.Name + "." + f.Name
+}
@@ -0,0 +1,73 @@
+package graphite
+
+import (
+	"math"
+	"slices"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// PercentileMethod is the method estimating the percentiles of histograms
+// and timers from their samples.
+type PercentileMethod int
+
+const (
+	// PercentileDefault interpolates between the values around rank
+	// p(n+1), as go-metrics and Dropwizard do.
+	PercentileDefault PercentileMethod = iota
+
+	// PercentileNearestRank takes the value of rank ceil(pn), always one of
+	// the values sampled.
+	PercentileNearestRank
+
+	// PercentileLinear interpolates between the values around rank
+	// p(n-1)+1, as NumPy and R do by default.
+	PercentileLinear
+)
+
+// sampled is implemented by distributions of custom types exposing the
+// values they sampled.
+type sampled interface {
+	Values() []int64
+}
+
+// quantiles returns the percentiles c.Percentiles of d estimated with
+// c.PercentileMethod. Distributions which do not expose their sample, such
+// as the timers of go-metrics unlike those of NewSlidingWindowTimer, are
+// estimated with PercentileDefault.
+func quantiles(c *GraphiteConfig, d distribution) []float64 {
+	if PercentileDefault != c.PercentileMethod {
+		switch s := d.(type) {
+		case metrics.Histogram:
+			return estimate(c.PercentileMethod, s.Sample().Values(), c.Percentiles)
+		case sampled:
+			return estimate(c.PercentileMethod, s.Values(), c.Percentiles)
+		}
+	}
+	return d.Percentiles(c.Percentiles)
+}
+
+// estimate returns the percentiles ps of values estimated with method m,
+// zero if values is empty.
+func estimate(m PercentileMethod, values []int64, ps []float64) []float64 {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	n := len(values)
+	scores := make([]float64, len(ps))
+	if 0 == n {
+		return scores
+	}
+	for i, p := range ps {
+		switch m {
+		case PercentileNearestRank:
+			rank := int(math.Ceil(p * float64(n)))
+			scores[i] = float64(values[min(max(rank, 1), n)-1])
+		default:
+			pos := p * float64(n-1)
+			lo := min(max(int(math.Floor(pos)), 0), n-1)
+			hi := min(lo+1, n-1)
+			scores[i] = float64(values[lo]) + (pos-float64(lo))*float64(values[hi]-values[lo])
+		}
+	}
+	return scores
+}
@@ -0,0 +1,51 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimate(t *testing.T) {
+	values := []int64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	ps := []float64{0, 0.5, 0.9, 0.95, 1}
+	for _, tc := range []struct {
+		method   PercentileMethod
+		expected []float64
+	}{
+		{PercentileNearestRank, []float64{1, 5, 9, 10, 10}},
+		{PercentileLinear, []float64{1, 5.5, 9.1, 9.55, 10}},
+	} {
+		found := estimate(tc.method, values, ps)
+		for i := range ps {
+			if !floatEquals(found[i], tc.expected[i]) {
+				t.Error("bad percentile:", tc.method, ps[i], tc.expected[i], found[i])
+			}
+		}
+	}
+	if 10 != values[0] {
+		t.Fatal("values sorted in place")
+	}
+	if found := estimate(PercentileLinear, nil, ps); 0 != found[1] {
+		t.Fatal("bad percentile of an empty sample:", found)
+	}
+}
+
+func TestPercentileMethod(t *testing.T) {
+	h := sampleOf([]int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	c := &GraphiteConfig{Percentiles: []float64{0.9}}
+	if found := quantiles(c, h)[0]; !floatEquals(found, 9.9) {
+		t.Fatal("bad default percentile:", found)
+	}
+	c.PercentileMethod = PercentileNearestRank
+	if found := quantiles(c, h)[0]; !floatEquals(found, 9) {
+		t.Fatal("bad nearest rank percentile:", found)
+	}
+
+	timer := NewSlidingWindowTimer(time.Minute)
+	for i := 1; i <= 10; i++ {
+		timer.Update(time.Duration(i))
+	}
+	if found := quantiles(c, timer.Snapshot())[0]; !floatEquals(found, 9) {
+		t.Fatal("bad nearest rank timer percentile:", found)
+	}
+}
@@ -0,0 +1,95 @@
+package graphite
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistedState is the content of GraphiteConfig.StateFile.
+type persistedState struct {
+	Counters map[string]float64           `json:"counters,omitempty"`
+	Outliers map[string]persistedOutliers `json:"outliers,omitempty"`
+	LastOK   int64                        `json:"last_ok,omitempty"` // Unix time of the last successful flush
+}
+
+type persistedOutliers struct {
+	N    int     `json:"n"`
+	Mean float64 `json:"mean"`
+	M2   float64 `json:"m2"`
+}
+
+// loadState restores the baselines saved in c.StateFile, once. A missing
+// file is not an error since it is expected on the first run.
+func (e *Exporter) loadState() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.loaded || "" == e.c.StateFile {
+		return
+	}
+	e.loaded = true
+	b, err := os.ReadFile(e.c.StateFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	var state persistedState
+	if nil == err {
+		err = json.Unmarshal(b, &state)
+	}
+	if nil != err {
+		log.Println("graphite: could not load state:", err)
+		return
+	}
+	if nil != state.Counters {
+		e.baselines = state.Counters
+	}
+	for name, o := range state.Outliers {
+		e.outliers[name] = &runningStats{n: o.N, mean: o.Mean, m2: o.M2}
+	}
+	if 0 != state.LastOK && e.succeeded.IsZero() {
+		e.succeeded = time.Unix(state.LastOK, 0)
+	}
+}
+
+// saveState writes the current baselines to c.StateFile. The file is
+// replaced atomically so that a crash never leaves a truncated state.
+func (e *Exporter) saveState() {
+	if "" == e.c.StateFile {
+		return
+	}
+	e.mu.Lock()
+	state := persistedState{Counters: e.baselines, Outliers: make(map[string]persistedOutliers)}
+	if !e.succeeded.IsZero() {
+		state.LastOK = e.succeeded.Unix()
+	}
+	for name, o := range e.outliers {
+		state.Outliers[name] = persistedOutliers{N: o.n, Mean: o.mean, M2: o.m2}
+	}
+	b, err := json.Marshal(state)
+	e.mu.Unlock()
+	if nil == err {
+		err = writeFileAtomic(e.c.StateFile, b)
+	}
+	if nil != err {
+		log.Println("graphite: could not save state:", err)
+	}
+}
+
+func writeFileAtomic(name string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if nil != err {
+		return err
+	}
+	if _, err := f.Write(b); nil != err {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); nil != err {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,35 @@
+package graphite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersistState(t *testing.T) {
+	dir, err := os.MkdirTemp("", "graphite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := GraphiteConfig{
+		CounterDeltas: true,
+		StateFile:     filepath.Join(dir, "state.json"),
+		Outliers:      []OutlierRule{{Pattern: "*", StdDevs: 3}},
+	}
+
+	e := New(c)
+	e.loadState()
+	e.process([]MetricSnapshot{NewCounterSnapshot("foo", 10)})
+	e.saveState()
+
+	e = New(c)
+	e.loadState()
+	snaps := e.process([]MetricSnapshot{NewCounterSnapshot("foo", 15)})
+	if found := snaps[0].Fields[0].Value; !floatEquals(found, 5) {
+		t.Fatal("baseline not restored:", found)
+	}
+	if stats := e.outliers["foo"]; stats == nil || stats.n != 2 {
+		t.Fatal("outlier stats not restored:", stats)
+	}
+}
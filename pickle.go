@@ -0,0 +1,96 @@
+package graphite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"strconv"
+)
+
+// Protocol is the protocol datapoints are sent to carbon with.
+type Protocol int
+
+// Protocols carbon accepts.
+const (
+	ProtocolPlaintext Protocol = iota // Lines of "<path> <value> <timestamp>", usually on port 2003
+	ProtocolPickle                    // Pickled batches of (path, (timestamp, value)) tuples, usually on port 2004
+)
+
+// pickleBatch is the number of datapoints of a pickle message, carbon
+// refusing messages larger than 1MB.
+const pickleBatch = 500
+
+// writePickles writes dps to conn as pickle messages of up to pickleBatch
+// datapoints, and returns the bytes written along with the number of
+// datapoints of the messages written in full.
+func writePickles(conn net.Conn, dps []datapoint) (int, int, error) {
+	var written, sent int
+	var buf bytes.Buffer
+	for 0 != len(dps) {
+		n := len(dps)
+		if n > pickleBatch {
+			n = pickleBatch
+		}
+		buf.Reset()
+		pickle(&buf, dps[:n])
+		m, err := conn.Write(buf.Bytes())
+		written += m
+		if nil != err {
+			return written, sent, err
+		}
+		sent += n
+		dps = dps[n:]
+	}
+	return written, sent, nil
+}
+
+// pickle writes dps to buf as a message of the carbon pickle protocol: the
+// big-endian length of a pickle, protocol 2, of the list of their
+// (path, (timestamp, value)) tuples, followed by the pickle.
+func pickle(buf *bytes.Buffer, dps []datapoint) {
+	start := buf.Len()
+	buf.Write([]byte{0, 0, 0, 0})
+	buf.WriteString("\x80\x02]")
+	if 0 != len(dps) {
+		buf.WriteByte('(')
+	}
+	var b [8]byte
+	for _, dp := range dps {
+		buf.WriteByte('X')
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(dp.path)))
+		buf.Write(b[:4])
+		buf.WriteString(dp.path)
+		if dp.timestamp >= math.MinInt32 && dp.timestamp <= math.MaxInt32 {
+			buf.WriteByte('J')
+			binary.LittleEndian.PutUint32(b[:4], uint32(int32(dp.timestamp)))
+			buf.Write(b[:4])
+		} else {
+			buf.WriteString("\x8a\x08")
+			binary.LittleEndian.PutUint64(b[:], uint64(dp.timestamp))
+			buf.Write(b[:])
+		}
+		buf.WriteByte('G')
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(rounded(dp)))
+		buf.Write(b[:])
+		buf.WriteString("\x86\x86")
+	}
+	if 0 != len(dps) {
+		buf.WriteByte('e')
+	}
+	buf.WriteByte('.')
+	binary.BigEndian.PutUint32(buf.Bytes()[start:], uint32(buf.Len()-start-4))
+}
+
+// rounded returns the value of dp rounded to its precision, as sent by the
+// plaintext protocol.
+func rounded(dp datapoint) float64 {
+	if dp.precision < 0 {
+		return dp.value
+	}
+	v, err := strconv.ParseFloat(strconv.FormatFloat(dp.value, 'f', dp.precision, 64), 64)
+	if nil != err {
+		return dp.value
+	}
+	return v
+}
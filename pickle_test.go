@@ -0,0 +1,92 @@
+package graphite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestPickle(t *testing.T) {
+	// Unpickled by Python as [('a', (1, 1.0))].
+	var buf bytes.Buffer
+	pickle(&buf, []datapoint{{path: "a", value: 1.004, precision: 2, timestamp: 1}})
+	expected := "\x00\x00\x00\x1c\x80\x02](X\x01\x00\x00\x00aJ\x01\x00\x00\x00G?\xf0\x00\x00\x00\x00\x00\x00\x86\x86e."
+	if found := buf.String(); expected != found {
+		t.Fatalf("bad pickle: %q", found)
+	}
+
+	// Timestamps beyond 2038 are pickled as longs.
+	buf.Reset()
+	pickle(&buf, []datapoint{{path: "b", value: 2.5, precision: -1, timestamp: 1 << 40}})
+	if !bytes.Contains(buf.Bytes(), []byte("\x8a\x08\x00\x00\x00\x00\x00\x01\x00\x00G")) {
+		t.Fatalf("bad long timestamp: %q", buf.String())
+	}
+
+	buf.Reset()
+	pickle(&buf, nil)
+	if expected, found := "\x00\x00\x00\x04\x80\x02].", buf.String(); expected != found {
+		t.Fatalf("bad empty pickle: %q", found)
+	}
+}
+
+func TestPickleFlush(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	messages := make(chan []byte, 16)
+	go func() {
+		conn, err := l.Accept()
+		if nil != err {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			var size [4]byte
+			if _, err := io.ReadFull(r, size[:]); nil != err {
+				close(messages)
+				return
+			}
+			m := make([]byte, binary.BigEndian.Uint32(size[:]))
+			if _, err := io.ReadFull(r, m); nil != err {
+				close(messages)
+				return
+			}
+			messages <- m
+		}
+	}()
+
+	r := metrics.NewRegistry()
+	for i := 0; i < pickleBatch; i++ {
+		metrics.GetOrRegisterGauge(fmt.Sprintf("g%03d", i), r).Update(int64(i))
+	}
+	c := GraphiteConfig{Addr: l.Addr().String(), Registry: r, FlushInterval: time.Second, Prefix: "p", Protocol: ProtocolPickle}
+	e := New(c)
+	e.Send("marker", 1, time.Now())
+	res := e.flush()
+	if nil != res.Err {
+		t.Fatal(res.Err)
+	}
+	var n int
+	for m := range messages {
+		n++
+		if m[0] != 0x80 || m[len(m)-1] != '.' {
+			t.Fatalf("bad message: %q", m)
+		}
+	}
+	if 2 != n {
+		t.Fatal("datapoints not batched:", n)
+	}
+	if 0 != len(e.queue) || pickleBatch+1 != res.Lines {
+		t.Fatal("bad flush:", res.Lines, e.queue)
+	}
+}
@@ -0,0 +1,76 @@
+package graphite
+
+import (
+	"bytes"
+	"net"
+	"sync"
+)
+
+// pipelineDepth is the number of encoded chunks which may wait to be
+// written while the next ones are encoded.
+const pipelineDepth = 4
+
+// chunk is a run of plaintext lines written at once.
+type chunk struct {
+	b     []byte
+	lines int
+}
+
+// writePipelined writes dps as plaintext lines terminated by eol to conn,
+// in writes of at most max bytes like writeLines, while they are encoded by
+// another goroutine so that encoding the datapoints of large registries
+// overlaps with writing them. It returns the bytes written and the number
+// of datapoints written in full, the first ones of dps.
+func writePipelined(conn net.Conn, dps []datapoint, max int, eol string) (int, int, error) {
+	chunks := make(chan chunk, pipelineDepth)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		encodeChunks(dps, max, eol, chunks, done)
+	}()
+	defer wg.Wait()
+	defer close(done)
+	var written, sent int
+	for ch := range chunks {
+		n, err := conn.Write(ch.b)
+		written += n
+		if nil != err {
+			return written, sent + bytes.Count(ch.b[:n], []byte{'\n'}), err
+		}
+		sent += ch.lines
+	}
+	return written, sent, nil
+}
+
+// encodeChunks sends dps to chunks encoded as lines terminated by eol, in
+// chunks of at most max bytes split on line boundaries, lines longer than
+// max being alone in their chunk, until done is closed. It closes chunks.
+func encodeChunks(dps []datapoint, max int, eol string, chunks chan<- chunk, done <-chan struct{}) {
+	defer close(chunks)
+	send := func(ch chunk) bool {
+		select {
+		case chunks <- ch:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	var ch chunk
+	var line []byte
+	for _, dp := range dps {
+		line = appendLine(line[:0], dp, eol)
+		if 0 != ch.lines && len(ch.b)+len(line) > max {
+			if !send(ch) {
+				return
+			}
+			ch = chunk{}
+		}
+		ch.b = append(ch.b, line...)
+		ch.lines++
+	}
+	if 0 != ch.lines {
+		send(ch)
+	}
+}
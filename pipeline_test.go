@@ -0,0 +1,68 @@
+package graphite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// recordConn records the writes made to it, failing once limit bytes were
+// written if limit is positive.
+type recordConn struct {
+	net.Conn
+	writes [][]byte
+	limit  int
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	written := 0
+	for _, w := range c.writes {
+		written += len(w)
+	}
+	if c.limit > 0 && written+len(b) > c.limit {
+		n := c.limit - written
+		c.writes = append(c.writes, append([]byte(nil), b[:n]...))
+		return n, errors.New("limit reached")
+	}
+	c.writes = append(c.writes, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func TestWritePipelined(t *testing.T) {
+	var dps []datapoint
+	for i := 0; i < 1000; i++ {
+		dps = append(dps, datapoint{path: fmt.Sprintf("foo.bar%d", i), value: float64(i), precision: -1, timestamp: 1})
+	}
+	dps = append(dps, datapoint{path: strings.Repeat("long", 100), value: 1, precision: -1, timestamp: 1})
+	var expected bytes.Buffer
+	encode(&expected, dps)
+
+	conn := &recordConn{}
+	n, sent, err := writePipelined(conn, dps, 256, "\n")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if expected.Len() != n || len(dps) != sent {
+		t.Fatal("bad counts:", n, sent)
+	}
+	if found := bytes.Join(conn.writes, nil); !bytes.Equal(expected.Bytes(), found) {
+		t.Fatal("bad payload:", string(found))
+	}
+	for i, w := range conn.writes {
+		if '\n' != w[len(w)-1] || (len(w) > 256 && 1 != bytes.Count(w, []byte{'\n'})) {
+			t.Fatal("bad write:", i, string(w))
+		}
+	}
+
+	conn = &recordConn{limit: 1000}
+	n, sent, err = writePipelined(conn, dps, 256, "\n")
+	if nil == err || 1000 != n {
+		t.Fatal("write did not fail:", n, err)
+	}
+	if expected := bytes.Count(expected.Bytes()[:1000], []byte{'\n'}); expected != sent {
+		t.Fatal("bad lines sent:", expected, sent)
+	}
+}
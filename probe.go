@@ -0,0 +1,44 @@
+package graphite
+
+import (
+	"bytes"
+	"net"
+	"time"
+)
+
+// probe writes a harmless line on the connection kept open between
+// flushes, if any, so that connections silently dropped by a NAT or a
+// firewall after some idle time are detected, and closed, before they fail
+// a flush. Only connections kept open between flushes with c.KeepAlive are
+// probed. Probes give up after five seconds so as not to hold up flushes.
+func (e *Exporter) probe() {
+	e.connMu.Lock()
+	defer e.connMu.Unlock()
+	if nil == e.conn {
+		return
+	}
+	var dps []datapoint
+	if "" != e.c.ProbeMetric {
+		dps = append(dps, datapoint{path: prefix(&e.c) + "." + e.c.ProbeMetric, value: 1, precision: -1, timestamp: time.Now().Unix()})
+	}
+	dps = withAPIKeys(&e.c, dps)
+	buf := bytes.NewBufferString("")
+	if ProtocolPickle == e.c.Protocol {
+		pickle(buf, dps)
+	} else if 0 == len(dps) {
+		buf.WriteString(lineEnding(&e.c))
+	} else {
+		encodeLines(buf, dps, lineEnding(&e.c))
+	}
+	var conn net.Conn = e.conn
+	if e.c.Framed && ProtocolPickle != e.c.Protocol {
+		conn = framedConn{Conn: e.conn, e: e}
+	}
+	e.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(buf.Bytes()); nil != err {
+		e.disconnect(e.conn, err)
+		e.conn = nil
+		return
+	}
+	e.conn.SetWriteDeadline(time.Time{})
+}
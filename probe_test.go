@@ -0,0 +1,123 @@
+package graphite
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestProbe(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	var disconnected error
+	e := New(GraphiteConfig{Prefix: "foobar", ProbeMetric: "keepalive"})
+	e.c.OnDisconnect = func(addr string, err error) { disconnected = err }
+	e.conn = client
+
+	lines := make(chan string)
+	go func() {
+		line, _ := bufio.NewReader(server).ReadString('\n')
+		lines <- line
+	}()
+	e.probe()
+	if line := <-lines; !strings.HasPrefix(line, "foobar.keepalive 1 ") {
+		t.Fatal("bad probe:", line)
+	}
+
+	server.Close()
+	e.probe()
+	if e.conn != nil || disconnected == nil {
+		t.Fatal("broken connection not dropped")
+	}
+
+	// Probing without a connection is a no-op.
+	e.probe()
+}
+
+func TestProbeKeepAlive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conns := make(chan net.Conn, 1)
+	lines := make(chan string, 100)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conns <- conn
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	disconnected := make(chan error, 1)
+	e := New(GraphiteConfig{
+		Addr:          ln.Addr().String(),
+		Registry:      metrics.NewRegistry(),
+		FlushInterval: time.Hour,
+		Prefix:        "foobar",
+		KeepAlive:     true,
+		ProbeInterval: 5 * time.Millisecond,
+		ProbeMetric:   "keepalive",
+		OnDisconnect: func(addr string, err error) {
+			select {
+			case disconnected <- err:
+			default:
+			}
+		},
+	})
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	e.Start()
+	defer e.Stop()
+	select {
+	case line := <-lines:
+		if !strings.HasPrefix(line, "foobar.keepalive 1 ") {
+			t.Fatal("bad probe:", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("connection not probed")
+	}
+
+	(<-conns).Close()
+	select {
+	case err := <-disconnected:
+		if err == nil {
+			t.Fatal("broken connection dropped without error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("broken connection not dropped")
+	}
+}
+
+func TestProbePickle(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	e := New(GraphiteConfig{Prefix: "foobar", Protocol: ProtocolPickle})
+	e.conn = client
+
+	probes := make(chan []byte)
+	go func() {
+		b := make([]byte, 64)
+		n, _ := server.Read(b)
+		probes <- b[:n]
+	}()
+	e.probe()
+	if expected, found := "\x00\x00\x00\x04\x80\x02].", string(<-probes); expected != found {
+		t.Fatalf("bad probe: %q", found)
+	}
+}
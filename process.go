@@ -0,0 +1,108 @@
+package graphite
+
+import (
+	"math"
+	"time"
+)
+
+// process applies the stateful value policies of e to snaps: negative
+// counters handling, counter deltas and reset detection, increases of the
+// counts of counters and meters with ZeroIdle, clamping, outlier dropping
+// and unit conversions, in that order.
+func (e *Exporter) process(snaps []MetricSnapshot) []MetricSnapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if nil == e.baselines {
+		e.baselines = make(map[string]float64)
+	}
+	for i := range snaps {
+		fields := snaps[i].Fields[:0]
+		for _, f := range snaps[i].Fields {
+			name := fieldName(snaps[i], f)
+			if TypeCounter == snaps[i].Type && f.Value < 0 {
+				switch e.c.NegativeCounters {
+				case NegativeClamp:
+					f.Value = 0
+				case NegativeDrop:
+					e.negatives++
+					continue
+				}
+			}
+			var reset bool
+			if TypeCounter == snaps[i].Type && "" == f.Name {
+				f.Value, reset = e.counter(name, f.Value)
+			}
+			if e.c.ZeroIdle {
+				f.Value = e.zeroFill(snaps[i], f)
+			}
+			for _, rule := range e.c.Clamp {
+				if match(rule.Pattern, name) {
+					f.Value = math.Max(rule.Min, math.Min(rule.Max, f.Value))
+				}
+			}
+			if e.outlier(name, f.Value) {
+				continue
+			}
+			fields = append(fields, convert(e.c.Units, name, f))
+			if reset && e.c.AnnotateCounterResets {
+				fields = append(fields, intField("reset", 1))
+			}
+		}
+		snaps[i].Fields = fields
+	}
+	return snaps
+}
+
+// prune forgets the per-series state of the series which are not part of
+// snaps, the snapshot of a complete flush, so that state does not grow
+// with metrics which were unregistered: counter baselines, outlier
+// statistics, idle counts and threshold states. Series delivered with
+// OrderedDelivery are forgotten once nothing was delivered for them during
+// ten flush intervals, since they also include datapoints which are not
+// part of the registry.
+func (e *Exporter) prune(snaps []MetricSnapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	seen := make(map[string]bool, len(e.external))
+	for name := range e.external {
+		seen[name] = true
+	}
+	for _, s := range snaps {
+		seen[s.Name] = true
+		for _, f := range s.Fields {
+			seen[fieldName(s, f)] = true
+		}
+	}
+	for name := range e.baselines {
+		if !seen[name] {
+			delete(e.baselines, name)
+		}
+	}
+	for name := range e.outliers {
+		if !seen[name] {
+			delete(e.outliers, name)
+		}
+	}
+	for name := range e.counts {
+		if !seen[name] {
+			delete(e.counts, name)
+		}
+	}
+	for key := range e.crossed {
+		if !seen[key.name] {
+			delete(e.crossed, key)
+		}
+	}
+	retention := 10 * e.c.FlushInterval
+	if retention < time.Minute {
+		retention = time.Minute
+	}
+	oldest := time.Now().Add(-retention)
+	for path, d := range e.latest {
+		if d.at.Before(oldest) {
+			delete(e.latest, path)
+		}
+	}
+	e.measure()
+	e.enforce()
+}
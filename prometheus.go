@@ -0,0 +1,176 @@
+package graphite
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// PrometheusHandler returns an http.Handler which renders the metrics in
+// c.Registry using the Prometheus text exposition format. It is meant to be
+// mounted on a /metrics endpoint next to the Graphite exporter so the same
+// registry can be scraped as well as pushed.
+//
+// Counters and gauges are exposed as gauges since go-metrics counters may be
+// decremented. Meters expose their count as a counter and their rates as
+// gauges. Histograms and timers are exposed as summaries using
+// c.Percentiles as quantiles; timer values are converted to seconds as is
+// customary for Prometheus.
+//
+// Metrics whose names collide once sanitized, such as "a.b" and "a_b", are
+// exposed once, as the first of them in name order, the others being
+// logged the first time they are left out.
+func PrometheusHandler(c GraphiteConfig) http.Handler {
+	var mu sync.Mutex
+	logged := make(map[string]bool)
+	collided := func(name, other string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !logged[name] {
+			logged[name] = true
+			log.Printf("graphite: metric %q left out of the Prometheus exposition: its name collides with %q", name, other)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(prometheus(&c, collided))
+	})
+}
+
+// prometheus renders the metrics of c.Registry, calling collided with the
+// names of those left out because they collide with another metric once
+// sanitized.
+func prometheus(c *GraphiteConfig, collided func(name, other string)) []byte {
+	buf := bytes.NewBufferString("")
+	metricsByName := make(map[string]interface{})
+	names := make([]string, 0)
+	c.Registry.Each(func(name string, i interface{}) {
+		metricsByName[name] = i
+		names = append(names, name)
+	})
+	sort.Strings(names)
+
+	owners := make(map[string]string)
+	for _, name := range names {
+		n := prometheusName(c.Prefix, name)
+		families := prometheusFamilies(n, metricsByName[name])
+		if other, ok := firstOwner(owners, families); ok {
+			collided(name, other)
+			continue
+		}
+		for _, family := range families {
+			owners[family] = name
+		}
+		switch metric := metricsByName[name].(type) {
+		case metrics.Counter:
+			fmt.Fprintf(buf, "# TYPE %s gauge\n", n)
+			fmt.Fprintf(buf, "%s %d\n", n, metric.Count())
+		case metrics.Gauge:
+			fmt.Fprintf(buf, "# TYPE %s gauge\n", n)
+			fmt.Fprintf(buf, "%s %d\n", n, metric.Value())
+		case metrics.GaugeFloat64:
+			fmt.Fprintf(buf, "# TYPE %s gauge\n", n)
+			fmt.Fprintf(buf, "%s %s\n", n, prometheusFloat(metric.Value()))
+		case metrics.Histogram:
+			h := metric.Snapshot()
+			prometheusSummary(buf, n, c.Percentiles, quantiles(c, h), float64(h.Sum()), h.Count())
+		case metrics.Meter:
+			m := metric.Snapshot()
+			fmt.Fprintf(buf, "# TYPE %s_total counter\n", n)
+			fmt.Fprintf(buf, "%s_total %d\n", n, m.Count())
+			prometheusRates(buf, n, m.Rate1(), m.Rate5(), m.Rate15(), m.RateMean())
+		case metrics.Timer:
+			t := metric.Snapshot()
+			ps := quantiles(c, t)
+			for psIdx := range ps {
+				ps[psIdx] /= float64(time.Second)
+			}
+			prometheusSummary(buf, n, c.Percentiles, ps, float64(t.Sum())/float64(time.Second), t.Count())
+			prometheusRates(buf, n, t.Rate1(), t.Rate5(), t.Rate15(), t.RateMean())
+		}
+	}
+	return buf.Bytes()
+}
+
+// prometheusFamilies returns the names of the samples exposed for metric
+// under the name n.
+func prometheusFamilies(n string, metric interface{}) []string {
+	rates := []string{n + "_rate1m", n + "_rate5m", n + "_rate15m", n + "_rate_mean"}
+	switch metric.(type) {
+	case metrics.Counter, metrics.Gauge, metrics.GaugeFloat64:
+		return []string{n}
+	case metrics.Histogram:
+		return []string{n, n + "_sum", n + "_count"}
+	case metrics.Meter:
+		return append([]string{n + "_total"}, rates...)
+	case metrics.Timer:
+		return append([]string{n, n + "_sum", n + "_count"}, rates...)
+	}
+	return nil
+}
+
+// firstOwner returns the metric owning the first of families already in
+// owners.
+func firstOwner(owners map[string]string, families []string) (string, bool) {
+	for _, family := range families {
+		if name, ok := owners[family]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func prometheusSummary(buf *bytes.Buffer, n string, qs, ps []float64, sum float64, count int64) {
+	fmt.Fprintf(buf, "# TYPE %s summary\n", n)
+	for psIdx, q := range qs {
+		fmt.Fprintf(buf, "%s{quantile=\"%s\"} %s\n", n, prometheusFloat(q), prometheusFloat(ps[psIdx]))
+	}
+	fmt.Fprintf(buf, "%s_sum %s\n", n, prometheusFloat(sum))
+	fmt.Fprintf(buf, "%s_count %d\n", n, count)
+}
+
+func prometheusRates(buf *bytes.Buffer, n string, rate1, rate5, rate15, rateMean float64) {
+	for _, rate := range []struct {
+		suffix string
+		value  float64
+	}{
+		{"rate1m", rate1},
+		{"rate5m", rate5},
+		{"rate15m", rate15},
+		{"rate_mean", rateMean},
+	} {
+		fmt.Fprintf(buf, "# TYPE %s_%s gauge\n", n, rate.suffix)
+		fmt.Fprintf(buf, "%s_%s %s\n", n, rate.suffix, prometheusFloat(rate.value))
+	}
+}
+
+// prometheusName joins prefix and name and replaces every character which
+// is not allowed in a Prometheus metric name with an underscore.
+func prometheusName(prefix, name string) string {
+	if "" != prefix {
+		name = prefix + "_" + name
+	}
+	n := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', '_' == r, ':' == r:
+			return r
+		}
+		return '_'
+	}, name)
+	if "" != n && '0' <= n[0] && n[0] <= '9' {
+		n = "_" + n
+	}
+	return n
+}
+
+func prometheusFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
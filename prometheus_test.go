@@ -0,0 +1,87 @@
+package graphite
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("foo", r).Inc(2)
+	metrics.GetOrRegisterGaugeFloat64("bar.baz", r).Update(1.5)
+	metrics.GetOrRegisterTimer("qux", r).Update(2 * time.Second)
+
+	srv := httptest.NewServer(PrometheusHandler(GraphiteConfig{
+		Registry:    r,
+		Prefix:      "some.prefix",
+		Percentiles: []float64{0.5, 0.99},
+	}))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatal("bad content type:", ct)
+	}
+
+	for _, expected := range []string{
+		"# TYPE some_prefix_foo gauge\nsome_prefix_foo 2\n",
+		"# TYPE some_prefix_bar_baz gauge\nsome_prefix_bar_baz 1.5\n",
+		"# TYPE some_prefix_qux summary\n",
+		"some_prefix_qux{quantile=\"0.5\"} 2\n",
+		"some_prefix_qux{quantile=\"0.99\"} 2\n",
+		"some_prefix_qux_sum 2\n",
+		"some_prefix_qux_count 1\n",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Fatalf("missing %q in:\n%s", expected, body)
+		}
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	for _, tc := range []struct{ prefix, name, expected string }{
+		{"", "foo", "foo"},
+		{"a.b", "c-d", "a_b_c_d"},
+		{"", "1xx", "_1xx"},
+	} {
+		if found := prometheusName(tc.prefix, tc.name); found != tc.expected {
+			t.Fatal("bad name:", tc.expected, found)
+		}
+	}
+}
+
+func TestPrometheusCollisions(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("a.b", r).Update(1)
+	metrics.GetOrRegisterGauge("a_b", r).Update(2)
+	metrics.GetOrRegisterMeter("m", r).Mark(1)
+	metrics.GetOrRegisterGauge("m.total", r).Update(3)
+	var collided []string
+	body := string(prometheus(&GraphiteConfig{Registry: r}, func(name, other string) {
+		collided = append(collided, name+" "+other)
+	}))
+	if 1 != strings.Count(body, "# TYPE a_b ") || !strings.Contains(body, "a_b 1\n") {
+		t.Fatal("colliding metrics exposed twice:", body)
+	}
+	if strings.Contains(body, "m_total 3") || !strings.Contains(body, "m_total 1") {
+		t.Fatal("metric colliding with a meter exposed:", body)
+	}
+	if expected := []string{"a_b a.b", "m.total m"}; !reflect.DeepEqual(expected, collided) {
+		t.Fatal("bad collisions:", collided)
+	}
+}
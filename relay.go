@@ -0,0 +1,166 @@
+package graphite
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cyberdelia/go-metrics-graphite/carbonparse"
+)
+
+// Relay accepts plaintext from other local processes and forwards it
+// upstream along with the metrics of an Exporter, replacing a sidecar relay.
+// Lines are forwarded as is, without prefix, and benefit from the queueing
+// of Exporter.Send: they are sent with the next flush and kept queued while
+// the upstream is unavailable, up to c.MaxRelayed datapoints shared by the
+// relays of the exporter, apart from the ones of Exporter.Send.
+type Relay struct {
+	e  *Exporter
+	ln net.Listener
+	pc net.PacketConn
+	wg sync.WaitGroup
+
+	mu     sync.Mutex // protects conns and closed
+	conns  map[net.Conn]bool
+	closed bool
+
+	received  int64
+	malformed int64
+	dropped   int64
+}
+
+// Relay listens on addr of network, which may be a stream network such as
+// "tcp" or "unix" or a datagram network such as "udp", and relays the
+// lines it receives through e until Close is called, or until e is stopped.
+func (e *Exporter) Relay(network, addr string) (*Relay, error) {
+	r := &Relay{e: e, conns: make(map[net.Conn]bool)}
+	var run func()
+	if datagram(network) {
+		pc, err := net.ListenPacket(network, addr)
+		if nil != err {
+			return nil, err
+		}
+		r.pc, run = pc, r.readPackets
+	} else {
+		ln, err := net.Listen(network, addr)
+		if nil != err {
+			return nil, err
+		}
+		r.ln, run = ln, r.accept
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	select {
+	case <-e.stop:
+		r.Close()
+		return nil, errStopped
+	default:
+	}
+	e.relays = append(e.relays, r)
+	e.spawn(&r.wg, run)
+	return r, nil
+}
+
+// Addr returns the address r listens on.
+func (r *Relay) Addr() net.Addr {
+	if nil != r.pc {
+		return r.pc.LocalAddr()
+	}
+	return r.ln.Addr()
+}
+
+// Received returns the number of datapoints relayed so far.
+func (r *Relay) Received() int64 {
+	return atomic.LoadInt64(&r.received)
+}
+
+// Malformed returns the number of lines dropped because they could not be
+// parsed.
+func (r *Relay) Malformed() int64 {
+	return atomic.LoadInt64(&r.malformed)
+}
+
+// Dropped returns the number of datapoints received by r which were
+// dropped from the queue before they could be sent, c.MaxRelayed being
+// reached.
+func (r *Relay) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// Close stops listening, closes the connections of the clients and waits
+// for the lines being read to be queued.
+func (r *Relay) Close() error {
+	var err error
+	if nil != r.pc {
+		err = r.pc.Close()
+	} else {
+		err = r.ln.Close()
+	}
+	r.mu.Lock()
+	r.closed = true
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+	return err
+}
+
+func (r *Relay) accept() {
+	for {
+		conn, err := r.ln.Accept()
+		if nil != err {
+			return
+		}
+		// Connections accepted while r is being closed are not read.
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			return
+		}
+		r.conns[conn] = true
+		r.mu.Unlock()
+		r.e.spawn(&r.wg, func() {
+			r.read(conn)
+			r.mu.Lock()
+			delete(r.conns, conn)
+			r.mu.Unlock()
+			conn.Close()
+		})
+	}
+}
+
+func (r *Relay) readPackets() {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := r.pc.ReadFrom(buf)
+		if nil != err {
+			return
+		}
+		r.read(bytes.NewReader(buf[:n]))
+	}
+}
+
+// read queues every datapoint read from rd.
+func (r *Relay) read(rd io.Reader) {
+	pr := carbonparse.NewReader(rd)
+	for {
+		d, err := pr.Next()
+		if nil != err {
+			if _, ok := err.(*carbonparse.ParseError); !ok {
+				return
+			}
+			atomic.AddInt64(&r.malformed, 1)
+			log.Println(err)
+			continue
+		}
+		r.e.mu.Lock()
+		r.e.enqueue(datapoint{path: d.Series(), value: d.Value, precision: -1, timestamp: d.Timestamp, relay: r})
+		r.e.mu.Unlock()
+		atomic.AddInt64(&r.received, 1)
+	}
+}
@@ -0,0 +1,102 @@
+package graphite
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRelay(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	e := New(c)
+
+	tcp, err := e.Relay("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	udp, err := e.Relay("udp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	conn, err := net.Dial("tcp", tcp.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("other.app.requests 3 1234567890\nbroken\nother.app.load;host=a 0.5 1234567890\n"))
+	conn.Close()
+	pconn, err := net.Dial("udp", udp.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	pconn.Write([]byte("other.app.requests 2 1234567890\n"))
+	pconn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for tcp.Received()+udp.Received() < 3 || tcp.Malformed() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("lines not relayed:", tcp.Received(), udp.Received(), tcp.Malformed())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	wg.Add(1)
+	if r := e.flush(); nil != r.Err {
+		t.Fatal(r.Err)
+	}
+	wg.Wait()
+	if expected, found := 5.0, res["other.app.requests"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+	if expected, found := 0.5, res["other.app.load;host=a"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+}
+
+func TestRelayBound(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.MaxQueued = 1
+	c.MaxRelayed = 2
+	e := New(c)
+	e.Send("marker", 1, time.Now())
+
+	r, err := e.Relay("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", r.Addr().String())
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("a 1 1234567890\nb 1 1234567890\nc 1 1234567890\n"))
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Received() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("lines not relayed:", r.Received())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	r.Close()
+	if 1 != r.Dropped() {
+		t.Fatal("bad drop count:", r.Dropped())
+	}
+
+	wg.Add(1)
+	if r := e.flush(); nil != r.Err {
+		t.Fatal(r.Err)
+	}
+	wg.Wait()
+	for _, name := range []string{"foobar.marker", "b", "c"} {
+		if _, ok := res[name]; !ok {
+			t.Fatal("datapoint missing:", name, res)
+		}
+	}
+	if _, ok := res["a"]; ok {
+		t.Fatal("oldest relayed datapoint not dropped:", res)
+	}
+}
@@ -0,0 +1,72 @@
+package graphite
+
+import (
+	"context"
+	"net"
+	"slices"
+	"time"
+)
+
+// keepFailed keeps dps, datapoints a flush failed to send, in memory to be
+// resent by replayFailed, dropping the oldest payloads beyond
+// c.ReplayPayloads and c.ReplayMaxBytes. It returns false, so that they are
+// queued instead, if c.ReplayPayloads is not set.
+func (e *Exporter) keepFailed(dps []datapoint) bool {
+	c := &e.c
+	if c.ReplayPayloads <= 0 || 0 == len(dps) {
+		return false
+	}
+	max := c.ReplayMaxBytes
+	if max <= 0 {
+		max = 4 << 20
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.enforce()
+	e.replays = append(e.replays, slices.Clone(dps))
+	e.replayed += payloadSize(dps)
+	for len(e.replays) > c.ReplayPayloads || (e.replayed > max && len(e.replays) > 1) {
+		e.replayed -= payloadSize(e.replays[0])
+		e.replays = e.replays[1:]
+	}
+	return true
+}
+
+// payloadSize returns the bytes held by dps.
+func payloadSize(dps []datapoint) int {
+	n := 0
+	for _, dp := range dps {
+		n += dp.size()
+	}
+	return n
+}
+
+// replayFailed resends the payloads kept by keepFailed on conn, which
+// delivered a flush, oldest first. A payload partly delivered is replaced
+// by the datapoints left to send. It returns the error which broke conn,
+// if any.
+func (e *Exporter) replayFailed(ctx context.Context, conn net.Conn) error {
+	for {
+		e.mu.Lock()
+		if 0 == len(e.replays) {
+			e.mu.Unlock()
+			return nil
+		}
+		dps := e.replays[0]
+		e.mu.Unlock()
+		_, sent, err := e.attempt(ctx, conn, time.Now(), dps)
+		e.mu.Lock()
+		// The payload may have been dropped meanwhile by enforce.
+		if 0 != len(e.replays) && &e.replays[0][0] == &dps[0] {
+			e.replayed -= payloadSize(dps[:sent])
+			e.replays[0] = dps[sent:]
+			if 0 == len(e.replays[0]) {
+				e.replays = e.replays[1:]
+			}
+		}
+		e.mu.Unlock()
+		if nil != err {
+			return err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package graphite
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayFailed(t *testing.T) {
+	up := false
+	e, sent := NewSpoolExporter(t, "", &up)
+	e.c.ReplayPayloads = 2
+	e.Send("event", 1, time.Unix(1000, 0))
+	if err := e.flush().Err; nil == err {
+		t.Fatal("flush did not fail")
+	}
+	if 1 != len(e.replays) || 0 != len(e.queue) {
+		t.Fatal("failed payload not kept:", e.replays, e.queue)
+	}
+	if 0 == e.Memory().Replays {
+		t.Fatal("kept payload not measured")
+	}
+
+	up = true
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	lines := sent()
+	joined := strings.Join(lines, "\n")
+	if 3 != len(lines) || 2 != strings.Count(joined, "p.foo 1 ") || !strings.Contains(joined, "p.event 1 1000") {
+		t.Fatal("kept payload not resent:", lines)
+	}
+	if 0 != len(e.replays) || 0 != e.replayed {
+		t.Fatal("resent payload still kept:", e.replays, e.replayed)
+	}
+}
+
+func TestReplayFailedLimits(t *testing.T) {
+	e := New(GraphiteConfig{ReplayPayloads: 2})
+	for i := 0; i < 3; i++ {
+		e.keepFailed([]datapoint{{path: "foo", value: float64(i)}})
+	}
+	if 2 != len(e.replays) || !floatEquals(1, e.replays[0][0].value) {
+		t.Fatal("oldest payload not dropped:", e.replays)
+	}
+
+	size := payloadSize([]datapoint{{path: "foo"}})
+	e = New(GraphiteConfig{ReplayPayloads: 10, ReplayMaxBytes: 2 * size})
+	for i := 0; i < 3; i++ {
+		e.keepFailed([]datapoint{{path: "foo", value: float64(i)}})
+	}
+	if 2 != len(e.replays) || 2*size != e.replayed || !floatEquals(1, e.replays[0][0].value) {
+		t.Fatal("oldest payload not dropped by size:", e.replays, e.replayed)
+	}
+
+	e = New(GraphiteConfig{ReplayPayloads: 10, MemoryLimit: size})
+	e.keepFailed([]datapoint{{path: "foo"}})
+	e.keepFailed([]datapoint{{path: "foo"}})
+	if m := e.Memory(); 1 != len(e.replays) || 1 != m.Dropped {
+		t.Fatal("payload not dropped by the memory limit:", e.replays, m)
+	}
+
+	if e := New(GraphiteConfig{}); e.keepFailed([]datapoint{{path: "foo"}}) {
+		t.Fatal("payload kept without ReplayPayloads")
+	}
+}
@@ -0,0 +1,67 @@
+package graphite
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// attempt writes dps, the datapoints of the flush started at t which are
+// left to send, to conn. It returns the bytes written and the number of
+// datapoints delivered, the first ones of dps.
+func (e *Exporter) attempt(ctx context.Context, conn net.Conn, t time.Time, dps []datapoint) (int, int, error) {
+	// The deadline is set before ctx is checked so that it never overrides
+	// the one set once ctx is done.
+	if e.c.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(e.c.WriteTimeout))
+	}
+	if err := ctx.Err(); nil != err {
+		return 0, 0, err
+	}
+	n, sent, err := e.write(conn, t, dps)
+	if sent, err = e.acknowledged(conn, sent, err); nil != err {
+		e.failover(ctx, err)
+	}
+	return n, sent, err
+}
+
+// deliver writes dps, the datapoints of the flush res, to conn, reconnecting
+// and resuming with the datapoints left to send up to c.WriteRetries times.
+// It returns the connection last dialed, nil if reconnecting failed, along
+// with the number of datapoints delivered. *stop stops the watch of the
+// connection, see watch, and is replaced on every reconnection.
+func (e *Exporter) deliver(ctx context.Context, conn net.Conn, stop *func() bool, res *FlushResult, dps []datapoint) (net.Conn, int) {
+	sent := 0
+	for retries := 0; ; retries++ {
+		n, more, err := e.attempt(ctx, conn, res.Time, dps[sent:])
+		res.Bytes, sent, res.Err = res.Bytes+n, sent+more, err
+		if nil == res.Err || retries >= e.c.WriteRetries || nil != ctx.Err() {
+			return conn, sent
+		}
+		(*stop)()
+		if conn, res.Addr, err = e.reconnect(ctx, conn, res.Err); nil != err {
+			res.Err = err
+			return conn, sent
+		}
+		*stop = watch(ctx, conn)
+	}
+}
+
+// watch sets a deadline in the past on conn once ctx is done, so that the
+// flush using it is aborted, until the returned function is called.
+func watch(ctx context.Context, conn net.Conn) func() bool {
+	return context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+}
+
+// reconnect closes conn, whose write failed with err, and dials a new
+// connection on which a flush resumes after c.WriteRetries. The new
+// connection, nil if the dial failed, replaces conn as the one kept open
+// with c.KeepAlive, e.connMu being held by the flush.
+func (e *Exporter) reconnect(ctx context.Context, conn net.Conn, err error) (net.Conn, string, error) {
+	e.disconnect(conn, err)
+	conn, addr, err := e.connect(ctx)
+	if keepAlive(&e.c) {
+		e.conn, e.connAddr = conn, addr
+	}
+	return conn, addr, err
+}
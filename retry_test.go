@@ -0,0 +1,112 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestWriteRetries(t *testing.T) {
+	for _, retries := range []int{0, 1} {
+		var mu sync.Mutex
+		var lines []string
+		var wg sync.WaitGroup
+		dials := 0
+		e := New(GraphiteConfig{
+			Addr:          "carbon:2003",
+			Registry:      metrics.NewRegistry(),
+			FlushInterval: time.Second,
+			Prefix:        "p",
+			WriteRetries:  retries,
+			DialFunc: func(context.Context, string, string) (net.Conn, error) {
+				client, server := net.Pipe()
+				dials++
+				// The first connection breaks once 200 bytes were read.
+				var r io.Reader = server
+				if 1 == dials {
+					r = io.LimitReader(server, 200)
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer server.Close()
+					scanner := bufio.NewScanner(r)
+					for scanner.Scan() {
+						mu.Lock()
+						lines = append(lines, scanner.Text())
+						mu.Unlock()
+					}
+				}()
+				return client, nil
+			},
+		})
+		for i := 0; i < 50; i++ {
+			e.Send(fmt.Sprintf("metric%02d", i), 1, time.Unix(1, 0))
+		}
+		err := e.flush().Err
+		wg.Wait()
+		if 0 == retries {
+			if nil == err || 1 != dials {
+				t.Fatal("broken write did not fail:", dials, err)
+			}
+			continue
+		}
+		if nil != err {
+			t.Fatal(err)
+		}
+		if 2 != dials {
+			t.Fatal("bad number of dials:", dials)
+		}
+		seen := make(map[string]bool)
+		for _, line := range lines {
+			seen[strings.Fields(line)[0]] = true
+		}
+		for i := 0; i < 50; i++ {
+			if name := fmt.Sprintf("p.metric%02d", i); !seen[name] {
+				t.Fatal("datapoint lost:", name, lines)
+			}
+		}
+		if 0 != len(e.queue) {
+			t.Fatal("datapoints queued again:", e.queue)
+		}
+	}
+}
+
+func TestWriteRetriesDialFailure(t *testing.T) {
+	dials := 0
+	e := New(GraphiteConfig{
+		Addr:          "carbon:2003",
+		Registry:      metrics.NewRegistry(),
+		FlushInterval: time.Second,
+		KeepAlive:     true,
+		WriteRetries:  3,
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			if dials++; dials > 1 {
+				return nil, errors.New("unreachable")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	})
+	e.Send("foo", 1, time.Now())
+	if err := e.flush().Err; nil == err || "unreachable" != err.Error() {
+		t.Fatal("failed reconnection not reported:", err)
+	}
+	if 2 != dials || nil != e.conn || 1 != len(e.queue) {
+		t.Fatal("bad state after a failed reconnection:", dials, e.conn, e.queue)
+	}
+	// The connection lock was released.
+	if err := e.flush().Err; nil == err || 3 != dials {
+		t.Fatal("bad flush after a failed reconnection:", dials, err)
+	}
+}
@@ -0,0 +1,30 @@
+package graphite
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"os"
+)
+
+// PIDRunID returns a short identifier derived from the host name and the
+// process ID, suitable for GraphiteConfig.RunID. It is stable for the
+// lifetime of the process and distinguishes processes running on the same
+// host, so that their series do not interleave.
+func PIDRunID() string {
+	host, _ := os.Hostname()
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s/%d", host, os.Getpid())
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// RandomRunID returns a random 8 characters identifier suitable for
+// GraphiteConfig.RunID.
+func RandomRunID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); nil != err {
+		return PIDRunID()
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,20 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestRunID(t *testing.T) {
+	if id := PIDRunID(); len(id) != 8 || id != PIDRunID() {
+		t.Fatal("bad pid run id:", id)
+	}
+	if id := RandomRunID(); len(id) != 8 || id == RandomRunID() {
+		t.Fatal("bad random run id:", id)
+	}
+
+	c := &GraphiteConfig{Prefix: "app", RunID: "abcd1234"}
+	snaps := []MetricSnapshot{NewCounterSnapshot("foo", 1)}
+	if found := seriesName(c, snaps[0], snaps[0].Fields[0]); found != "app.abcd1234.foo" {
+		t.Fatal("bad series name:", found)
+	}
+}
@@ -0,0 +1,161 @@
+package graphite
+
+import (
+	"log"
+	"slices"
+	"strings"
+)
+
+// Versions of the set of fields every metric type emits, see
+// GraphiteConfig.SchemaVersion. A version never changes once released so
+// that pinning it pins the series an upgrade of this package emits.
+const (
+	SchemaV1 = 1 // Fields of the original exporter, with histogram percentiles spelled "-precentile"
+	SchemaV2 = 2 // Histogram percentiles spelled "-percentile", like those of timers
+
+	// SchemaGoMetrics holds the fields of the exporter formerly part of
+	// github.com/rcrowley/go-metrics: those of SchemaV2, with counters sent
+	// as "<name>.count" and gauges as "<name>.value".
+	SchemaGoMetrics = 3
+
+	LatestSchema = SchemaV2
+)
+
+// percentiles stands for the percentile fields in the field lists of
+// schemas.
+const percentiles = "%percentiles"
+
+// fieldSchema lists the fields emitted for every metric type, in order.
+type fieldSchema struct {
+	fields map[string][]string
+
+	// histogramPercentile is the suffix of the percentile fields of
+	// histograms.
+	histogramPercentile string
+
+	// values names the field of the metric types holding a single value,
+	// which is otherwise sent as the series of the metric itself.
+	values map[string]string
+}
+
+var schemas = map[int]fieldSchema{
+	SchemaV1: {
+		fields: map[string][]string{
+			TypeCounter:      {""},
+			TypeGauge:        {""},
+			TypeGaugeFloat64: {""},
+			TypeHistogram:    {"count", "min", "max", "mean", "std-dev", percentiles},
+			TypeMeter:        {"count", "one-minute", "five-minute", "fifteen-minute", "mean"},
+			TypeTimer:        {"count", "min", "max", "mean", "std-dev", percentiles, "one-minute", "five-minute", "fifteen-minute", "mean-rate"},
+		},
+		histogramPercentile: "-precentile",
+	},
+	SchemaV2: {
+		fields: map[string][]string{
+			TypeCounter:      {""},
+			TypeGauge:        {""},
+			TypeGaugeFloat64: {""},
+			TypeHistogram:    {"count", "min", "max", "mean", "std-dev", percentiles},
+			TypeMeter:        {"count", "one-minute", "five-minute", "fifteen-minute", "mean"},
+			TypeTimer:        {"count", "min", "max", "mean", "std-dev", percentiles, "one-minute", "five-minute", "fifteen-minute", "mean-rate"},
+		},
+		histogramPercentile: "-percentile",
+	},
+	SchemaGoMetrics: {
+		fields: map[string][]string{
+			TypeCounter:      {""},
+			TypeGauge:        {""},
+			TypeGaugeFloat64: {""},
+			TypeHistogram:    {"count", "min", "max", "mean", "std-dev", percentiles},
+			TypeMeter:        {"count", "one-minute", "five-minute", "fifteen-minute", "mean"},
+			TypeTimer:        {"count", "min", "max", "mean", "std-dev", percentiles, "one-minute", "five-minute", "fifteen-minute", "mean-rate"},
+		},
+		histogramPercentile: "-percentile",
+		values: map[string]string{
+			TypeCounter:      "count",
+			TypeGauge:        "value",
+			TypeGaugeFloat64: "value",
+		},
+	},
+}
+
+// checkSchema logs an unknown c.SchemaVersion, for which LatestSchema is
+// used.
+func checkSchema(c *GraphiteConfig) {
+	if _, ok := schemas[c.SchemaVersion]; !ok && 0 != c.SchemaVersion {
+		log.Printf("graphite: unknown schema version %d, using %d", c.SchemaVersion, LatestSchema)
+	}
+}
+
+// schema returns the field schema selected by c.SchemaVersion: SchemaV1 if
+// zero so that existing series are kept, LatestSchema if unknown.
+func schema(c *GraphiteConfig) fieldSchema {
+	v := c.SchemaVersion
+	if 0 == v {
+		v = SchemaV1
+	}
+	if s, ok := schemas[v]; ok {
+		return s
+	}
+	return schemas[LatestSchema]
+}
+
+// SchemaFields returns the names of the fields emitted for metrics of type
+// typ according to c, in order, which makes the output contract of a
+// configuration testable.
+func SchemaFields(c *GraphiteConfig, typ string) []string {
+	s := schema(c)
+	names := make([]string, 0)
+	for _, name := range s.fields[typ] {
+		if percentiles != name {
+			names = append(names, name)
+			continue
+		}
+		suffix := "-percentile"
+		if TypeHistogram == typ {
+			suffix = s.histogramPercentile
+		}
+		for _, p := range c.Percentiles {
+			names = append(names, percentileKey(p)+suffix)
+		}
+	}
+	if keep, ok := c.SummaryFields[typ]; ok {
+		kept := names[:0]
+		for _, name := range names {
+			if slices.Contains(keep, strings.Replace(name, "-precentile", "-percentile", 1)) {
+				kept = append(kept, name)
+			}
+		}
+		names = kept
+	}
+	return names
+}
+
+// CompactFields returns SummaryFields keeping the count and the 99th
+// percentile of histograms and timers, and the one-minute rate of meters,
+// which cuts the series of a registry by about 80%. The 99th percentile is
+// only emitted if 0.99 is one of GraphiteConfig.Percentiles.
+func CompactFields() map[string][]string {
+	return map[string][]string{
+		TypeHistogram: {"count", "99-percentile"},
+		TypeTimer:     {"count", "99-percentile"},
+		TypeMeter:     {"one-minute"},
+	}
+}
+
+// applySchema orders the fields of s according to c, dropping those which
+// are not part of its schema.
+func applySchema(c *GraphiteConfig, s MetricSnapshot) MetricSnapshot {
+	byName := make(map[string]Field, len(s.Fields))
+	for _, f := range s.Fields {
+		byName[f.Name] = f
+	}
+	fields := make([]Field, 0, len(s.Fields))
+	for _, name := range SchemaFields(c, s.Type) {
+		if f, ok := byName[name]; ok {
+			fields = append(fields, f)
+		}
+	}
+	s.Fields = fields
+	return s
+}
@@ -0,0 +1,132 @@
+package graphite
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSchemaVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version  int
+		expected string
+	}{
+		{0, "99-precentile"},
+		{SchemaV1, "99-precentile"},
+		{SchemaV2, "99-percentile"},
+		{1000, "99-percentile"},
+	} {
+		c := &GraphiteConfig{DurationUnit: time.Millisecond, Percentiles: []float64{0.99}, SchemaVersion: tc.version}
+		h := NewHistogramSnapshot(c, "h", []int64{1, 2})
+		if f := h.Fields[len(h.Fields)-1]; tc.expected != f.Name {
+			t.Error("bad percentile field:", tc.version, f.Name)
+		}
+	}
+}
+
+func TestSchemaFields(t *testing.T) {
+	c := &GraphiteConfig{DurationUnit: time.Millisecond, Percentiles: []float64{0.5, 0.999}, SchemaVersion: SchemaV2}
+	for _, s := range []MetricSnapshot{
+		NewCounterSnapshot("c", 1),
+		NewGaugeFloat64Snapshot("g", 1),
+		NewHistogramSnapshot(c, "h", []int64{1, 2}),
+		NewMeterSnapshot("m", 1, Rates{}),
+		NewTimerSnapshot(c, "t", []time.Duration{time.Second}, Rates{}),
+	} {
+		names := make([]string, 0)
+		for _, f := range s.Fields {
+			names = append(names, f.Name)
+		}
+		if expected := SchemaFields(c, s.Type); !reflect.DeepEqual(names, expected) {
+			t.Error("fields do not follow the schema:", s.Type, names, expected)
+		}
+	}
+	expected := []string{"count", "min", "max", "mean", "std-dev", "50-percentile", "999-percentile", "one-minute", "five-minute", "fifteen-minute", "mean-rate"}
+	if names := SchemaFields(c, TypeTimer); !reflect.DeepEqual(names, expected) {
+		t.Fatal("bad timer fields:", names)
+	}
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	c := &GraphiteConfig{DurationUnit: time.Millisecond, Percentiles: []float64{0.5}, SchemaVersion: SchemaV2}
+	for name, m := range map[string]interface{}{
+		"c": metrics.NewCounter(),
+		"g": metrics.NewGauge(),
+		"f": metrics.NewGaugeFloat64(),
+		"h": metrics.NewHistogram(metrics.NewUniformSample(10)),
+		"m": metrics.NewMeter(),
+		"t": metrics.NewTimer(),
+	} {
+		s, _ := SnapshotOf(c, name, m)
+		names := make([]string, 0)
+		for _, f := range s.Fields {
+			names = append(names, f.Name)
+		}
+		if expected := SchemaFields(c, s.Type); !reflect.DeepEqual(names, expected) {
+			t.Error("fields do not follow the schema:", s.Type, names, expected)
+		}
+	}
+}
+
+func TestUnknownSchema(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	New(GraphiteConfig{SchemaVersion: 1000})
+	if !strings.Contains(buf.String(), "unknown schema version 1000") {
+		t.Fatal("unknown version not reported:", buf.String())
+	}
+}
+
+func TestCompactFields(t *testing.T) {
+	for _, version := range []int{SchemaV1, SchemaV2} {
+		c := &GraphiteConfig{SchemaVersion: version, Percentiles: []float64{0.5, 0.99}, SummaryFields: CompactFields()}
+		for typ, expected := range map[string]string{
+			TypeCounter:   "",
+			TypeGauge:     "",
+			TypeHistogram: "count 99-percentile",
+			TypeTimer:     "count 99-percentile",
+			TypeMeter:     "one-minute",
+		} {
+			found := strings.Join(SchemaFields(c, typ), " ")
+			if TypeHistogram == typ && SchemaV1 == version {
+				expected = "count 99-precentile"
+			}
+			if expected != found {
+				t.Error("bad compact fields:", version, typ, found)
+			}
+		}
+		s := NewHistogramSnapshot(c, "h", []int64{1, 2, 3})
+		if 2 != len(s.Fields) {
+			t.Error("bad compact histogram:", version, s.Fields)
+		}
+	}
+	c := GraphiteConfig{SummaryFields: CompactFields()}
+	e := New(c)
+	c.SummaryFields[TypeMeter][0] = "mean"
+	if "one-minute" != e.c.SummaryFields[TypeMeter][0] {
+		t.Fatal("summary fields shared with the caller")
+	}
+}
+
+func TestSchemaGoMetrics(t *testing.T) {
+	c := &GraphiteConfig{Prefix: "p", SchemaVersion: SchemaGoMetrics}
+	for s, expected := range map[*MetricSnapshot]string{
+		{Name: "c", Type: TypeCounter}:      "p.c.count",
+		{Name: "g", Type: TypeGauge}:        "p.g.value",
+		{Name: "f", Type: TypeGaugeFloat64}: "p.f.value",
+	} {
+		if found := seriesName(c, *s, Field{}); expected != found {
+			t.Error("bad series:", expected, found)
+		}
+	}
+	if found := seriesName(c, MetricSnapshot{Name: "m", Type: TypeMeter}, Field{Name: "count"}); "p.m.count" != found {
+		t.Error("bad meter series:", found)
+	}
+}
@@ -0,0 +1,110 @@
+package graphite
+
+import (
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// self returns the datapoints describing the exporter itself for a full
+// flush of the series counted by namespace, sent under "<prefix>.exporter".
+func (e *Exporter) self(series map[string]int, ts time.Time) []datapoint {
+	e.mu.Lock()
+	e.series = series
+	e.mu.Unlock()
+
+	dps := make([]datapoint, 0)
+	root := prefix(&e.c) + ".exporter."
+	if e.c.ReportSeriesCounts {
+		for _, ns := range sortedKeys(series) {
+			dps = append(dps, datapoint{path: root + "series." + ns, value: float64(series[ns]), timestamp: ts.Unix()})
+		}
+	}
+	if gap, ok := e.gap(ts); ok && e.c.ReportGaps {
+		dps = append(dps, datapoint{path: root + "gap-seconds", value: gap.Seconds(), timestamp: ts.Unix()})
+	}
+	if e.c.ReportThroughput {
+		t := e.Throughput()
+		for _, rate := range []struct {
+			name  string
+			value float64
+		}{
+			{"lines.one-minute", t.Lines1},
+			{"lines.five-minute", t.Lines5},
+			{"bytes.one-minute", t.Bytes1},
+			{"bytes.five-minute", t.Bytes5},
+		} {
+			dps = append(dps, datapoint{path: root + rate.name, value: rate.value, precision: 2, timestamp: ts.Unix()})
+		}
+	}
+	if e.c.ReportConfig {
+		for _, v := range configValues(&e.c, series) {
+			dps = append(dps, datapoint{path: root + v.name, value: v.value, precision: -1, timestamp: ts.Unix()})
+		}
+	}
+	for i := range dps {
+		dps[i].path = tagged(&e.c, dps[i].path)
+	}
+	return dps
+}
+
+// gap returns the time during which no flush succeeded before the flush
+// starting at ts, beyond the usual flush interval, if at least half an
+// interval was missed. Gaps are detected across restarts with c.StateFile.
+func (e *Exporter) gap(ts time.Time) (time.Duration, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.succeeded.IsZero() {
+		return 0, false
+	}
+	gap := ts.Sub(e.succeeded) - e.c.FlushInterval
+	return gap.Truncate(time.Second), gap >= e.c.FlushInterval/2 && gap >= time.Second
+}
+
+// SeriesCounts returns the number of series sent by the last complete flush
+// for every top-level namespace, that is the first node of metric names.
+func (e *Exporter) SeriesCounts() map[string]int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	counts := make(map[string]int, len(e.series))
+	for ns, n := range e.series {
+		counts[ns] = n
+	}
+	return counts
+}
+
+// configValue is a series describing the configuration of an exporter.
+type configValue struct {
+	name  string
+	value float64
+}
+
+// configValues returns the series describing c, for a flush of the series
+// counted by namespace: the flush interval, the total number of series and
+// a hash of the destination, so that exporters of a fleet sending to the
+// wrong place or at the wrong interval stand out.
+func configValues(c *GraphiteConfig, series map[string]int) []configValue {
+	total := 0
+	for _, n := range series {
+		total += n
+	}
+	dest := strings.Join(endpoints(c), ",")
+	if "" != c.SRV {
+		dest = c.SRV
+	}
+	h := fnv.New32a()
+	h.Write([]byte(network(c) + " " + dest))
+	return []configValue{
+		{"config.flush-interval-seconds", c.FlushInterval.Seconds()},
+		{"config.series", float64(total)},
+		{"config.destination-hash", float64(h.Sum32())},
+	}
+}
+
+// namespace returns the first node of name.
+func namespace(name string) string {
+	if i := strings.IndexAny(name, ".;"); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
@@ -0,0 +1,90 @@
+package graphite
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSeriesCounts(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+
+	metrics.GetOrRegisterCounter("db.queries", r).Inc(1)
+	metrics.GetOrRegisterMeter("db.errors", r).Mark(1)
+	metrics.GetOrRegisterGauge("http", r).Update(1)
+
+	c.ReportSeriesCounts = true
+	e := New(c)
+	wg.Add(1)
+	e.flush()
+	wg.Wait()
+
+	if counts := e.SeriesCounts(); counts["db"] != 6 || counts["http"] != 1 {
+		t.Fatal("bad counts:", counts)
+	}
+	if expected, found := 6.0, res["foobar.exporter.series.db"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+	if expected, found := 1.0, res["foobar.exporter.series.http"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+}
+
+func TestReportGaps(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.ReportGaps = true
+	c.FlushInterval = 10 * time.Second
+	c.StateFile = filepath.Join(t.TempDir(), "state.json")
+
+	e := New(c)
+	wg.Add(1)
+	e.Flush()
+	wg.Wait()
+	if _, ok := res["foobar.exporter.gap-seconds"]; ok {
+		t.Fatal("gap reported on the first flush:", res)
+	}
+
+	// A restarted exporter finds out flushes were missed while it was down.
+	e = New(c)
+	e.loadState()
+	e.succeeded = e.succeeded.Add(-time.Minute)
+	wg.Add(1)
+	e.Flush()
+	wg.Wait()
+	if found := res["foobar.exporter.gap-seconds"]; found < 49 || found > 51 {
+		t.Fatal("bad gap:", found)
+	}
+}
+
+func TestReportConfig(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	metrics.GetOrRegisterGauge("bar", r).Update(1)
+	c.ReportConfig = true
+	c.FlushInterval = 10 * time.Second
+	e := New(c)
+	wg.Add(1)
+	e.flush()
+	wg.Wait()
+
+	if expected, found := 10.0, res["foobar.exporter.config.flush-interval-seconds"]; !floatEquals(found, expected) {
+		t.Fatal("bad flush interval:", expected, found)
+	}
+	if expected, found := 2.0, res["foobar.exporter.config.series"]; !floatEquals(found, expected) {
+		t.Fatal("bad series count:", expected, found)
+	}
+	hash := res["foobar.exporter.config.destination-hash"]
+	c.Addr = "elsewhere:2003"
+	if 0 == hash || floatEquals(hash, configValues(&c, nil)[2].value) {
+		t.Fatal("destination hash does not tell destinations apart:", hash)
+	}
+	if names := SeriesNames(&c); !slices.Contains(names, "foobar.exporter.config.destination-hash") {
+		t.Fatal("configuration series not named:", names)
+	}
+}
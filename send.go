@@ -0,0 +1,89 @@
+package graphite
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Send queues an ad-hoc datapoint, such as a deployment marker or the
+// result of a batch job with a historical timestamp, which is sent with the
+// next flush using the same connection and prefix as the registry metrics.
+// Datapoints are kept queued until a flush succeeded. When more than
+// c.MaxQueued datapoints are queued the oldest ones are dropped. Names
+// holding a newline are reported to c.OnMetricError and not queued. A zero
+// ts stands for the time of the call.
+func (e *Exporter) Send(name string, value float64, ts time.Time) {
+	path := tagged(&e.c, prefix(&e.c)+"."+name)
+	if brokenPath(path) {
+		skipMetric(&e.c, name, fmt.Errorf("newline in series %q", path))
+		return
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.enqueue(datapoint{path: path, value: value, precision: -1, timestamp: ts.Unix()})
+}
+
+// enqueue appends dps to the queue. Datapoints received by a Relay are
+// queued apart, up to c.MaxRelayed, so that other processes cannot crowd
+// out the datapoints of Send. It needs e.mu.
+func (e *Exporter) enqueue(dps ...datapoint) {
+	for _, dp := range dps {
+		e.queued += dp.size()
+		if nil == dp.relay {
+			e.queue = append(e.queue, dp)
+		} else {
+			e.relayed = append(e.relayed, dp)
+		}
+	}
+	e.queue = e.drop(e.queue, len(e.queue)-maxQueued(e.c.MaxQueued))
+	e.relayed = e.drop(e.relayed, len(e.relayed)-maxQueued(e.c.MaxRelayed))
+	e.enforce()
+}
+
+// maxQueued returns the number of datapoints a queue bounded by max holds,
+// 10000 if zero.
+func maxQueued(max int) int {
+	if max <= 0 {
+		return 10000
+	}
+	return max
+}
+
+// drop drops the n oldest of dps and accounts for the relayed ones in the
+// drop counter of their Relay. It needs e.mu.
+func (e *Exporter) drop(dps []datapoint, n int) []datapoint {
+	if n <= 0 {
+		return dps
+	}
+	for _, dp := range dps[:n] {
+		e.queued -= dp.size()
+		if nil != dp.relay {
+			atomic.AddInt64(&dp.relay.dropped, 1)
+		}
+	}
+	return append(dps[:0], dps[n:]...)
+}
+
+// dequeue empties the queue and returns its content, the datapoints of Send
+// first.
+func (e *Exporter) dequeue() []datapoint {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	dps := append(e.queue, e.relayed...)
+	e.queue, e.relayed, e.queued = nil, nil, 0
+	return dps
+}
+
+// requeue puts dps, dequeued datapoints which failed to be sent, back at
+// the front of the queue.
+func (e *Exporter) requeue(dps []datapoint) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	queue, relayed := e.queue, e.relayed
+	e.queue, e.relayed, e.queued = nil, nil, 0
+	e.enqueue(append(append(dps, queue...), relayed...)...)
+}
@@ -0,0 +1,72 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+
+	e := New(c)
+	e.Send("deploy", 1, time.Unix(1234567890, 0))
+	e.Send("batch.rows", 1.5, time.Unix(1234567800, 0))
+
+	wg.Add(1)
+	if r := e.flush(); r.Err != nil || r.Lines != 2 {
+		t.Fatal("bad flush:", r)
+	}
+	wg.Wait()
+
+	if expected, found := 1.0, res["foobar.deploy"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+	if expected, found := 1.5, res["foobar.batch.rows"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+	if len(e.queue) != 0 {
+		t.Fatal("queue not drained:", e.queue)
+	}
+
+	// Datapoints stay queued while the endpoint is down.
+	l.Close()
+	e.Send("deploy", 1, time.Now())
+	if r := e.flush(); r.Err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(e.queue) != 1 {
+		t.Fatal("datapoint lost:", e.queue)
+	}
+}
+
+func TestSendMaxQueued(t *testing.T) {
+	e := New(GraphiteConfig{Prefix: "p", MaxQueued: 2})
+	for i := 0; i < 3; i++ {
+		e.Send("foo", float64(i), time.Unix(int64(i), 0))
+	}
+	if len(e.queue) != 2 || e.queue[0].value != 1 || e.queue[0].path != "p.foo" {
+		t.Fatal("bad queue:", e.queue)
+	}
+}
+
+func TestSendNewline(t *testing.T) {
+	var skipped []string
+	e := New(GraphiteConfig{Prefix: "p", OnMetricError: func(err *MetricError) { skipped = append(skipped, err.Name) }})
+	e.Send("bad\nname", 1, time.Now())
+	e.Send("good", 1, time.Now())
+	if 1 != len(e.queue) || "p.good" != e.queue[0].path {
+		t.Fatal("bad queue:", e.queue)
+	}
+	if 1 != len(skipped) || "bad\nname" != skipped[0] {
+		t.Fatal("bad name not reported:", skipped)
+	}
+}
+
+func TestSendZeroTime(t *testing.T) {
+	e := New(GraphiteConfig{Prefix: "p"})
+	before := time.Now().Unix()
+	e.Send("deploy", 1, time.Time{})
+	if ts := e.queue[0].timestamp; ts < before || ts > time.Now().Unix() {
+		t.Fatal("zero time not sent as now:", ts)
+	}
+}
@@ -0,0 +1,113 @@
+package graphite
+
+import (
+	"fmt"
+)
+
+// SeriesNames returns the name of every series the exporter would emit for
+// the current content of c.Registry, in the order they would be sent. No
+// values are included which makes it suitable to pre-create Whisper files
+// or to review naming. Names follow the same policies as flushes: derived
+// series, unit suffixes, counter reset annotations, metrics of
+// c.HostRegistry when c.HostLock is set, as if this process held the lock,
+// and the exporter's own series. Reset annotations and derived series are
+// listed even though flushes only emit them when they apply.
+func SeriesNames(c *GraphiteConfig) []string {
+	names := make([]string, 0)
+	for _, s := range series(c) {
+		names = append(names, s.path)
+	}
+	return names
+}
+
+// namedSeries is a series the exporter emits.
+type namedSeries struct {
+	field string // name of the field it holds, before unit conversion
+	path  string
+}
+
+// series returns every series the exporter would emit for the current
+// content of the registries of c, in the order they would be sent.
+func series(c *GraphiteConfig) []namedSeries {
+	snaps := Snapshot(c)
+	if nil != c.HostRegistry && "" != c.HostLock {
+		snaps = snapshotRegistry(c, c.HostRegistry, snaps)
+	}
+	all := make([]namedSeries, 0)
+	counts := make(map[string]int)
+	add := func(s MetricSnapshot, name string, f Field) {
+		all = append(all, namedSeries{name, seriesName(c, s, f)})
+		counts[namespace(s.Name)]++
+	}
+	for _, s := range snaps {
+		for _, f := range s.Fields {
+			name := fieldName(s, f)
+			add(s, name, convert(c.Units, name, f))
+			if TypeCounter == s.Type && "" == f.Name && c.AnnotateCounterResets {
+				add(s, s.Name+".reset", intField("reset", 1))
+			}
+		}
+		if TypeCounter != s.Type {
+			continue
+		}
+		for _, rule := range c.PercentOfTotal {
+			if match(rule.Pattern, s.Name) {
+				suffix := rule.Suffix
+				if "" == suffix {
+					suffix = "percent"
+				}
+				add(s, s.Name+"."+suffix, floatField(suffix, 0))
+			}
+		}
+	}
+	root := prefix(c) + ".exporter."
+	self := make([]string, 0)
+	if c.ReportSeriesCounts {
+		for _, ns := range sortedKeys(counts) {
+			self = append(self, "series."+ns)
+		}
+	}
+	if c.ReportGaps {
+		self = append(self, "gap-seconds")
+	}
+	if c.ReportThroughput {
+		self = append(self, "lines.one-minute", "lines.five-minute", "bytes.one-minute", "bytes.five-minute")
+	}
+	if c.ReportConfig {
+		for _, v := range configValues(c, nil) {
+			self = append(self, v.name)
+		}
+	}
+	for _, name := range self {
+		all = append(all, namedSeries{"exporter." + name, tagged(c, root+name)})
+	}
+	return all
+}
+
+// seriesName returns the Graphite path of field f of metric s, followed by
+// the tags of s and c.Tags with c.Tagged.
+func seriesName(c *GraphiteConfig, s MetricSnapshot, f Field) string {
+	name, tags := s.Name, ""
+	if c.Tagged {
+		var t map[string]string
+		name, t = parseName(c, name)
+		tags = tagString(t)
+	}
+	name = fmt.Sprintf("%s.%s", prefix(c), name)
+	field := f.Name
+	if "" == field {
+		field = schema(c).values[s.Type]
+	}
+	if "" != field {
+		name += "." + field
+	}
+	return tagged(c, name+tags)
+}
+
+// prefix returns the prefix of every series, including the run identifier.
+func prefix(c *GraphiteConfig) string {
+	if "" == c.RunID {
+		return c.Prefix
+	}
+	return c.Prefix + "." + c.RunID
+}
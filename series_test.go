@@ -0,0 +1,81 @@
+package graphite
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSeriesNames(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("foo", r).Inc(2)
+	metrics.GetOrRegisterMeter("bar", r).Mark(1)
+
+	names := SeriesNames(&GraphiteConfig{
+		Registry:     r,
+		DurationUnit: time.Millisecond,
+		Prefix:       "prefix",
+	})
+	expected := []string{
+		"prefix.bar.count",
+		"prefix.bar.one-minute",
+		"prefix.bar.five-minute",
+		"prefix.bar.fifteen-minute",
+		"prefix.bar.mean",
+		"prefix.foo",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatal("bad names:", expected, names)
+	}
+}
+
+func TestSeriesNamesPolicies(t *testing.T) {
+	r, host := metrics.NewRegistry(), metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("responses.2xx", r).Inc(2)
+	metrics.GetOrRegisterGauge("heap", r).Update(1 << 20)
+	metrics.GetOrRegisterGauge("load", host).Update(1)
+
+	c := &GraphiteConfig{
+		Registry:              r,
+		HostRegistry:          host,
+		HostLock:              "series-names",
+		DurationUnit:          time.Millisecond,
+		Prefix:                "prefix",
+		Units:                 []UnitConversion{{Pattern: "heap", Factor: BytesToMegabytes, Suffix: "mb"}},
+		PercentOfTotal:        []PercentOfTotal{{Pattern: "responses.*"}},
+		AnnotateCounterResets: true,
+		ReportSeriesCounts:    true,
+		ReportGaps:            true,
+	}
+	names := SeriesNames(c)
+	expected := []string{
+		"prefix.heap.mb",
+		"prefix.load",
+		"prefix.responses.2xx",
+		"prefix.responses.2xx.reset",
+		"prefix.responses.2xx.percent",
+		"prefix.exporter.series.heap",
+		"prefix.exporter.series.load",
+		"prefix.exporter.series.responses",
+		"prefix.exporter.gap-seconds",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatal("bad names:", expected, names)
+	}
+
+	d := DiffSeries(&GraphiteConfig{Registry: r, DurationUnit: time.Millisecond, Prefix: "prefix"}, c)
+	added := make(map[string]bool)
+	for _, name := range d.Added {
+		added[name] = true
+	}
+	for _, rename := range d.Renamed {
+		added[rename.New] = true
+	}
+	for _, name := range names {
+		if !added[name] && "prefix.responses.2xx" != name {
+			t.Fatal("series missing from diff:", name, d)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package graphite
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// allShards selects every shard, as full flushes do.
+const allShards = -1
+
+// shards returns the number of shards regular flushes are spread over.
+func shards(c *GraphiteConfig) int {
+	if c.Shards < 1 {
+		return 1
+	}
+	return c.Shards
+}
+
+// tick returns the interval between regular flushes, each of them sending a
+// single shard when c.Shards is more than one.
+func tick(c *GraphiteConfig) time.Duration {
+	if d := c.FlushInterval / time.Duration(shards(c)); d > 0 {
+		return d
+	}
+	return c.FlushInterval
+}
+
+// shardOf returns the shard metric name belongs to out of n. Metrics are
+// hashed by name so that they stay in the same shard, and are thus sent
+// once every flush interval, as metrics come and go.
+func shardOf(name string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(n))
+}
+
+// shard returns the snapshots of snaps in shard, or every one of them for
+// allShards.
+func (e *Exporter) shard(snaps []MetricSnapshot, shard int) []MetricSnapshot {
+	n := shards(&e.c)
+	if allShards == shard || 1 == n {
+		return snaps
+	}
+	return filter(snaps, func(name string) bool { return shard == shardOf(name, n) })
+}
+
+// countSeries returns the number of series of snaps for every namespace.
+// The series of a shard are added to those last counted for the others.
+func (e *Exporter) countSeries(snaps []MetricSnapshot, shard int) map[string]int {
+	series := make(map[string]int)
+	for _, s := range snaps {
+		series[namespace(s.Name)] += len(s.Fields)
+	}
+	n := shards(&e.c)
+	if allShards == shard || 1 == n {
+		return series
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if n != len(e.perShard) {
+		e.perShard = make([]map[string]int, n)
+	}
+	e.perShard[shard] = series
+	total := make(map[string]int)
+	for _, counts := range e.perShard {
+		for ns, count := range counts {
+			total[ns] += count
+		}
+	}
+	return total
+}
+
+// flushShard sends shard as a regular flush, along with the exporter's own
+// series for the first shard.
+func (e *Exporter) flushShard(shard int) FlushResult {
+	return e.flushSelected(e.ctx, selection{shard: shard})
+}
@@ -0,0 +1,69 @@
+package graphite
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestShards(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	for i := 0; i < 20; i++ {
+		metrics.GetOrRegisterGauge(fmt.Sprintf("g.%d", i), r).Update(1)
+	}
+	c.Shards = 4
+	c.ReportSeriesCounts = true
+	if expected, found := c.FlushInterval/4, tick(&c); expected != found {
+		t.Fatal("bad tick:", expected, found)
+	}
+
+	e := New(c)
+	sent := func() (n int) {
+		for i := 0; i < 20; i++ {
+			n += int(res[fmt.Sprintf("foobar.g.%d", i)])
+		}
+		return n
+	}
+	for _, shard := range []int{1, 2, 3} {
+		wg.Add(1)
+		if err := e.flushShard(shard).Err; nil != err {
+			t.Fatal(err)
+		}
+		wg.Wait()
+	}
+	if _, ok := res["foobar.exporter.series.g"]; ok {
+		t.Fatal("exporter series sent by another shard than the first")
+	}
+	if n := sent(); 0 == n || 20 == n {
+		t.Fatal("shards not partitioned:", n)
+	}
+	wg.Add(1)
+	if err := e.flushShard(0).Err; nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	for i := 0; i < 20; i++ {
+		if found := res[fmt.Sprintf("foobar.g.%d", i)]; !floatEquals(found, 1) {
+			t.Fatal("gauge not sent once by the shards:", i, found)
+		}
+	}
+	if expected, found := 20.0, res["foobar.exporter.series.g"]; !floatEquals(found, expected) {
+		t.Fatal("bad series count:", expected, found)
+	}
+}
+
+func TestShardsStable(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("m.%d", i)
+		if shard := shardOf(name, 7); shard < 0 || shard >= 7 || shard != shardOf(name, 7) {
+			t.Fatal("bad shard:", name, shard)
+		}
+	}
+	c := GraphiteConfig{FlushInterval: time.Second}
+	if expected, found := time.Second, tick(&c); expected != found {
+		t.Fatal("bad tick without shards:", expected, found)
+	}
+}
@@ -0,0 +1,158 @@
+// Package cloudwatchsink sends the datapoints of a graphite.Exporter to
+// Amazon CloudWatch as custom metrics. It is a module of its own so that
+// the exporter does not depend on the AWS SDK:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	// ...
+//	e := graphite.New(graphite.GraphiteConfig{
+//		Addr:     "cloudwatch",
+//		DialFunc: cloudwatchsink.DialFunc(cloudwatch.NewFromConfig(cfg), "MyService"),
+//		// ...
+//	})
+package cloudwatchsink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// maxDatums is the number of datums sent by a single PutMetricData request.
+const maxDatums = 20
+
+// PutMetricDataAPI is the part of *cloudwatch.Client the connections use.
+type PutMetricDataAPI interface {
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// DialFunc returns a dial function for graphite.GraphiteConfig.DialFunc
+// whose connections send every plaintext line written to them as a datum
+// of namespace: the series is the name of the metric and the tags of a
+// tagged series its dimensions. The address dialed is ignored.
+func DialFunc(client PutMetricDataAPI, namespace string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(context.Context, string, string) (net.Conn, error) {
+		return &conn{client: client, namespace: namespace}, nil
+	}
+}
+
+// datum returns the datum of line, a line of the plaintext protocol.
+func datum(line string) (types.MetricDatum, error) {
+	fields := strings.Fields(line)
+	if 3 != len(fields) {
+		return types.MetricDatum{}, fmt.Errorf("cloudwatchsink: malformed line %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if nil != err {
+		return types.MetricDatum{}, fmt.Errorf("cloudwatchsink: malformed value in %q", line)
+	}
+	ts, err := strconv.ParseInt(fields[2], 10, 64)
+	if nil != err {
+		return types.MetricDatum{}, fmt.Errorf("cloudwatchsink: malformed timestamp in %q", line)
+	}
+	nodes := strings.Split(fields[0], ";")
+	d := types.MetricDatum{
+		MetricName: aws.String(nodes[0]),
+		Value:      aws.Float64(value),
+		Timestamp:  aws.Time(time.Unix(ts, 0)),
+	}
+	tags := nodes[1:]
+	sort.Strings(tags)
+	for _, tag := range tags {
+		name, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			return types.MetricDatum{}, fmt.Errorf("cloudwatchsink: malformed tag %q in %q", tag, line)
+		}
+		d.Dimensions = append(d.Dimensions, types.Dimension{Name: aws.String(name), Value: aws.String(value)})
+	}
+	return d, nil
+}
+
+// conn is a connection sending the lines written to it as datums.
+type conn struct {
+	client    PutMetricDataAPI
+	namespace string
+
+	mu       sync.Mutex // protects deadline
+	deadline time.Time  // deadline of the writes, none if zero
+}
+
+// Write sends the lines of b, maxDatums at a time. It returns the bytes of
+// the lines sent before a malformed line or a failed request.
+func (c *conn) Write(b []byte) (int, error) {
+	ctx := context.Background()
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	var sent, n int
+	var datums []types.MetricDatum
+	send := func() error {
+		if 0 == len(datums) {
+			return nil
+		}
+		_, err := c.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(c.namespace),
+			MetricData: datums,
+		})
+		if nil == err {
+			sent, datums = n, nil
+		}
+		return err
+	}
+	for n < len(b) {
+		line, _, _ := bytes.Cut(b[n:], []byte("\n"))
+		if 0 != len(bytes.TrimSpace(line)) {
+			d, err := datum(string(line))
+			if nil != err {
+				if serr := send(); nil != serr {
+					return sent, serr
+				}
+				return sent, err
+			}
+			datums = append(datums, d)
+		}
+		n = min(n+len(line)+1, len(b))
+		if maxDatums == len(datums) {
+			if err := send(); nil != err {
+				return sent, err
+			}
+		}
+	}
+	if err := send(); nil != err {
+		return sent, err
+	}
+	return len(b), nil
+}
+
+// Read fails since CloudWatch is not read from.
+func (c *conn) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (c *conn) Close() error                    { return nil }
+func (c *conn) LocalAddr() net.Addr             { return nil }
+func (c *conn) RemoteAddr() net.Addr            { return nil }
+func (c *conn) SetDeadline(t time.Time) error   { return c.SetWriteDeadline(t) }
+func (c *conn) SetReadDeadline(time.Time) error { return nil }
+
+// SetWriteDeadline sets the deadline of the next writes, such as that of
+// graphite.GraphiteConfig.WriteTimeout.
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
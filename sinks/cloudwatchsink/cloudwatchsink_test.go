@@ -0,0 +1,60 @@
+package cloudwatchsink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+type fakeCloudWatch struct {
+	requests [][]types.MetricDatum
+}
+
+func (f *fakeCloudWatch) PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	f.requests = append(f.requests, params.MetricData)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestDatum(t *testing.T) {
+	d, err := datum("requests;method=GET;dc=ams 2.5 1000")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "requests" != aws.ToString(d.MetricName) || 2.5 != aws.ToFloat64(d.Value) || 1000 != d.Timestamp.Unix() {
+		t.Fatal("bad datum:", d)
+	}
+	if 2 != len(d.Dimensions) || "dc" != aws.ToString(d.Dimensions[0].Name) || "GET" != aws.ToString(d.Dimensions[1].Value) {
+		t.Fatal("bad dimensions:", d.Dimensions)
+	}
+	for _, line := range []string{"foo 1", "foo x 1", "foo 1 x", "foo;bar 1 1"} {
+		if _, err := datum(line); nil == err {
+			t.Error("malformed line accepted:", line)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	client := &fakeCloudWatch{}
+	conn, _ := DialFunc(client, "MyService")(context.Background(), "tcp", "cloudwatch")
+	var b strings.Builder
+	for i := 0; i < maxDatums+1; i++ {
+		fmt.Fprintf(&b, "foo %d 1000\n", i)
+	}
+	if n, err := conn.Write([]byte(b.String())); nil != err || b.Len() != n {
+		t.Fatal("write failed:", n, err)
+	}
+	if 2 != len(client.requests) || maxDatums != len(client.requests[0]) || 1 != len(client.requests[1]) {
+		t.Fatal("bad requests:", len(client.requests))
+	}
+
+	client.requests = nil
+	n, err := conn.Write([]byte("foo 1 1000\nbroken\nbar 2 1000\n"))
+	if nil == err || len("foo 1 1000\n") != n || 1 != len(client.requests) {
+		t.Fatal("malformed line not reported:", n, err, client.requests)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/cyberdelia/go-metrics-graphite/sinks/cloudwatchsink
+
+go 1.21
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.24.0
+	github.com/aws/aws-sdk-go-v2/service/cloudwatch v1.32.0
+)
@@ -0,0 +1,5 @@
+module github.com/cyberdelia/go-metrics-graphite/sinks/kafkasink
+
+go 1.21
+
+require github.com/segmentio/kafka-go v0.4.47
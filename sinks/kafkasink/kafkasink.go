@@ -0,0 +1,89 @@
+// Package kafkasink publishes the datapoints of a graphite.Exporter to a
+// Kafka topic. It is a module of its own so that the exporter does not
+// depend on a Kafka client:
+//
+//	e := graphite.New(graphite.GraphiteConfig{
+//		Addr:     "kafka",
+//		DialFunc: kafkasink.DialFunc(&kafka.Writer{Addr: kafka.TCP("broker:9092"), Topic: "metrics"}),
+//		// ...
+//	})
+package kafkasink
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DialFunc returns a dial function for graphite.GraphiteConfig.DialFunc
+// whose connections publish every plaintext line written to them as a
+// message of w, keyed by series so that the datapoints of a series stay
+// ordered within a partition. The address dialed is ignored and w is not
+// closed with the connections.
+func DialFunc(w *kafka.Writer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(context.Context, string, string) (net.Conn, error) {
+		return &conn{w: w}, nil
+	}
+}
+
+// messages returns the messages of the lines of b, skipping blank ones.
+func messages(b []byte) []kafka.Message {
+	msgs := make([]kafka.Message, 0, bytes.Count(b, []byte("\n"))+1)
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if 0 == len(line) {
+			continue
+		}
+		series, _, _ := bytes.Cut(line, []byte(" "))
+		msgs = append(msgs, kafka.Message{Key: series, Value: line})
+	}
+	return msgs
+}
+
+// conn is a connection publishing the lines written to it to a topic.
+type conn struct {
+	w *kafka.Writer
+
+	mu       sync.Mutex // protects deadline
+	deadline time.Time  // deadline of the writes, none if zero
+}
+
+// Write publishes the lines of b, all of them or none.
+func (c *conn) Write(b []byte) (int, error) {
+	ctx := context.Background()
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	if err := c.w.WriteMessages(ctx, messages(b)...); nil != err {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Read fails since topics are not read from.
+func (c *conn) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (c *conn) Close() error                    { return nil }
+func (c *conn) LocalAddr() net.Addr             { return nil }
+func (c *conn) RemoteAddr() net.Addr            { return nil }
+func (c *conn) SetDeadline(t time.Time) error   { return c.SetWriteDeadline(t) }
+func (c *conn) SetReadDeadline(time.Time) error { return nil }
+
+// SetWriteDeadline sets the deadline of the next writes, such as that of
+// graphite.GraphiteConfig.WriteTimeout.
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
@@ -0,0 +1,18 @@
+package kafkasink
+
+import (
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	msgs := messages([]byte("foo.bar 1 1000\n\nbaz;dc=ams 2 1000\n"))
+	if 2 != len(msgs) {
+		t.Fatal("bad messages:", msgs)
+	}
+	if "foo.bar" != string(msgs[0].Key) || "foo.bar 1 1000" != string(msgs[0].Value) {
+		t.Fatal("bad message:", msgs[0])
+	}
+	if "baz;dc=ams" != string(msgs[1].Key) {
+		t.Fatal("tagged series not used as key:", msgs[1])
+	}
+}
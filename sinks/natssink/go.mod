@@ -0,0 +1,5 @@
+module github.com/cyberdelia/go-metrics-graphite/sinks/natssink
+
+go 1.21
+
+require github.com/nats-io/nats.go v1.31.0
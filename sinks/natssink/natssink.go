@@ -0,0 +1,95 @@
+// Package natssink publishes the datapoints of a graphite.Exporter to a
+// NATS subject. It is a module of its own so that the exporter does not
+// depend on a NATS client:
+//
+//	nc, err := nats.Connect(nats.DefaultURL)
+//	// ...
+//	e := graphite.New(graphite.GraphiteConfig{
+//		Addr:     "nats",
+//		DialFunc: natssink.DialFunc(nc, "metrics"),
+//		// ...
+//	})
+package natssink
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DialFunc returns a dial function for graphite.GraphiteConfig.DialFunc
+// whose connections publish every plaintext line written to them as a
+// message on subject, flushed to the server before the write returns. The
+// address dialed is ignored and nc is not closed with the connections.
+func DialFunc(nc *nats.Conn, subject string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(context.Context, string, string) (net.Conn, error) {
+		return &conn{nc: nc, subject: subject}, nil
+	}
+}
+
+// lines returns the lines of b, skipping blank ones.
+func lines(b []byte) [][]byte {
+	all := make([][]byte, 0, bytes.Count(b, []byte("\n"))+1)
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if line = bytes.TrimSpace(line); 0 != len(line) {
+			all = append(all, line)
+		}
+	}
+	return all
+}
+
+// conn is a connection publishing the lines written to it on a subject.
+type conn struct {
+	nc      *nats.Conn
+	subject string
+
+	mu       sync.Mutex // protects deadline
+	deadline time.Time  // deadline of the writes, none if zero
+}
+
+// Write publishes the lines of b and waits for the server to receive them.
+// Lines published before a failure may have been delivered, the write
+// reporting that none was.
+func (c *conn) Write(b []byte) (int, error) {
+	for _, line := range lines(b) {
+		if err := c.nc.Publish(c.subject, line); nil != err {
+			return 0, err
+		}
+	}
+	ctx := context.Background()
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	if err := c.nc.FlushWithContext(ctx); nil != err {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Read fails since subjects are not read from.
+func (c *conn) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (c *conn) Close() error                    { return nil }
+func (c *conn) LocalAddr() net.Addr             { return nil }
+func (c *conn) RemoteAddr() net.Addr            { return nil }
+func (c *conn) SetDeadline(t time.Time) error   { return c.SetWriteDeadline(t) }
+func (c *conn) SetReadDeadline(time.Time) error { return nil }
+
+// SetWriteDeadline sets the deadline of the next writes, such as that of
+// graphite.GraphiteConfig.WriteTimeout.
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
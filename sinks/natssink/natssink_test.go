@@ -0,0 +1,12 @@
+package natssink
+
+import (
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	found := lines([]byte("foo 1 1000\n \nbar 2 1000\n"))
+	if 2 != len(found) || "foo 1 1000" != string(found[0]) || "bar 2 1000" != string(found[1]) {
+		t.Fatal("bad lines:", found)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/cyberdelia/go-metrics-graphite/sinks/s3sink
+
+go 1.21
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.24.0
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.47.5
+)
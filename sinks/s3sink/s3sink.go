@@ -0,0 +1,102 @@
+// Package s3sink archives the datapoints of a graphite.Exporter as objects
+// of an S3 bucket, for instance to replay them into another Graphite
+// cluster. It is a module of its own so that the exporter does not depend
+// on the AWS SDK:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	// ...
+//	e := graphite.New(graphite.GraphiteConfig{
+//		Addr:     "s3",
+//		DialFunc: s3sink.DialFunc(s3.NewFromConfig(cfg), "metrics", "graphite/"),
+//		// ...
+//	})
+package s3sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PutObjectAPI is the part of *s3.Client the connections use.
+type PutObjectAPI interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// DialFunc returns a dial function for graphite.GraphiteConfig.DialFunc
+// whose connections store every write, the plaintext lines of part of a
+// flush, as an object of bucket. Objects are named after prefix, the time
+// of the write and a sequence number, such as
+// "graphite/2024/01/02/150405-1.txt", so that they list in the order they
+// were written. The address dialed is ignored.
+func DialFunc(client PutObjectAPI, bucket, prefix string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	seq := new(int64)
+	return func(context.Context, string, string) (net.Conn, error) {
+		return &conn{client: client, bucket: bucket, prefix: prefix, seq: seq}, nil
+	}
+}
+
+// key returns the name of the object of the write numbered seq at t.
+func key(prefix string, t time.Time, seq int64) string {
+	return fmt.Sprintf("%s%s-%d.txt", prefix, t.UTC().Format("2006/01/02/150405"), seq)
+}
+
+// conn is a connection storing the writes it receives as objects.
+type conn struct {
+	client PutObjectAPI
+	bucket string
+	prefix string
+	seq    *int64 // number of the last object of the dial function
+
+	mu       sync.Mutex // protects deadline
+	deadline time.Time  // deadline of the writes, none if zero
+}
+
+// Write stores b as a new object.
+func (c *conn) Write(b []byte) (int, error) {
+	ctx := context.Background()
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(c.bucket),
+		Key:         aws.String(key(c.prefix, time.Now(), atomic.AddInt64(c.seq, 1))),
+		Body:        bytes.NewReader(b),
+		ContentType: aws.String("text/plain"),
+	})
+	if nil != err {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Read fails since objects are not read from.
+func (c *conn) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (c *conn) Close() error                    { return nil }
+func (c *conn) LocalAddr() net.Addr             { return nil }
+func (c *conn) RemoteAddr() net.Addr            { return nil }
+func (c *conn) SetDeadline(t time.Time) error   { return c.SetWriteDeadline(t) }
+func (c *conn) SetReadDeadline(time.Time) error { return nil }
+
+// SetWriteDeadline sets the deadline of the next writes, such as that of
+// graphite.GraphiteConfig.WriteTimeout.
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
@@ -0,0 +1,41 @@
+package s3sink
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+type fakeS3 map[string]string
+
+func (f fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	f[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = string(body)
+	return &s3.PutObjectOutput{}, err
+}
+
+func TestDialFunc(t *testing.T) {
+	objects := fakeS3{}
+	conn, err := DialFunc(objects, "metrics", "graphite/")(context.Background(), "tcp", "s3")
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, payload := range []string{"foo 1 1000\n", "foo 2 1010\n"} {
+		if n, err := conn.Write([]byte(payload)); nil != err || len(payload) != n {
+			t.Fatal("write failed:", n, err)
+		}
+	}
+	if 2 != len(objects) {
+		t.Fatal("bad objects:", objects)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if found := key("graphite/", time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), 3); "graphite/2024/01/02/150405-3.txt" != found {
+		t.Fatal("bad key:", found)
+	}
+}
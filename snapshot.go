@@ -0,0 +1,231 @@
+package graphite
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// MetricSnapshot is a point-in-time copy of a single registry metric along
+// with the fields the exporter derives from it.
+type MetricSnapshot struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Fields []Field           `json:"fields"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// Field is a single value of a metric, such as the max of a timer. Its name
+// is appended to the metric name to build the Graphite path; single valued
+// metrics such as counters and gauges use an empty name.
+type Field struct {
+	Name  string  `json:"name,omitempty"`
+	Value float64 `json:"value"`
+
+	// Precision is the number of decimals written by the plaintext encoder.
+	Precision int `json:"-"`
+}
+
+// Metric types reported in MetricSnapshot.Type.
+const (
+	TypeCounter      = "counter"
+	TypeGauge        = "gauge"
+	TypeGaugeFloat64 = "gauge_float64"
+	TypeHistogram    = "histogram"
+	TypeMeter        = "meter"
+	TypeTimer        = "timer"
+)
+
+// Snapshot returns a snapshot of every metric in c.Registry, sorted by name.
+// Metrics of unknown types are skipped.
+func Snapshot(c *GraphiteConfig) []MetricSnapshot {
+	return snapshotRegistry(c, c.Registry, make([]MetricSnapshot, 0))
+}
+
+// snapshotRegistry appends the snapshot of every metric in r to snaps and
+// sorts the result by name.
+func snapshotRegistry(c *GraphiteConfig, r metrics.Registry, snaps []MetricSnapshot) []MetricSnapshot {
+	r.Each(func(name string, i interface{}) {
+		var s MetricSnapshot
+		var ok bool
+		if err := isolate(c, name, func() { s, ok = snapshot(c, name, i) }); nil == err && ok {
+			snaps = append(snaps, s)
+		}
+	})
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Name < snaps[j].Name })
+	return snaps
+}
+
+// SnapshotOf returns the snapshot of a single metric which does not need to
+// be part of a registry. It returns false for metrics of unknown types.
+func SnapshotOf(c *GraphiteConfig, name string, i interface{}) (MetricSnapshot, bool) {
+	return snapshot(c, name, i)
+}
+
+// snapshot returns the snapshot of metric i called name, holding the tags
+// of name with c.Tagged.
+func snapshot(c *GraphiteConfig, name string, i interface{}) (MetricSnapshot, bool) {
+	s, ok := snapshotMetric(c, name, i)
+	if c.Tagged {
+		_, s.Tags = parseName(c, name)
+	}
+	return s, ok
+}
+
+func snapshotMetric(c *GraphiteConfig, name string, i interface{}) (MetricSnapshot, bool) {
+	switch metric := i.(type) {
+	case metrics.Counter:
+		return applySchema(c, NewCounterSnapshot(name, metric.Count())), true
+	case metrics.Gauge:
+		return applySchema(c, NewGaugeSnapshot(name, metric.Value())), true
+	case metrics.GaugeFloat64:
+		return applySchema(c, NewGaugeFloat64Snapshot(name, metric.Value())), true
+	case metrics.Histogram:
+		return histogramSnapshot(c, name, metric.Snapshot()), true
+	case metrics.Meter:
+		m := metric.Snapshot()
+		return applySchema(c, NewMeterSnapshot(name, m.Count(), Rates{m.Rate1(), m.Rate5(), m.Rate15(), m.RateMean()})), true
+	case metrics.Timer:
+		t := metric.Snapshot()
+		return timerSnapshot(c, name, t, Rates{t.Rate1(), t.Rate5(), t.Rate15(), t.RateMean()}), true
+	}
+	return MetricSnapshot{Name: name}, false
+}
+
+// Rates holds the rates of a meter or a timer, in events per second.
+type Rates struct {
+	Rate1  float64 // One-minute moving average rate
+	Rate5  float64 // Five-minute moving average rate
+	Rate15 float64 // Fifteen-minute moving average rate
+	Mean   float64 // Mean rate
+}
+
+// NewCounterSnapshot returns the snapshot of a counter holding count.
+func NewCounterSnapshot(name string, count int64) MetricSnapshot {
+	return MetricSnapshot{Name: name, Type: TypeCounter, Fields: []Field{intField("", count)}}
+}
+
+// NewGaugeSnapshot returns the snapshot of a gauge holding value.
+func NewGaugeSnapshot(name string, value int64) MetricSnapshot {
+	return MetricSnapshot{Name: name, Type: TypeGauge, Fields: []Field{intField("", value)}}
+}
+
+// NewGaugeFloat64Snapshot returns the snapshot of a float64 gauge holding
+// value.
+func NewGaugeFloat64Snapshot(name string, value float64) MetricSnapshot {
+	return MetricSnapshot{Name: name, Type: TypeGaugeFloat64, Fields: []Field{{Value: value, Precision: 6}}}
+}
+
+// NewHistogramSnapshot returns the snapshot of a histogram which recorded
+// values.
+func NewHistogramSnapshot(c *GraphiteConfig, name string, values []int64) MetricSnapshot {
+	return histogramSnapshot(c, name, sampleOf(values))
+}
+
+// NewMeterSnapshot returns the snapshot of a meter which was marked count
+// times at rates r.
+func NewMeterSnapshot(name string, count int64, r Rates) MetricSnapshot {
+	return MetricSnapshot{Name: name, Type: TypeMeter, Fields: []Field{
+		intField("count", count),
+		floatField("one-minute", r.Rate1),
+		floatField("five-minute", r.Rate5),
+		floatField("fifteen-minute", r.Rate15),
+		floatField("mean", r.Mean),
+	}}
+}
+
+// NewTimerSnapshot returns the snapshot of a timer which recorded durations
+// at rates r.
+func NewTimerSnapshot(c *GraphiteConfig, name string, durations []time.Duration, r Rates) MetricSnapshot {
+	values := make([]int64, len(durations))
+	for i, d := range durations {
+		values[i] = int64(d)
+	}
+	return timerSnapshot(c, name, sampleOf(values), r)
+}
+
+// distribution is implemented by both histograms and timers.
+type distribution interface {
+	Count() int64
+	Min() int64
+	Max() int64
+	Mean() float64
+	StdDev() float64
+	Percentiles([]float64) []float64
+}
+
+// sampleOf returns a histogram holding exactly values.
+func sampleOf(values []int64) metrics.Histogram {
+	size := len(values)
+	if 0 == size {
+		size = 1
+	}
+	h := metrics.NewHistogram(metrics.NewUniformSample(size))
+	for _, v := range values {
+		h.Update(v)
+	}
+	return h.Snapshot()
+}
+
+func histogramSnapshot(c *GraphiteConfig, name string, h distribution) MetricSnapshot {
+	ps := quantiles(c, h)
+	s := MetricSnapshot{Name: name, Type: TypeHistogram, Fields: []Field{
+		intField("count", h.Count()),
+		intField("min", h.Min()),
+		intField("max", h.Max()),
+		floatField("mean", h.Mean()),
+		floatField("std-dev", h.StdDev()),
+	}}
+	for psIdx, psKey := range c.Percentiles {
+		s.Fields = append(s.Fields, floatField(percentileKey(psKey)+schema(c).histogramPercentile, ps[psIdx]))
+	}
+	return applySchema(c, s)
+}
+
+func timerSnapshot(c *GraphiteConfig, name string, t distribution, r Rates) MetricSnapshot {
+	du := float64(c.DurationUnit)
+	if du <= 0 {
+		du = float64(time.Nanosecond)
+	}
+	ps := quantiles(c, t)
+	prec := c.DurationPrecision
+	if 0 == prec {
+		prec = 2
+	}
+	duration := func(name string, v float64) Field {
+		return Field{Name: name, Value: v / du, Precision: prec}
+	}
+	s := MetricSnapshot{Name: name, Type: TypeTimer, Fields: []Field{
+		intField("count", t.Count()),
+		duration("min", float64(t.Min())),
+		duration("max", float64(t.Max())),
+		duration("mean", t.Mean()),
+		duration("std-dev", t.StdDev()),
+	}}
+	for psIdx, psKey := range c.Percentiles {
+		s.Fields = append(s.Fields, duration(percentileKey(psKey)+"-percentile", ps[psIdx]))
+	}
+	s.Fields = append(s.Fields,
+		floatField("one-minute", r.Rate1),
+		floatField("five-minute", r.Rate5),
+		floatField("fifteen-minute", r.Rate15),
+		floatField("mean-rate", r.Mean),
+	)
+	return applySchema(c, s)
+}
+
+func intField(name string, v int64) Field {
+	return Field{Name: name, Value: float64(v)}
+}
+
+func floatField(name string, v float64) Field {
+	return Field{Name: name, Value: v, Precision: 2}
+}
+
+// percentileKey formats a percentile such as 0.999 as "999".
+func percentileKey(p float64) string {
+	return strings.Replace(strconv.FormatFloat(p*100.0, 'f', -1, 64), ".", "", 1)
+}
@@ -0,0 +1,47 @@
+package graphite
+
+import (
+	"context"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SRVLookup returns the targets of the DNS SRV record called name, sorted
+// by priority and randomized by weight like net.Resolver.LookupSRV.
+type SRVLookup func(ctx context.Context, name string) ([]*net.SRV, error)
+
+// discover returns the endpoints of the targets of c.SRV, in order of
+// preference. When the lookup fails, the endpoints last discovered are
+// used until a lookup succeeds again.
+func (e *Exporter) discover(ctx context.Context) ([]string, error) {
+	lookup := e.c.LookupSRV
+	if nil == lookup {
+		lookup = func(ctx context.Context, name string) ([]*net.SRV, error) {
+			_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+			return srvs, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout(&e.c))
+	defer cancel()
+	srvs, err := lookup(ctx, e.c.SRV)
+	if nil == err && 0 == len(srvs) {
+		err = &net.DNSError{Err: "no SRV targets", Name: e.c.SRV, IsNotFound: true}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if nil != err {
+		if 0 == len(e.srv) {
+			return nil, err
+		}
+		log.Println("graphite: using stale SRV targets:", err)
+		return e.srv, nil
+	}
+	eps := make([]string, len(srvs))
+	for i, srv := range srvs {
+		eps[i] = net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+	}
+	e.srv = eps
+	return eps, nil
+}
@@ -0,0 +1,57 @@
+package graphite
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSRV(t *testing.T) {
+	var dialed []string
+	var fail error
+	targets := []*net.SRV{{Target: "down.metrics.internal.", Port: 2003}, {Target: "up.metrics.internal.", Port: 2004}}
+	e := New(GraphiteConfig{
+		FlushInterval: time.Second,
+		SRV:           "_carbon._tcp.metrics.internal",
+		LookupSRV: func(_ context.Context, name string) ([]*net.SRV, error) {
+			if "_carbon._tcp.metrics.internal" != name {
+				t.Error("bad SRV name:", name)
+			}
+			return targets, fail
+		},
+		DialFunc: func(_ context.Context, _, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			if "down.metrics.internal:2003" == addr {
+				return nil, errors.New("unreachable")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	})
+	conn, addr, err := e.connect(context.Background())
+	if nil != err {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if "up.metrics.internal:2004" != addr || 2 != len(dialed) || "up.metrics.internal:2004" != e.Endpoint() {
+		t.Fatal("SRV targets not dialed in order:", addr, dialed, e.Endpoint())
+	}
+
+	// Targets are discovered again for every connection, the last ones
+	// being used when the lookup fails.
+	targets = []*net.SRV{{Target: "new.metrics.internal.", Port: 2003}}
+	if _, addr, _ := e.connect(context.Background()); "new.metrics.internal:2003" != addr {
+		t.Fatal("SRV targets not discovered again:", addr)
+	}
+	fail = errors.New("SERVFAIL")
+	if _, addr, _ := e.connect(context.Background()); "new.metrics.internal:2003" != addr {
+		t.Fatal("stale SRV targets not used:", addr)
+	}
+	e.srv = nil
+	if _, _, err := e.connect(context.Background()); fail != err {
+		t.Fatal("lookup error not reported:", err)
+	}
+}
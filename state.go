@@ -0,0 +1,91 @@
+package graphite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// DumpState writes a human-readable description of the internal state of e
+// to w, to help debugging why series are missing or wrong. The state is
+// formatted before writing to w so that a slow writer does not hold up
+// flushes.
+func (e *Exporter) DumpState(w io.Writer) error {
+	var buf bytes.Buffer
+	e.dumpState(&buf)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// dumpState formats the state of e to buf.
+func (e *Exporter) dumpState(buf *bytes.Buffer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	tw := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "config\n")
+	fmt.Fprintf(tw, "  addr:\t%s\n", e.c.Addr)
+	fmt.Fprintf(tw, "  prefix:\t%q\n", e.c.Prefix)
+	fmt.Fprintf(tw, "  flush interval:\t%s\n", e.c.FlushInterval)
+	fmt.Fprintf(tw, "  duration unit:\t%s\n", e.c.DurationUnit)
+	fmt.Fprintf(tw, "  percentiles:\t%v\n", e.c.Percentiles)
+	transforms := make([]string, len(e.c.Transforms))
+	for i, t := range e.c.Transforms {
+		transforms[i] = t.Pattern
+	}
+	fmt.Fprintf(tw, "  transforms:\t%v\n", transforms)
+
+	fmt.Fprintf(tw, "last flush\n")
+	if nil == e.last {
+		fmt.Fprintf(tw, "  none\n")
+	} else {
+		fmt.Fprintf(tw, "  time:\t%s\n", e.last.Time.Format(time.RFC3339))
+		fmt.Fprintf(tw, "  duration:\t%s\n", e.last.Duration)
+		fmt.Fprintf(tw, "  lines:\t%d\n", e.last.Lines)
+		fmt.Fprintf(tw, "  bytes:\t%d\n", e.last.Bytes)
+		fmt.Fprintf(tw, "  error:\t%v\n", e.last.Err)
+	}
+
+	fmt.Fprintf(tw, "destinations\n")
+	for _, addr := range sortedKeys(e.dests) {
+		d := e.dests[addr]
+		fmt.Fprintf(tw, "  %s:\tflushes=%d failures=%d mean latency=%s last error=%v\n", addr, d.Flushes, d.Failures, d.MeanLatency(), d.LastError)
+	}
+
+	fmt.Fprintf(tw, "error budget\n")
+	fmt.Fprintf(tw, "  degraded:\t%t\n", e.budget.degraded)
+	fmt.Fprintf(tw, "  recent failures:\t%d\n", len(e.budget.failures))
+
+	if "" != e.c.HostLock {
+		fmt.Fprintf(tw, "host lock %q held:\t%t\n", e.c.HostLock, nil != e.hostLock)
+	}
+	fmt.Fprintf(tw, "queued datapoints:\t%d\n", len(e.queue))
+	fmt.Fprintf(tw, "relayed datapoints:\t%d\n", len(e.relayed))
+	m := e.memory()
+	fmt.Fprintf(tw, "memory:\tqueued=%dB payloads=%dB state=%dB dropped=%d\n", m.Queued, m.Payloads, m.State, m.Dropped)
+	fmt.Fprintf(tw, "dropped negative counters:\t%d\n", e.negatives)
+
+	fmt.Fprintf(tw, "counter baselines\n")
+	for _, name := range sortedKeys(e.baselines) {
+		fmt.Fprintf(tw, "  %s:\t%g\n", name, e.baselines[name])
+	}
+
+	fmt.Fprintf(tw, "outlier baselines\n")
+	for _, name := range sortedKeys(e.outliers) {
+		stats := e.outliers[name]
+		fmt.Fprintf(tw, "  %s:\tn=%d mean=%g stddev=%g\n", name, stats.n, stats.mean, stats.stdDev())
+	}
+	tw.Flush()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
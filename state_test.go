@@ -0,0 +1,54 @@
+package graphite
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpState(t *testing.T) {
+	e := New(GraphiteConfig{Addr: "127.0.0.1:2003", Prefix: "foobar", FlushInterval: time.Second})
+
+	var buf bytes.Buffer
+	if err := e.DumpState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"addr:", "127.0.0.1:2003", `"foobar"`, "last flush\n  none\n", "degraded:"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("missing %q in:\n%s", expected, buf.String())
+		}
+	}
+
+	e.record(FlushResult{Time: time.Now(), Lines: 3, Bytes: 42})
+	buf.Reset()
+	e.DumpState(&buf)
+	if !strings.Contains(buf.String(), "bytes:") || !strings.Contains(buf.String(), "42") {
+		t.Fatalf("missing last flush in:\n%s", buf.String())
+	}
+}
+
+// blockingWriter blocks writes until unblock is closed.
+type blockingWriter struct {
+	unblock chan struct{}
+}
+
+func (w blockingWriter) Write(b []byte) (int, error) {
+	<-w.unblock
+	return len(b), nil
+}
+
+func TestDumpStateSlowWriter(t *testing.T) {
+	e := New(GraphiteConfig{Prefix: "foobar"})
+	w := blockingWriter{unblock: make(chan struct{})}
+	done := make(chan error)
+	go func() { done <- e.DumpState(w) }()
+
+	// The exporter is not locked while the state is written.
+	time.Sleep(10 * time.Millisecond)
+	e.Send("deploy", 1, time.Now())
+	close(w.unblock)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
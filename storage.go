@@ -0,0 +1,91 @@
+package graphite
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// StorageSchemas returns a suggested storage-schemas.conf stanza for the
+// series under the prefix of c, whose finest archive matches
+// c.FlushInterval and keeps a week of data, followed by coarser archives
+// keeping a month and two years.
+func StorageSchemas(c *GraphiteConfig) string {
+	step := c.FlushInterval / time.Second
+	if step < 1 {
+		step = 1
+	}
+	mid := coarser(step, 60)
+	long := coarser(mid, 3600)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "[%s]\n", stanza(prefix(c)))
+	fmt.Fprintf(&b, "pattern = ^%s\\.\n", regexp.QuoteMeta(prefix(c)))
+	fmt.Fprintf(&b, "retentions = %s:7d,%s:30d,%s:2y\n", retention(step), retention(mid), retention(long))
+	return b.String()
+}
+
+// coarser returns the smallest multiple of step of at least min seconds,
+// or ten times step if it is already that coarse.
+func coarser(step, min time.Duration) time.Duration {
+	if step >= min {
+		return 10 * step
+	}
+	return (min + step - 1) / step * step
+}
+
+// retention formats a precision of secs seconds the way Whisper does.
+func retention(secs time.Duration) string {
+	switch {
+	case 0 == secs%3600:
+		return fmt.Sprintf("%dh", secs/3600)
+	case 0 == secs%60:
+		return fmt.Sprintf("%dm", secs/60)
+	}
+	return fmt.Sprintf("%ds", secs)
+}
+
+// StorageAggregation returns suggested storage-aggregation.conf stanzas
+// for the series the exporter emits for the current content of
+// c.Registry, so that rolling up to coarser archives preserves their
+// meaning: cumulative counts keep their maximum, counter deltas and reset
+// annotations are summed, minimums and maximums keep theirs, and
+// everything else is averaged. Stanzas apply in order, the first matching
+// one winning.
+func StorageAggregation(c *GraphiteConfig) string {
+	p := regexp.QuoteMeta(prefix(c))
+	counters := "max"
+	if c.CounterDeltas {
+		counters = "sum"
+	}
+	var names []string
+	for _, s := range Snapshot(c) {
+		if TypeCounter == s.Type {
+			names = append(names, regexp.QuoteMeta(s.Name))
+		}
+	}
+	var b bytes.Buffer
+	write := func(name, pattern string, xff float64, method string) {
+		fmt.Fprintf(&b, "[%s_%s]\npattern = %s\nxFilesFactor = %g\naggregationMethod = %s\n\n", stanza(prefix(c)), name, pattern, xff, method)
+	}
+	if c.AnnotateCounterResets {
+		write("reset", `^`+p+`\..*\.reset$`, 0, "sum")
+	}
+	if 0 != len(names) {
+		write("counter", `^`+p+`\.(`+strings.Join(names, "|")+`)$`, 0, counters)
+	}
+	write("count", `^`+p+`\..*\.count$`, 0, "max")
+	write("min", `^`+p+`\..*\.min$`, 0.1, "min")
+	write("max", `^`+p+`\..*\.max$`, 0.1, "max")
+	write("default", `^`+p+`\.`, 0.5, "average")
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// stanza returns the stanza name of the series under prefix p.
+func stanza(p string) string {
+	if "" == p {
+		return "graphite"
+	}
+	return strings.Replace(p, ".", "_", -1)
+}
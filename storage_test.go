@@ -0,0 +1,57 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestStorageSchemas(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		10 * time.Second: "retentions = 10s:7d,1m:30d,1h:2y\n",
+		7 * time.Second:  "retentions = 7s:7d,63s:30d,3654s:2y\n",
+		time.Minute:      "retentions = 1m:7d,10m:30d,1h:2y\n",
+	} {
+		s := StorageSchemas(&GraphiteConfig{Prefix: "app.web", FlushInterval: d})
+		if "[app_web]\npattern = ^app\\.web\\.\n"+expected != s {
+			t.Fatal("bad schema:", d, s)
+		}
+	}
+}
+
+func TestStorageAggregation(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("requests", r).Inc(1)
+	metrics.GetOrRegisterTimer("latency", r).Update(time.Millisecond)
+
+	s := StorageAggregation(&GraphiteConfig{Registry: r, Prefix: "app", CounterDeltas: true})
+	expected := `[app_counter]
+pattern = ^app\.(requests)$
+xFilesFactor = 0
+aggregationMethod = sum
+
+[app_count]
+pattern = ^app\..*\.count$
+xFilesFactor = 0
+aggregationMethod = max
+
+[app_min]
+pattern = ^app\..*\.min$
+xFilesFactor = 0.1
+aggregationMethod = min
+
+[app_max]
+pattern = ^app\..*\.max$
+xFilesFactor = 0.1
+aggregationMethod = max
+
+[app_default]
+pattern = ^app\.
+xFilesFactor = 0.5
+aggregationMethod = average
+`
+	if expected != s {
+		t.Fatal("bad aggregation:", s)
+	}
+}
@@ -0,0 +1,208 @@
+package graphite
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled is the reason a Supervisor restarts an exporter whose flush
+// loop handled no tick for c.StallTimeout, because of a deadlock, a flush
+// which never completes or a starved ticker.
+var ErrStalled = errors.New("graphite: flush loop stalled")
+
+// PanicError is a panic which escaped the flush loop of an exporter run by
+// a Supervisor.
+type PanicError struct {
+	Value interface{} // Value passed to panic
+	Stack []byte      // Stack of the flush loop when it panicked
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("graphite: flush loop panicked: %v", p.Value)
+}
+
+// Supervisor runs an exporter and restarts it when its flush loop stalls or
+// panics, carrying its queues, replays and per-series state over to the
+// new one, for long-lived daemons which cannot afford to silently stop
+// reporting. The stuck exporter is stopped in the background, since it may
+// never return. Bursts and relays are not carried over.
+type Supervisor struct {
+	c GraphiteConfig
+
+	mu       sync.Mutex // protects e and restarts
+	e        *Exporter
+	restarts int
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewSupervisor returns a Supervisor of an exporter reporting according to
+// c. It does not report anything until Start is called.
+func NewSupervisor(c GraphiteConfig) *Supervisor {
+	e := New(c)
+	e.watched = true
+	return &Supervisor{c: e.c, e: e, stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// Exporter returns the exporter currently run by s, which changes on every
+// restart.
+func (s *Supervisor) Exporter() *Exporter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.e
+}
+
+// Restarts returns the number of times s restarted its exporter.
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// Start starts the exporter and its supervision. Calling Start more than
+// once, or after Stop, has no effect.
+func (s *Supervisor) Start() {
+	s.startOnce.Do(func() {
+		e := s.Exporter()
+		e.heartbeat()
+		e.Start()
+		go s.watch()
+	})
+}
+
+// Stop stops the supervision and then the current exporter, see
+// Exporter.Stop.
+func (s *Supervisor) Stop() {
+	s.halt()
+	s.Exporter().Stop()
+}
+
+// Close stops the supervision and then closes the current exporter, see
+// Exporter.Close.
+func (s *Supervisor) Close() error {
+	s.halt()
+	return s.Exporter().Close()
+}
+
+// halt stops the supervision and waits for it to return.
+func (s *Supervisor) halt() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.startOnce.Do(func() { close(s.done) })
+	<-s.done
+}
+
+// watch restarts the exporter of s whenever its flush loop stalls or
+// panics, until s is stopped or the flush loop returns on its own.
+func (s *Supervisor) watch() {
+	defer close(s.done)
+	stall := stallTimeout(&s.c)
+	ticker := time.NewTicker(max(stall/4, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		e := s.Exporter()
+		select {
+		case <-s.stop:
+			return
+		case <-e.done:
+			if nil == e.crash {
+				return
+			}
+			s.restart(e, e.crash)
+		case now := <-ticker.C:
+			if now.Sub(time.Unix(0, atomic.LoadInt64(&e.beat))) > stall {
+				s.restart(e, ErrStalled)
+			}
+		}
+	}
+}
+
+// restart replaces old, which stopped because of err, with a new exporter.
+func (s *Supervisor) restart(old *Exporter, err error) {
+	// A stalled flush loop may hold the locks of old forever.
+	go old.Stop()
+	e := New(s.c)
+	e.watched = true
+	e.ctx = old.ctx
+	e.carryOver(old)
+	s.mu.Lock()
+	s.e = e
+	s.restarts++
+	s.mu.Unlock()
+	e.heartbeat()
+	e.Start()
+	if nil != s.c.OnRestart {
+		s.c.OnRestart(err)
+	} else {
+		log.Println("graphite: restarted the exporter:", err)
+	}
+}
+
+// stallTimeout returns the time without a handled tick after which the
+// flush loop is stalled: three flush intervals and at least a minute if
+// c.StallTimeout is zero.
+func stallTimeout(c *GraphiteConfig) time.Duration {
+	if c.StallTimeout > 0 {
+		return c.StallTimeout
+	}
+	return max(3*c.FlushInterval, time.Minute)
+}
+
+// heartbeat records that the flush loop of e is alive.
+func (e *Exporter) heartbeat() {
+	atomic.StoreInt64(&e.beat, time.Now().UnixNano())
+}
+
+// recoverRun records the panic escaping the flush loop of a supervised
+// exporter, which then returns.
+func (e *Exporter) recoverRun() {
+	if r := recover(); nil != r {
+		e.crash = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
+
+// carryOver copies the registry, queues, replays and per-series state of
+// old, which is halted, to e. A stalled flush may hold the lock of old
+// forever, in which case only c.StateFile, if any, is restored.
+func (e *Exporter) carryOver(old *Exporter) {
+	locked := false
+	for deadline := time.Now().Add(time.Second); !locked && time.Now().Before(deadline); {
+		if locked = old.mu.TryLock(); !locked {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if !locked {
+		log.Println("graphite: restarted the exporter without the state of the stalled one")
+		return
+	}
+	defer old.mu.Unlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.registry = old.registry
+	e.loaded = old.loaded
+	e.baselines = maps.Clone(old.baselines)
+	e.counts = maps.Clone(old.counts)
+	for name, stats := range old.outliers {
+		copied := *stats
+		e.outliers[name] = &copied
+	}
+	e.crossed = maps.Clone(old.crossed)
+	e.latest = maps.Clone(old.latest)
+	e.queue = slices.Clone(old.queue)
+	e.relayed = slices.Clone(old.relayed)
+	e.queued = old.queued
+	e.state = old.state
+	e.replays = slices.Clone(old.replays)
+	e.replayed = old.replayed
+	e.succeeded = old.succeeded
+	e.breaker = old.breaker
+}
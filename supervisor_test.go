@@ -0,0 +1,113 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// NewSupervisedConfig returns the configuration of an exporter whose dials
+// call dial first, and a channel receiving the lines it sent.
+func NewSupervisedConfig(dial func(n int32)) (GraphiteConfig, <-chan string) {
+	lines := make(chan string, 100)
+	var dials int32
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	return GraphiteConfig{
+		Addr:          "carbon:2003",
+		Registry:      r,
+		FlushInterval: 10 * time.Millisecond,
+		Prefix:        "p",
+		DialFunc: func(context.Context, string, string) (net.Conn, error) {
+			dial(atomic.AddInt32(&dials, 1))
+			client, server := net.Pipe()
+			go func() {
+				scanner := bufio.NewScanner(server)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+			return client, nil
+		},
+	}, lines
+}
+
+func TestSupervisorPanic(t *testing.T) {
+	c, lines := NewSupervisedConfig(func(n int32) {
+		if 1 == n {
+			panic("broken dialer")
+		}
+	})
+	restarted := make(chan error, 1)
+	c.OnRestart = func(err error) { restarted <- err }
+	s := NewSupervisor(c)
+	s.Exporter().Send("event", 1, time.Unix(1000, 0))
+	s.Start()
+	defer s.Stop()
+
+	var perr *PanicError
+	if err := <-restarted; !errors.As(err, &perr) || "broken dialer" != perr.Value {
+		t.Fatal("bad restart reason:", err)
+	}
+	if 1 != s.Restarts() {
+		t.Fatal("bad restart count:", s.Restarts())
+	}
+	for line := range lines {
+		if strings.HasPrefix(line, "p.event 1 1000") {
+			break
+		}
+	}
+}
+
+func TestSupervisorStall(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	c, lines := NewSupervisedConfig(func(n int32) {
+		if 1 == n {
+			<-unblock
+		}
+	})
+	c.StallTimeout = 50 * time.Millisecond
+	restarted := make(chan error, 1)
+	c.OnRestart = func(err error) { restarted <- err }
+	s := NewSupervisor(c)
+	s.Start()
+	defer s.Stop()
+
+	if err := <-restarted; ErrStalled != err {
+		t.Fatal("bad restart reason:", err)
+	}
+	if line := <-lines; !strings.HasPrefix(line, "p.foo 1 ") {
+		t.Fatal("restarted exporter did not flush:", line)
+	}
+}
+
+func TestCarryOver(t *testing.T) {
+	old := New(GraphiteConfig{CounterDeltas: true})
+	old.process([]MetricSnapshot{NewCounterSnapshot("foo", 10)})
+	old.Send("event", 1, time.Unix(1000, 0))
+	e := New(GraphiteConfig{CounterDeltas: true})
+	e.carryOver(old)
+	if 1 != len(e.queue) || !floatEquals(10, e.baselines["foo"]) {
+		t.Fatal("state not carried over:", e.queue, e.baselines)
+	}
+	snaps := e.process([]MetricSnapshot{NewCounterSnapshot("foo", 15)})
+	if found := snaps[0].Fields[0].Value; !floatEquals(5, found) {
+		t.Fatal("bad delta after carry-over:", found)
+	}
+
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	e = New(GraphiteConfig{})
+	e.carryOver(old)
+	if 0 != len(e.queue) {
+		t.Fatal("state of a locked exporter carried over:", e.queue)
+	}
+}
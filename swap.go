@@ -0,0 +1,49 @@
+package graphite
+
+import (
+	"github.com/rcrowley/go-metrics"
+)
+
+// SwapPolicy controls what happens to the per-series state of an exporter
+// when Exporter.SwapRegistry replaces its registry.
+type SwapPolicy int
+
+// Policies for swapped registries.
+const (
+	SwapCarryOver SwapPolicy = iota // Keep the state of the series of the new registry, such as counter baselines
+	SwapReset                       // Drop the state of every series, as if the exporter was new
+)
+
+// SwapRegistry replaces the registry e exports, c.Registry initially, with
+// r, for applications rebuilding their registry after a configuration
+// reload. The flush in progress, if any, completes with the previous
+// registry. The per-series state is kept or dropped according to
+// c.RegistrySwap; with SwapCarryOver, the state of the series missing from
+// r is pruned by the next regular flush.
+func (e *Exporter) SwapRegistry(r metrics.Registry) {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.registry = r
+	if SwapReset != e.c.RegistrySwap {
+		return
+	}
+	e.baselines = nil
+	e.counts = nil
+	e.outliers = make(map[string]*runningStats)
+	e.crossed = nil
+	e.latest = nil
+	e.previous = nil
+	e.measure()
+}
+
+// currentRegistry returns the registry e exports, see SwapRegistry.
+func (e *Exporter) currentRegistry() metrics.Registry {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if nil == e.registry {
+		return e.c.Registry
+	}
+	return e.registry
+}
@@ -0,0 +1,54 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestSwapRegistry(t *testing.T) {
+	for _, policy := range []SwapPolicy{SwapCarryOver, SwapReset} {
+		res, l, r, c, wg := NewTestServer(t, "foobar")
+		c.CounterDeltas = true
+		c.RegistrySwap = policy
+		e := New(c)
+		metrics.GetOrRegisterCounter("foo", r).Inc(10)
+		wg.Add(1)
+		if err := e.flush().Err; nil != err {
+			t.Fatal(err)
+		}
+		wg.Wait()
+
+		swapped := metrics.NewRegistry()
+		metrics.GetOrRegisterCounter("foo", swapped).Inc(15)
+		metrics.GetOrRegisterGauge("bar", swapped).Update(1)
+		e.SwapRegistry(swapped)
+		wg.Add(2)
+		if err := e.FlushMetric("bar"); nil != err {
+			t.Fatal("swapped registry not used:", err)
+		}
+		if err := e.flush().Err; nil != err {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		l.Close()
+
+		expected := 10.0 + 5
+		if SwapReset == policy {
+			expected = 10 + 15
+		}
+		if found := res["foobar.foo"]; !floatEquals(expected, found) {
+			t.Fatal("bad deltas after the swap:", policy, expected, found)
+		}
+	}
+}
+
+func TestSwapRegistryReset(t *testing.T) {
+	e := New(GraphiteConfig{FlushInterval: time.Second, CounterDeltas: true, RegistrySwap: SwapReset})
+	e.process([]MetricSnapshot{NewCounterSnapshot("foo", 10)})
+	e.SwapRegistry(metrics.NewRegistry())
+	if 0 != len(e.baselines) || 0 != e.Memory().State {
+		t.Fatal("state not reset:", e.baselines, e.Memory())
+	}
+}
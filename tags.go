@@ -0,0 +1,89 @@
+package graphite
+
+import "strings"
+
+// TaggedName returns the name of a metric called name with tags, to be
+// registered with go-metrics, such as "requests;method=GET". With c.Tagged
+// the tags are sent as those of a Graphite 1.1 tagged series, after the
+// field of the metric: "<prefix>.requests.count;method=GET".
+func TaggedName(name string, tags map[string]string) string {
+	return name + tagString(tags)
+}
+
+// NameParser splits a metric name into the path and the tags sent with
+// c.Tagged, nil if none.
+type NameParser func(name string) (string, map[string]string)
+
+// BraceTags is a NameParser for names holding their tags between braces,
+// such as "http.requests{method=GET,code=200}". Names without braces, or
+// whose braces hold no valid tag, are sent untagged.
+func BraceTags(name string) (string, map[string]string) {
+	i := strings.IndexByte(name, '{')
+	if i < 0 || !strings.HasSuffix(name, "}") {
+		return name, nil
+	}
+	var tags map[string]string
+	for _, tag := range strings.Split(name[i+1:len(name)-1], ",") {
+		if j := strings.IndexByte(tag, '='); j > 0 {
+			if nil == tags {
+				tags = make(map[string]string)
+			}
+			tags[strings.TrimSpace(tag[:j])] = strings.TrimSpace(tag[j+1:])
+		}
+	}
+	if nil == tags {
+		return name, nil
+	}
+	return name[:i], tags
+}
+
+// parseName returns the path and tags of name according to c.Names, or
+// those of a name such as "<path>;<tag>=<value>" if nil.
+func parseName(c *GraphiteConfig, name string) (string, map[string]string) {
+	if nil != c.Names {
+		return c.Names(name)
+	}
+	return splitTags(name)
+}
+
+// splitTags returns the path of name and its tags, nil if none.
+func splitTags(name string) (string, map[string]string) {
+	parts := strings.Split(name, ";")
+	if 1 == len(parts) {
+		return name, nil
+	}
+	tags := make(map[string]string, len(parts)-1)
+	for _, tag := range parts[1:] {
+		if i := strings.IndexByte(tag, '='); i > 0 {
+			tags[tag[:i]] = tag[i+1:]
+		}
+	}
+	return parts[0], tags
+}
+
+// tagString returns tags in the form ";<tag>=<value>", sorted by tag the
+// way Graphite normalizes tagged series.
+func tagString(tags map[string]string) string {
+	var b strings.Builder
+	for _, k := range sortedKeys(tags) {
+		b.WriteString(";" + k + "=" + tags[k])
+	}
+	return b.String()
+}
+
+// tagged returns path, which may hold tags, with the tags of c.Tags it does
+// not override, when c.Tagged is set.
+func tagged(c *GraphiteConfig, path string) string {
+	if !c.Tagged || (0 == len(c.Tags) && !strings.Contains(path, ";")) {
+		return path
+	}
+	path, tags := splitTags(path)
+	all := make(map[string]string, len(c.Tags)+len(tags))
+	for k, v := range c.Tags {
+		all[k] = v
+	}
+	for k, v := range tags {
+		all[k] = v
+	}
+	return path + tagString(all)
+}
@@ -0,0 +1,81 @@
+package graphite
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestTagged(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.Tagged = true
+	c.Tags = map[string]string{"env": "prod"}
+	c.ReportSeriesCounts = true
+	name := TaggedName("requests", map[string]string{"method": "GET", "code": "200"})
+	if expected := "requests;code=200;method=GET"; expected != name {
+		t.Fatal("bad tagged name:", name)
+	}
+	metrics.GetOrRegisterMeter(name, r).Mark(2)
+	metrics.GetOrRegisterGauge("load;env=dev", r).Update(3)
+
+	e := New(c)
+	e.Send("deploy", 1, time.Now())
+	wg.Add(1)
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	for path, expected := range map[string]float64{
+		"foobar.requests.count;code=200;env=prod;method=GET": 2,
+		"foobar.load;env=dev":                                3,
+		"foobar.deploy;env=prod":                             1,
+		"foobar.exporter.series.requests;env=prod":           5,
+	} {
+		if found, ok := res[path]; !ok || !floatEquals(found, expected) {
+			t.Fatal("bad tagged series:", path, expected, found, res)
+		}
+	}
+	names := SeriesNames(&c)
+	if expected := "foobar.requests.count;code=200;env=prod;method=GET"; !slices.Contains(names, expected) {
+		t.Fatal("tagged series not named:", expected, names)
+	}
+
+	s, _ := SnapshotOf(&c, name, metrics.NewCounter())
+	if expected := map[string]string{"method": "GET", "code": "200"}; !reflect.DeepEqual(expected, s.Tags) {
+		t.Fatal("bad snapshot tags:", s.Tags)
+	}
+}
+
+func TestUntagged(t *testing.T) {
+	c := GraphiteConfig{Prefix: "p", Tags: map[string]string{"env": "prod"}}
+	s := NewCounterSnapshot("requests;method=GET", 1)
+	if expected, found := "p.requests;method=GET", seriesName(&c, s, s.Fields[0]); expected != found {
+		t.Fatal("bad untagged series:", expected, found)
+	}
+}
+
+func TestBraceTags(t *testing.T) {
+	for name, expected := range map[string]struct {
+		path string
+		tags map[string]string
+	}{
+		"http.requests{method=GET, code=200}": {"http.requests", map[string]string{"method": "GET", "code": "200"}},
+		"http.requests":                       {"http.requests", nil},
+		"http.requests{}":                     {"http.requests{}", nil},
+		"http.requests{method":                {"http.requests{method", nil},
+	} {
+		if path, tags := BraceTags(name); expected.path != path || !reflect.DeepEqual(expected.tags, tags) {
+			t.Fatal("bad parse:", name, path, tags)
+		}
+	}
+
+	c := GraphiteConfig{Prefix: "p", Tagged: true, Names: BraceTags}
+	s := NewMeterSnapshot("http.requests{method=GET}", 1, Rates{})
+	if expected, found := "p.http.requests.count;method=GET", seriesName(&c, s, s.Fields[0]); expected != found {
+		t.Fatal("bad series:", expected, found)
+	}
+}
@@ -0,0 +1,74 @@
+package graphite
+
+// Comparison is the way a ThresholdRule compares values to its threshold.
+type Comparison int
+
+// Comparisons of threshold rules.
+const (
+	Above Comparison = iota // Crossed when the value is greater than the threshold
+	Below                   // Crossed when the value is less than the threshold
+)
+
+// ThresholdRule triggers an immediate export of a metric when the value of
+// one of its series matching Pattern crosses Value, in either direction, so
+// that alerting based on Graphite sees critical transitions without waiting
+// for the next flush. Values are compared before value policies such as
+// counter deltas or unit conversions apply.
+type ThresholdRule struct {
+	Pattern    string
+	Comparison Comparison
+	Value      float64
+}
+
+// crossed returns true if v is beyond the threshold of rule.
+func (rule ThresholdRule) crossed(v float64) bool {
+	if Below == rule.Comparison {
+		return v < rule.Value
+	}
+	return v > rule.Value
+}
+
+// checkThresholds evaluates c.Thresholds and exports the metrics whose
+// series crossed a threshold since the previous evaluation.
+func (e *Exporter) checkThresholds() {
+	names := e.crossings(e.snapshot())
+	if 0 == len(names) {
+		return
+	}
+	res := e.flushMatching(func(name string) bool { return names[name] })
+	logFailure(res)
+	e.record(res)
+}
+
+// crossings updates the threshold state of the series of snaps and returns
+// the names of the metrics for which it changed.
+func (e *Exporter) crossings(snaps []MetricSnapshot) map[string]bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if nil == e.crossed {
+		e.crossed = make(map[thresholdKey]bool)
+	}
+	names := make(map[string]bool)
+	for _, s := range snaps {
+		for _, f := range s.Fields {
+			name := fieldName(s, f)
+			for i, rule := range e.c.Thresholds {
+				if !match(rule.Pattern, name) {
+					continue
+				}
+				key := thresholdKey{name: name, rule: i}
+				if crossed := rule.crossed(f.Value); crossed != e.crossed[key] {
+					e.crossed[key] = crossed
+					names[s.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// thresholdKey identifies the state of a series with respect to a rule.
+type thresholdKey struct {
+	name string
+	rule int
+}
@@ -0,0 +1,53 @@
+package graphite
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestCrossings(t *testing.T) {
+	e := New(GraphiteConfig{Thresholds: []ThresholdRule{
+		{Pattern: "queue.depth", Comparison: Above, Value: 100},
+		{Pattern: "pool.idle", Comparison: Below, Value: 1},
+	}})
+	check := func(depth, idle int64, expected ...string) {
+		t.Helper()
+		names := e.crossings([]MetricSnapshot{NewGaugeSnapshot("queue.depth", depth), NewGaugeSnapshot("pool.idle", idle)})
+		found := make([]string, 0)
+		for _, name := range []string{"pool.idle", "queue.depth"} {
+			if names[name] {
+				found = append(found, name)
+			}
+		}
+		if !reflect.DeepEqual(found, append([]string{}, expected...)) {
+			t.Fatal("bad crossings:", depth, idle, found)
+		}
+	}
+	check(10, 5)
+	check(150, 5, "queue.depth")
+	check(200, 0, "pool.idle")
+	check(200, 0)
+	check(50, 0, "queue.depth")
+}
+
+func TestThresholdExport(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.FlushInterval = time.Hour
+	c.ThresholdInterval = 5 * time.Millisecond
+	c.Thresholds = []ThresholdRule{{Pattern: "errors", Value: 10}}
+	metrics.GetOrRegisterCounter("requests", r).Inc(1)
+	metrics.GetOrRegisterCounter("errors", r).Inc(11)
+
+	wg.Add(1)
+	e := New(c)
+	e.Start()
+	wg.Wait()
+	e.Stop()
+	if 1 != len(res) || 11 != res["foobar.errors"] {
+		t.Fatal("bad series:", res)
+	}
+}
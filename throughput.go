@@ -0,0 +1,80 @@
+package graphite
+
+import (
+	"math"
+	"time"
+)
+
+// Throughput holds the smoothed rates at which an exporter emits lines and
+// bytes, per second.
+type Throughput struct {
+	Lines1 float64 // One-minute moving average of lines sent
+	Lines5 float64 // Five-minute moving average of lines sent
+	Bytes1 float64 // One-minute moving average of bytes written
+	Bytes5 float64 // Five-minute moving average of bytes written
+}
+
+// ewma is an exponentially weighted moving average of a rate, updated at
+// irregular intervals.
+type ewma struct {
+	window time.Duration
+	rate   float64
+}
+
+// update accounts n events which happened over elapsed.
+func (a *ewma) update(n float64, elapsed time.Duration) {
+	alpha := 1 - math.Exp(-float64(elapsed)/float64(a.window))
+	a.rate += alpha * (n/elapsed.Seconds() - a.rate)
+}
+
+// throughput tracks the rates of a Throughput.
+type throughput struct {
+	last           time.Time
+	lines1, lines5 ewma
+	bytes1, bytes5 ewma
+}
+
+func newThroughput() throughput {
+	return throughput{
+		lines1: ewma{window: time.Minute},
+		lines5: ewma{window: 5 * time.Minute},
+		bytes1: ewma{window: time.Minute},
+		bytes5: ewma{window: 5 * time.Minute},
+	}
+}
+
+// update accounts the lines and bytes sent by the flush which started at
+// t, the previous one having started at tp.last.
+func (tp *throughput) update(t time.Time, lines, bytes int) {
+	if !tp.last.IsZero() && t.After(tp.last) {
+		elapsed := t.Sub(tp.last)
+		tp.lines1.update(float64(lines), elapsed)
+		tp.lines5.update(float64(lines), elapsed)
+		tp.bytes1.update(float64(bytes), elapsed)
+		tp.bytes5.update(float64(bytes), elapsed)
+	}
+	tp.last = t
+}
+
+// throughput accounts res. It needs e.mu.
+func (e *Exporter) throughput(res FlushResult) {
+	lines := res.Lines
+	if nil != res.Err {
+		lines = 0
+	}
+	e.tput.update(res.Time, lines, res.Bytes)
+}
+
+// Throughput returns the rates at which e emitted lines and bytes over the
+// last minutes. They are also sent as "<prefix>.exporter.lines.one-minute"
+// and so on when c.ReportThroughput is set.
+func (e *Exporter) Throughput() Throughput {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return Throughput{
+		Lines1: e.tput.lines1.rate,
+		Lines5: e.tput.lines5.rate,
+		Bytes1: e.tput.bytes1.rate,
+		Bytes5: e.tput.bytes5.rate,
+	}
+}
@@ -0,0 +1,51 @@
+package graphite
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestThroughput(t *testing.T) {
+	e := New(GraphiteConfig{})
+	start := time.Unix(1234567890, 0)
+	for i := 0; i <= 60; i++ {
+		e.record(FlushResult{Time: start.Add(time.Duration(i) * 10 * time.Second), Lines: 100, Bytes: 1000})
+	}
+	tp := e.Throughput()
+	// 100 lines every 10s is a steady 10 lines/s.
+	if math.Abs(tp.Lines1-10) > 0.01 || math.Abs(tp.Bytes1-100) > 0.1 {
+		t.Fatal("bad one-minute rates:", tp)
+	}
+	if tp.Lines5 < 8.6 || tp.Lines5 > tp.Lines1 {
+		t.Fatal("bad five-minute rate:", tp)
+	}
+
+	// Failed flushes do not count lines.
+	fail := errors.New("fail")
+	for i := 61; i <= 120; i++ {
+		e.record(FlushResult{Time: start.Add(time.Duration(i) * 10 * time.Second), Lines: 100, Err: fail})
+	}
+	if tp := e.Throughput(); tp.Lines1 > 0.01 || tp.Bytes1 > 0.01 {
+		t.Fatal("bad rates after failures:", tp)
+	}
+}
+
+func TestReportThroughput(t *testing.T) {
+	res, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.ReportThroughput = true
+	e := New(c)
+	e.tput.lines1.rate = 12
+
+	wg.Add(1)
+	e.flush()
+	wg.Wait()
+	if expected, found := 12.0, res["foobar.exporter.lines.one-minute"]; !floatEquals(found, expected) {
+		t.Fatal("bad value:", expected, found)
+	}
+	if _, ok := res["foobar.exporter.bytes.five-minute"]; !ok {
+		t.Fatal("missing series:", res)
+	}
+}
@@ -0,0 +1,95 @@
+package graphite
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// secure returns conn wrapped in a TLS client connection configured by
+// c.TLSConfig once the handshake completed, or conn itself if c.TLSConfig
+// is nil. Connections are dialed before being wrapped, rather than with
+// tls.DialWithDialer, so that the local address, port range and resolved
+// addresses of Addr apply to them too. The server name defaults to the host
+// of Addr since the address dialed is usually one it resolved to.
+func (e *Exporter) secure(ctx context.Context, conn net.Conn, endpoint string) (net.Conn, error) {
+	c := &e.c
+	if nil == c.TLSConfig {
+		return conn, nil
+	}
+	if datagram(network(c)) {
+		conn.Close()
+		return nil, errors.New("graphite: TLSConfig requires a stream network")
+	}
+	cfg := c.TLSConfig.Clone()
+	if "" == cfg.ServerName && !cfg.InsecureSkipVerify {
+		if host, _, err := net.SplitHostPort(endpoint); nil == err {
+			cfg.ServerName = host
+		}
+	}
+	if nil != c.ClientCert {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.ClientCert()
+		}
+	}
+	tc := tls.Client(conn, cfg)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := tc.HandshakeContext(ctx); nil != err {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// ClientCertFunc returns the client certificate presented to the server
+// for mutual TLS authentication. It is called on every handshake, so that
+// rotated certificates are presented by the next connection; connections
+// kept open by c.KeepAlive keep the certificate they were established with.
+type ClientCertFunc func() (*tls.Certificate, error)
+
+// LoadClientCert returns a ClientCertFunc reading the PEM encoded
+// certificate and key in certFile and keyFile, reloaded whenever either
+// file is modified. The certificate last loaded keeps being presented,
+// and the error logged, if the files cannot be read or do not match.
+func LoadClientCert(certFile, keyFile string) ClientCertFunc {
+	var mu sync.Mutex
+	var cert *tls.Certificate
+	var certMod, keyMod time.Time
+	return func() (*tls.Certificate, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		ci, err := os.Stat(certFile)
+		if nil != err {
+			return loaded(cert, err)
+		}
+		ki, err := os.Stat(keyFile)
+		if nil != err {
+			return loaded(cert, err)
+		}
+		if nil != cert && ci.ModTime().Equal(certMod) && ki.ModTime().Equal(keyMod) {
+			return cert, nil
+		}
+		c, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if nil != err {
+			return loaded(cert, err)
+		}
+		cert, certMod, keyMod = &c, ci.ModTime(), ki.ModTime()
+		return cert, nil
+	}
+}
+
+// loaded returns cert, the certificate last loaded, after logging err, or
+// err if no certificate was loaded yet.
+func loaded(cert *tls.Certificate, err error) (*tls.Certificate, error) {
+	if nil == cert {
+		return nil, err
+	}
+	log.Println("graphite: keeping the client certificate last loaded:", err)
+	return cert, nil
+}
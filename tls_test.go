@@ -0,0 +1,179 @@
+package graphite
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// NewTLSServer starts a TLS server configured by cfg, with a certificate
+// valid for 127.0.0.1 trusted by the roots returned, which sends every line
+// received to lines along with the client certificate presented, if any.
+func NewTLSServer(t *testing.T, cfg *tls.Config) (net.Listener, *x509.CertPool, chan tlsLine) {
+	s := httptest.NewUnstartedServer(nil)
+	s.StartTLS()
+	roots := x509.NewCertPool()
+	roots.AddCert(s.Certificate())
+	cfg.Certificates = s.TLS.Certificates
+	s.Close()
+	l, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if nil != err {
+		t.Fatal(err)
+	}
+	lines := make(chan tlsLine, 16)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if nil != err {
+						return
+					}
+					var peer *x509.Certificate
+					if certs := conn.(*tls.Conn).ConnectionState().PeerCertificates; 0 != len(certs) {
+						peer = certs[0]
+					}
+					lines <- tlsLine{line, peer}
+				}
+			}()
+		}
+	}()
+	return l, roots, lines
+}
+
+type tlsLine struct {
+	line string
+	peer *x509.Certificate
+}
+
+func receive(t *testing.T, lines chan tlsLine) tlsLine {
+	select {
+	case l := <-lines:
+		return l
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing received")
+	}
+	return tlsLine{}
+}
+
+func TestTLS(t *testing.T) {
+	l, roots, lines := NewTLSServer(t, &tls.Config{})
+	defer l.Close()
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	c := GraphiteConfig{Addr: l.Addr().String(), Registry: r, FlushInterval: time.Second, Prefix: "p", TLSConfig: &tls.Config{RootCAs: roots}}
+	if err := New(c).flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if line := receive(t, lines).line; !strings.HasPrefix(line, "p.foo 1 ") {
+		t.Fatal("bad line:", line)
+	}
+
+	c.TLSConfig = &tls.Config{}
+	if err := New(c).flush().Err; nil == err {
+		t.Fatal("certificate of an unknown authority accepted")
+	}
+}
+
+// clientCert returns a self-signed client certificate with serial number
+// serial, PEM encoded along with its key.
+func clientCert(t *testing.T, serial int64) (*x509.Certificate, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if nil != err {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	k, err := x509.MarshalECPrivateKey(key)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: k})
+}
+
+func TestClientCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	clients := x509.NewCertPool()
+	pems := make(map[int64][2][]byte)
+	for _, serial := range []int64{1, 2} {
+		cert, certPEM, keyPEM := clientCert(t, serial)
+		clients.AddCert(cert)
+		pems[serial] = [2][]byte{certPEM, keyPEM}
+	}
+	write := func(serial int64) {
+		mod := time.Unix(1000*serial, 0)
+		for i, name := range []string{certFile, keyFile} {
+			if err := os.WriteFile(name, pems[serial][i], 0600); nil != err {
+				t.Fatal(err)
+			}
+			os.Chtimes(name, mod, mod)
+		}
+	}
+	write(1)
+
+	l, roots, lines := NewTLSServer(t, &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clients})
+	defer l.Close()
+	r := metrics.NewRegistry()
+	metrics.GetOrRegisterGauge("foo", r).Update(1)
+	c := GraphiteConfig{
+		Addr:          l.Addr().String(),
+		Registry:      r,
+		FlushInterval: time.Second,
+		Prefix:        "p",
+		TLSConfig:     &tls.Config{RootCAs: roots},
+		ClientCert:    LoadClientCert(certFile, keyFile),
+	}
+	e := New(c)
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if peer := receive(t, lines).peer; nil == peer || 1 != peer.SerialNumber.Int64() {
+		t.Fatal("bad client certificate:", peer)
+	}
+
+	// The rotated certificate is presented by the next connection.
+	write(2)
+	if err := e.flush().Err; nil != err {
+		t.Fatal(err)
+	}
+	if peer := receive(t, lines).peer; nil == peer || 2 != peer.SerialNumber.Int64() {
+		t.Fatal("rotated client certificate not presented:", peer)
+	}
+
+	// A broken rotation keeps the certificate last loaded.
+	os.WriteFile(keyFile, []byte("broken"), 0600)
+	if cert, err := c.ClientCert(); nil != err || nil == cert {
+		t.Fatal("certificate last loaded not kept:", err)
+	}
+}
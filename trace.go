@@ -0,0 +1,62 @@
+package graphite
+
+import (
+	"context"
+)
+
+// Tracer starts tracing spans. It mirrors the shape of the OpenTelemetry
+// tracing API so that a few lines of glue make an OpenTelemetry tracer
+// usable without this package depending on OpenTelemetry:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, graphite.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttribute(key string, value interface{}) {
+//		s.Span.SetAttributes(attribute.String(key, fmt.Sprint(value)))
+//	}
+//
+//	func (s otelSpan) RecordError(err error) { s.Span.RecordError(err) }
+//
+//	func (s otelSpan) End() { s.Span.End() }
+//
+// Spans of flushes started by FlushContext are children of the span of the
+// context passed to it. Spans of other flushes start from the context given
+// to GraphiteWithContext, if any.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a tracing span started by a Tracer.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// trace starts the span of a flush as a child of ctx and returns the context
+// of the span along with the function ending it with the attributes of the
+// flush result.
+func (e *Exporter) trace(ctx context.Context) (context.Context, func(*FlushResult)) {
+	if nil == e.c.Tracer {
+		return ctx, func(*FlushResult) {}
+	}
+	ctx, span := e.c.Tracer.Start(ctx, "graphite.flush")
+	return ctx, func(res *FlushResult) {
+		span.SetAttribute("graphite.destination", res.Addr)
+		span.SetAttribute("graphite.lines", res.Lines)
+		span.SetAttribute("graphite.bytes", res.Bytes)
+		if nil != res.Err {
+			span.SetAttribute("graphite.outcome", "failure")
+			span.RecordError(res.Err)
+		} else {
+			span.SetAttribute("graphite.outcome", "success")
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,80 @@
+package graphite
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &testSpan{name: name, attrs: make(map[string]interface{}), parent: ctx.Value(parentKey{})}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+type testSpan struct {
+	name   string
+	attrs  map[string]interface{}
+	parent interface{}
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)                      { s.err = err }
+func (s *testSpan) End()                                       { s.ended = true }
+
+func TestTracer(t *testing.T) {
+	_, l, _, c, wg := NewTestServer(t, "foobar")
+	tracer := &testTracer{}
+	c.Tracer = tracer
+	e := New(c)
+	e.Send("foo", 1, time.Now())
+
+	wg.Add(1)
+	e.flush()
+	wg.Wait()
+	l.Close()
+	e.flush()
+
+	if len(tracer.spans) != 2 {
+		t.Fatal("bad span count:", len(tracer.spans))
+	}
+	ok, failed := tracer.spans[0], tracer.spans[1]
+	if ok.name != "graphite.flush" || !ok.ended || ok.err != nil {
+		t.Fatal("bad span:", ok)
+	}
+	if ok.attrs["graphite.outcome"] != "success" || ok.attrs["graphite.lines"] != 1 || ok.attrs["graphite.destination"] != c.Addr {
+		t.Fatal("bad attributes:", ok.attrs)
+	}
+	if failed.attrs["graphite.outcome"] != "failure" || failed.err == nil || !failed.ended {
+		t.Fatal("bad span:", failed)
+	}
+}
+
+type parentKey struct{}
+
+func TestTracerParent(t *testing.T) {
+	_, l, _, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	tracer := &testTracer{}
+	c.Tracer = tracer
+	e := New(c)
+
+	ctx := context.WithValue(context.Background(), parentKey{}, "request")
+	wg.Add(1)
+	if err := e.FlushContext(ctx); nil != err {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if 1 != len(tracer.spans) {
+		t.Fatal("bad span count:", len(tracer.spans))
+	}
+	if parent := tracer.spans[0].parent; "request" != parent {
+		t.Fatal("span not started from the context of the flush:", parent)
+	}
+}
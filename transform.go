@@ -0,0 +1,49 @@
+package graphite
+
+import (
+	"math"
+)
+
+// FieldTransform rewrites the fields of the series matching Pattern when
+// they are encoded, to normalize the output without touching the
+// instrumentation. Func may change the value and the precision of the
+// field but not its name. Every matching transform applies, in order,
+// after the value policies of the exporter such as unit conversions.
+type FieldTransform struct {
+	Pattern string
+	Func    func(f Field) Field
+}
+
+// Scale returns a transform function multiplying values by factor.
+func Scale(factor float64) func(Field) Field {
+	return func(f Field) Field {
+		f.Value *= factor
+		return f
+	}
+}
+
+// Round returns a transform function rounding values to decimals.
+func Round(decimals int) func(Field) Field {
+	p := math.Pow10(decimals)
+	return func(f Field) Field {
+		f.Value = math.Round(f.Value*p) / p
+		if decimals >= 0 {
+			f.Precision = decimals
+		} else {
+			f.Precision = 0
+		}
+		return f
+	}
+}
+
+// transform applies the transforms of c matching name to f.
+func transform(c *GraphiteConfig, name string, f Field) Field {
+	for _, t := range c.Transforms {
+		if match(t.Pattern, name) {
+			fieldName := f.Name
+			f = t.Func(f)
+			f.Name = fieldName
+		}
+	}
+	return f
+}
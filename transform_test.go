@@ -0,0 +1,34 @@
+package graphite
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTransforms(t *testing.T) {
+	c := &GraphiteConfig{
+		DurationUnit: time.Nanosecond,
+		Prefix:       "app",
+		Transforms: []FieldTransform{
+			{Pattern: "latency.mean", Func: Scale(1e-6)},
+			{Pattern: "latency.mean", Func: Round(1)},
+			{Pattern: "load", Func: Round(0)},
+			{Pattern: "load", Func: func(f Field) Field {
+				f.Name = "renamed"
+				return f
+			}},
+		},
+	}
+	snaps := []MetricSnapshot{
+		NewGaugeFloat64Snapshot("load", 2.6),
+		{Name: "latency", Type: TypeTimer, Fields: []Field{{Name: "mean", Value: 1234567, Precision: 2}, {Name: "max", Value: 5, Precision: 2}}},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, c, snaps, time.Unix(1, 0)); nil != err {
+		t.Fatal(err)
+	}
+	if expected := "app.load 3 1\napp.latency.mean 1.2 1\napp.latency.max 5.00 1\n"; expected != buf.String() {
+		t.Fatalf("bad payload:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,48 @@
+package graphite
+
+import (
+	"math"
+)
+
+// Common factors for UnitConversion.
+const (
+	BytesToKilobytes          = 1.0 / (1 << 10)
+	BytesToMegabytes          = 1.0 / (1 << 20)
+	BytesToGigabytes          = 1.0 / (1 << 30)
+	NanosecondsToMilliseconds = 1e-6
+	NanosecondsToSeconds      = 1e-9
+	RatioToPercent            = 100
+)
+
+// UnitConversion multiplies the values of the series matching Pattern by
+// Factor when they are encoded. A non-empty Suffix is appended as an extra
+// node to the series name, such as "mb", so that dashboards show the unit.
+// Only the first matching conversion applies. Other value policies, such as
+// clamping, are expressed in the original unit.
+type UnitConversion struct {
+	Pattern string
+	Factor  float64
+	Suffix  string
+}
+
+// convert applies the first of units matching name to f.
+func convert(units []UnitConversion, name string, f Field) Field {
+	for _, u := range units {
+		if !match(u.Pattern, name) {
+			continue
+		}
+		f.Value *= u.Factor
+		if u.Factor != math.Trunc(u.Factor) && f.Precision < 2 {
+			f.Precision = 2
+		}
+		if "" != u.Suffix {
+			if "" == f.Name {
+				f.Name = u.Suffix
+			} else {
+				f.Name += "." + u.Suffix
+			}
+		}
+		break
+	}
+	return f
+}
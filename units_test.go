@@ -0,0 +1,28 @@
+package graphite
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestUnits(t *testing.T) {
+	c := GraphiteConfig{Prefix: "p", Units: []UnitConversion{
+		{Pattern: "heap", Factor: BytesToMegabytes, Suffix: "mb"},
+		{Pattern: "hit-ratio", Factor: RatioToPercent},
+		{Pattern: "db.*", Factor: NanosecondsToMilliseconds, Suffix: "ms"},
+	}}
+	e := New(c)
+	snaps := e.process([]MetricSnapshot{
+		NewGaugeSnapshot("heap", 3<<19),
+		NewGaugeFloat64Snapshot("hit-ratio", 0.25),
+		{Name: "db", Type: TypeTimer, Fields: []Field{floatField("mean", 2500000)}},
+	})
+
+	var buf bytes.Buffer
+	Encode(&buf, &c, snaps, time.Unix(1, 0))
+	expected := "p.heap.mb 1.50 1\np.hit-ratio 25.000000 1\np.db.mean.ms 2.50 1\n"
+	if found := buf.String(); found != expected {
+		t.Fatalf("bad payload:\n%s", found)
+	}
+}
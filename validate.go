@@ -0,0 +1,97 @@
+package graphite
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxClockSkew is how far in the future ValidateLine accepts timestamps.
+const maxClockSkew = 24 * time.Hour
+
+// ValidateLine returns an error describing why s, optionally terminated by
+// a newline, is not a well-formed line of the Graphite plaintext protocol:
+// a path made of non-empty nodes without whitespace or control characters,
+// a finite value and a Unix timestamp in seconds not far in the future.
+// Carbon silently drops such lines.
+func ValidateLine(s string) error {
+	line := strings.TrimSuffix(s, "\n")
+	if strings.ContainsAny(line, "\r\n") {
+		return fmt.Errorf("graphite: invalid line %q: embedded newline", s)
+	}
+	parts := strings.Split(line, " ")
+	if 3 != len(parts) {
+		return fmt.Errorf("graphite: invalid line %q: %d space separated fields instead of 3", s, len(parts))
+	}
+	name, value, ts := parts[0], parts[1], parts[2]
+	if "" == name {
+		return fmt.Errorf("graphite: invalid line %q: empty name", s)
+	}
+	for _, node := range strings.Split(name, ".") {
+		if "" == node {
+			return fmt.Errorf("graphite: invalid line %q: empty node in name", s)
+		}
+	}
+	if i := strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }); i >= 0 {
+		return fmt.Errorf("graphite: invalid line %q: invalid character %q in name", s, name[i])
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if nil != err {
+		return fmt.Errorf("graphite: invalid line %q: malformed value", s)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("graphite: invalid line %q: non-finite value", s)
+	}
+	t, err := strconv.ParseInt(ts, 10, 64)
+	if nil != err {
+		return fmt.Errorf("graphite: invalid line %q: malformed timestamp", s)
+	}
+	if t <= 0 {
+		return fmt.Errorf("graphite: invalid line %q: non-positive timestamp", s)
+	}
+	if t > time.Now().Add(maxClockSkew).Unix() {
+		return fmt.Errorf("graphite: invalid line %q: timestamp in the future, or not in seconds", s)
+	}
+	return nil
+}
+
+// InvalidLinesError is returned by flushes in strict mode when some of the
+// generated lines do not pass ValidateLine. Nothing is sent in that case.
+type InvalidLinesError struct {
+	Errs []error // Error of every invalid line, in the order of the lines
+}
+
+func (e *InvalidLinesError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("graphite: %d invalid lines:\n%s", len(e.Errs), strings.Join(msgs, "\n"))
+}
+
+// Unwrap returns the error of every invalid line.
+func (e *InvalidLinesError) Unwrap() []error {
+	return e.Errs
+}
+
+// validate returns the datapoints of dps whose line passes ValidateLine,
+// and the errors of the others.
+func validate(dps []datapoint) ([]datapoint, []error) {
+	var b bytes.Buffer
+	var errs []error
+	valid := dps[:0]
+	for _, dp := range dps {
+		b.Reset()
+		encode(&b, []datapoint{dp})
+		if err := ValidateLine(b.String()); nil != err {
+			errs = append(errs, err)
+			continue
+		}
+		valid = append(valid, dp)
+	}
+	return valid, errs
+}
@@ -0,0 +1,83 @@
+package graphite
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestValidateLine(t *testing.T) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	for _, line := range []string{
+		"foo.bar 1 " + now + "\n",
+		"foo.bar -1.5 " + now,
+		"foo;tag=value 1e3 " + now,
+	} {
+		if err := ValidateLine(line); nil != err {
+			t.Error(err)
+		}
+	}
+	for _, line := range []string{
+		"",
+		"foo.bar 1",
+		"foo.bar  1 " + now,
+		"foo\nbar 1 " + now,
+		"foo..bar 1 " + now,
+		".foo 1 " + now,
+		"foo\tbar 1 " + now,
+		"foo.bar one " + now,
+		"foo.bar NaN " + now,
+		"foo.bar +Inf " + now,
+		"foo.bar 1 0",
+		"foo.bar 1 1.5",
+		"foo.bar 1 " + now + "000",
+	} {
+		if err := ValidateLine(line); nil == err {
+			t.Errorf("%q is valid", line)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now().Unix()
+	dps, errs := validate([]datapoint{
+		{path: "foo", value: 1, timestamp: now},
+		{path: "foo bar", value: 1, timestamp: now},
+		{path: "bar", value: math.NaN(), precision: 2, timestamp: now},
+		{path: "baz", value: 2, timestamp: now},
+	})
+	if 2 != len(dps) || "foo" != dps[0].path || "baz" != dps[1].path {
+		t.Fatal("bad datapoints:", dps)
+	}
+	if 2 != len(errs) {
+		t.Fatal("bad errors:", errs)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	res, l, r, c, wg := NewTestServer(t, "foobar")
+	defer l.Close()
+	c.Strict = true
+	metrics.GetOrRegisterCounter("foo", r).Inc(1)
+	metrics.GetOrRegisterGaugeFloat64("bad gauge", r).Update(math.Inf(1))
+	metrics.GetOrRegisterGaugeFloat64("nan", r).Update(math.NaN())
+
+	wg.Add(1)
+	err := GraphiteOnce(c)
+	wg.Wait()
+	var invalid *InvalidLinesError
+	if !errors.As(err, &invalid) || 2 != len(invalid.Errs) {
+		t.Fatal("bad error:", err)
+	}
+	if !strings.Contains(err.Error(), "foobar.bad gauge") || !strings.Contains(err.Error(), "foobar.nan") {
+		t.Fatal("offending series not listed:", err)
+	}
+	if 0 != len(res) {
+		t.Fatal("sent in strict mode:", res)
+	}
+}
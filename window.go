@@ -0,0 +1,150 @@
+package graphite
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// SlidingWindowSample is a metrics.Sample which only holds the values
+// recorded within a sliding time window, so that the percentiles exported
+// for a timer or histogram using it reflect only the last few minutes
+// rather than an exponentially decayed history. At most size values are
+// kept, the oldest being dropped first.
+type SlidingWindowSample struct {
+	window time.Duration
+	size   int
+	now    func() time.Time
+
+	mutex  sync.Mutex
+	count  int64
+	times  []time.Time
+	values []int64
+}
+
+// NewSlidingWindowSample returns a sample holding at most size values
+// recorded within the last window.
+func NewSlidingWindowSample(window time.Duration, size int) *SlidingWindowSample {
+	return &SlidingWindowSample{window: window, size: size, now: time.Now}
+}
+
+// NewSlidingWindowTimer returns a metrics.Timer whose percentiles are
+// computed over the values recorded within the last window. Its snapshots
+// expose these values, so that GraphiteConfig.PercentileMethod applies.
+func NewSlidingWindowTimer(window time.Duration) metrics.Timer {
+	h := metrics.NewHistogram(NewSlidingWindowSample(window, 1028))
+	return &slidingWindowTimer{Timer: metrics.NewCustomTimer(h, metrics.NewMeter()), h: h}
+}
+
+// slidingWindowTimer is a timer whose snapshots expose the values of its
+// histogram.
+type slidingWindowTimer struct {
+	metrics.Timer
+	h metrics.Histogram
+}
+
+// Snapshot returns a read-only copy of t along with the values within the
+// window.
+func (t *slidingWindowTimer) Snapshot() metrics.Timer {
+	return &sampledTimer{Timer: t.Timer.Snapshot(), values: t.h.Snapshot().Sample().Values()}
+}
+
+// sampledTimer is the snapshot of a slidingWindowTimer.
+type sampledTimer struct {
+	metrics.Timer
+	values []int64
+}
+
+// Snapshot returns t.
+func (t *sampledTimer) Snapshot() metrics.Timer { return t }
+
+// Values returns the values within the window when t was taken.
+func (t *sampledTimer) Values() []int64 { return t.values }
+
+// Clear clears all values.
+func (s *SlidingWindowSample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count = 0
+	s.times = s.times[:0]
+	s.values = s.values[:0]
+}
+
+// Count returns the number of values recorded, which may exceed the number
+// of values held.
+func (s *SlidingWindowSample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+// Update records v.
+func (s *SlidingWindowSample) Update(v int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count++
+	if s.size > 0 && len(s.values) >= s.size {
+		s.times = s.times[1:]
+		s.values = s.values[1:]
+	}
+	s.times = append(s.times, s.now())
+	s.values = append(s.values, v)
+}
+
+// Snapshot returns a read-only copy of the values within the window.
+func (s *SlidingWindowSample) Snapshot() metrics.Sample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prune()
+	values := make([]int64, len(s.values))
+	copy(values, s.values)
+	return metrics.NewSampleSnapshot(s.count, values)
+}
+
+// prune drops the values older than the window. It needs s.mutex.
+func (s *SlidingWindowSample) prune() {
+	cutoff := s.now().Add(-s.window)
+	i := 0
+	for i < len(s.times) && s.times[i].Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		s.times = append(s.times[:0], s.times[i:]...)
+		s.values = append(s.values[:0], s.values[i:]...)
+	}
+}
+
+// Max returns the maximum value within the window.
+func (s *SlidingWindowSample) Max() int64 { return s.Snapshot().Max() }
+
+// Mean returns the mean of the values within the window.
+func (s *SlidingWindowSample) Mean() float64 { return s.Snapshot().Mean() }
+
+// Min returns the minimum value within the window.
+func (s *SlidingWindowSample) Min() int64 { return s.Snapshot().Min() }
+
+// Percentile returns an arbitrary percentile of the values within the
+// window.
+func (s *SlidingWindowSample) Percentile(p float64) float64 { return s.Snapshot().Percentile(p) }
+
+// Percentiles returns a slice of arbitrary percentiles of the values within
+// the window.
+func (s *SlidingWindowSample) Percentiles(ps []float64) []float64 {
+	return s.Snapshot().Percentiles(ps)
+}
+
+// Size returns the number of values within the window.
+func (s *SlidingWindowSample) Size() int { return s.Snapshot().Size() }
+
+// StdDev returns the standard deviation of the values within the window.
+func (s *SlidingWindowSample) StdDev() float64 { return s.Snapshot().StdDev() }
+
+// Sum returns the sum of the values within the window.
+func (s *SlidingWindowSample) Sum() int64 { return s.Snapshot().Sum() }
+
+// Values returns a copy of the values within the window.
+func (s *SlidingWindowSample) Values() []int64 { return s.Snapshot().Values() }
+
+// Variance returns the variance of the values within the window.
+func (s *SlidingWindowSample) Variance() float64 { return s.Snapshot().Variance() }
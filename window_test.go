@@ -0,0 +1,39 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowSample(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewSlidingWindowSample(time.Minute, 3)
+	s.now = func() time.Time { return now }
+
+	s.Update(100)
+	now = now.Add(30 * time.Second)
+	s.Update(1)
+	s.Update(2)
+	if s.Max() != 100 || s.Size() != 3 {
+		t.Fatal("bad sample:", s.Values())
+	}
+
+	now = now.Add(45 * time.Second)
+	if s.Max() != 2 || s.Size() != 2 || s.Count() != 3 {
+		t.Fatal("values not pruned:", s.Values())
+	}
+
+	s.Update(3)
+	s.Update(4)
+	if values := s.Values(); len(values) != 3 || values[0] != 2 {
+		t.Fatal("bad size cap:", values)
+	}
+}
+
+func TestSlidingWindowTimer(t *testing.T) {
+	timer := NewSlidingWindowTimer(time.Minute)
+	timer.Update(time.Second)
+	if timer.Snapshot().Max() != int64(time.Second) {
+		t.Fatal("bad max:", timer.Max())
+	}
+}